@@ -0,0 +1,238 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/tickstep/aliyunpan/cmder"
+	"github.com/tickstep/aliyunpan/cmder/cmdtable"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/urfave/cli"
+	"os"
+	"strconv"
+)
+
+func CmdAccount() cli.Command {
+	return cli.Command{
+		Name:      "account",
+		Usage:     "多账号登录管理",
+		UsageText: cmder.App().Name + " account <add|remove|switch|list>",
+		Description: `
+	管理多个已登录的阿里云盘账号，账号可以使用UID、用户名或者昵称指定。
+	用户名或者昵称对应多个账号时，需要使用UID指定，UID可以通过 account list 查看。
+
+	示例:
+
+	登录并添加一个新账号
+	aliyunpan account add
+
+	列出所有账号，* 标记当前使用的账号
+	aliyunpan account list
+
+	切换到UID为 1234 的账号
+	aliyunpan account switch 1234
+
+	删除用户名为 tickstep 的账号
+	aliyunpan account remove tickstep
+`,
+		Category: "阿里云盘账号",
+		Before:   ReloadConfigFunc,
+		After:    SaveConfigFunc,
+		Action: func(c *cli.Context) error {
+			cli.ShowCommandHelp(c, c.Command.Name)
+			return nil
+		},
+		Subcommands: []cli.Command{
+			{
+				Name:      "add",
+				Usage:     "登录并添加账号",
+				UsageText: cmder.App().Name + " account add [-qr]",
+				Description: `
+	添加成功后自动切换到新账号。账号已经存在时会更新该账号的Token。
+	默认使用网页授权登录，按提示一步一步来即可，也可以使用 -qr 扫码登录。
+`,
+				Action: func(c *cli.Context) error {
+					RunAccountAdd(c.Bool("qr"))
+					return nil
+				},
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "qr",
+						Usage: "在终端显示登录二维码，使用阿里云盘App扫码登录",
+					},
+				},
+			},
+			{
+				Name:      "remove",
+				Usage:     "删除账号",
+				UsageText: cmder.App().Name + " account remove <uid|用户名>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						cli.ShowCommandHelp(c, c.Command.Name)
+						return nil
+					}
+					RunAccountRemove(c.Args().Get(0), c.Bool("y"))
+					return nil
+				},
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "y",
+						Usage: "跳过确认",
+					},
+				},
+			},
+			{
+				Name:      "switch",
+				Usage:     "切换账号",
+				UsageText: cmder.App().Name + " account switch <uid|用户名>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						cli.ShowCommandHelp(c, c.Command.Name)
+						return nil
+					}
+					RunAccountSwitch(c.Args().Get(0))
+					return nil
+				},
+			},
+			{
+				Name:      "list",
+				Aliases:   []string{"ls"},
+				Usage:     "列出所有账号",
+				UsageText: cmder.App().Name + " account list",
+				Action: func(c *cli.Context) error {
+					RunAccountList()
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// RunAccountAdd 登录账号并添加到账号列表, 添加后切换到该账号
+func RunAccountAdd(qr bool) {
+	var (
+		ticketId  string
+		openToken *config.PanClientToken
+		webToken  *config.PanClientToken
+		err       error
+	)
+	if qr {
+		ticketId, openToken, webToken, err = RunLoginQR()
+	} else {
+		ticketId, openToken, webToken, err = RunLogin()
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	cloudUser, apierr := config.SetupUserByCookie(openToken, webToken,
+		ticketId, "",
+		config.Config.DeviceId, config.Config.DeviceName,
+		config.Config.ClientId, config.Config.ClientSecret)
+	if cloudUser == nil {
+		fmt.Println("登录失败: ", apierr)
+		return
+	}
+	cloudUser.TicketId = ticketId
+
+	exist := false
+	for _, u := range config.Config.UserList {
+		if u.UserId == cloudUser.UserId {
+			exist = true
+			break
+		}
+	}
+	config.Config.SetActiveUser(cloudUser)
+	if exist {
+		fmt.Printf("账号已存在，已更新Token并切换到该账号: %s (UID: %s)\n", cloudUser.Nickname, cloudUser.UserId)
+	} else {
+		fmt.Printf("添加账号成功，已切换到该账号: %s (UID: %s)\n", cloudUser.Nickname, cloudUser.UserId)
+	}
+}
+
+// RunAccountRemove 从账号列表删除账号, 删除的不是当前账号时保持当前账号不变
+func RunAccountRemove(name string, skipConfirm bool) {
+	u, err := config.Config.FindUser(name)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if !skipConfirm {
+		var confirm string
+		fmt.Printf("确认删除账号: %s (UID: %s) ? (y/n) > ", u.Nickname, u.UserId)
+		if _, err = fmt.Scanln(&confirm); err != nil || (confirm != "y" && confirm != "Y") {
+			return
+		}
+	}
+
+	activeUid := config.Config.ActiveUID
+	deletedUser, err := config.Config.DeleteUser(u.UserId)
+	if err != nil {
+		fmt.Printf("删除账号 %s 失败, 错误: %s\n", u.Nickname, err)
+		return
+	}
+	fmt.Printf("删除账号成功: %s (UID: %s)\n", deletedUser.Nickname, deletedUser.UserId)
+
+	// DeleteUser 会切换到列表的第一个账号, 删除的不是当前账号时切换回原来的账号
+	if activeUid != "" && activeUid != deletedUser.UserId {
+		if _, err = config.Config.SwitchUser(activeUid); err != nil {
+			fmt.Printf("恢复当前账号失败, %s\n", err)
+		}
+		return
+	}
+	if activeUser := config.Config.ActiveUser(); activeUser != nil {
+		fmt.Printf("当前账号切换为: %s (UID: %s)\n", activeUser.Nickname, activeUser.UserId)
+	}
+}
+
+// RunAccountSwitch 切换当前账号
+func RunAccountSwitch(name string) {
+	u, err := config.Config.FindUser(name)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	switchedUser, err := config.Config.SwitchUser(u.UserId)
+	if err != nil {
+		fmt.Printf("切换账号失败, %s\n", err)
+		return
+	}
+	if switchedUser == nil {
+		fmt.Printf("切换账号失败, 账号 %s 的登录信息已失效, 请使用 account add 重新登录\n", u.Nickname)
+		return
+	}
+	fmt.Printf("切换账号: %s (UID: %s)\n", switchedUser.Nickname, switchedUser.UserId)
+}
+
+// RunAccountList 列出所有账号, * 标记当前使用的账号
+func RunAccountList() {
+	if config.Config.NumLogins() == 0 {
+		fmt.Println("未登录任何账号")
+		return
+	}
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetColumnAlignment([]int{tablewriter.ALIGN_DEFAULT, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_CENTER, tablewriter.ALIGN_CENTER})
+	tb.SetHeader([]string{"#", "当前", "uid", "用户名", "昵称"})
+	for k, u := range config.Config.UserList {
+		active := ""
+		if u.UserId == config.Config.ActiveUID {
+			active = "*"
+		}
+		tb.Append([]string{strconv.Itoa(k + 1), active, u.UserId, u.AccountName, u.Nickname})
+	}
+	tb.Render()
+}
@@ -32,8 +32,10 @@ import (
 	"github.com/tickstep/library-go/requester/rio/speeds"
 	"github.com/urfave/cli"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -274,6 +276,9 @@ func CmdAlbum() cli.Command {
     下载相簿 "我的相簿2022" 里面的所有文件
     aliyunpan album download-file 我的相簿2022
 
+    同一张照片有多种格式的文件(例如实况照片的 HEIC 和 MOV)时，只下载 JPEG 格式，没有 JPEG 时按照 raw、heic、jpeg、mov 的顺序选择
+    aliyunpan album download-file -format jpeg 我的相簿2022
+
 `,
 				Action: func(c *cli.Context) error {
 					if config.Config.ActiveUser() == nil {
@@ -298,7 +303,14 @@ func CmdAlbum() cli.Command {
 						saveTo = filepath.Clean(c.String("saveto"))
 					}
 
+					format := strings.ToLower(c.String("format"))
+					if !isAlbumDownloadFormat(format) {
+						fmt.Printf("不支持的文件格式: %s\n", format)
+						return nil
+					}
+
 					do := &DownloadOptions{
+						AlbumFormat:          format,
 						IsPrintStatus:        false,
 						IsExecutedPermission: false,
 						IsOverwrite:          c.Bool("ow"),
@@ -328,6 +340,11 @@ func CmdAlbum() cli.Command {
 						Name:  "np",
 						Usage: "no progress 不展示下载进度条",
 					},
+					cli.StringFlag{
+						Name:  "format",
+						Usage: "同一张照片有多种格式的文件时优先下载的格式：raw, heic, jpeg, mov, original。original 代表下载所有格式的文件",
+						Value: AlbumFormatOriginal,
+					},
 				},
 			},
 		},
@@ -644,6 +661,9 @@ func RunAlbumDownloadFile(albumNames []string, options *DownloadOptions) {
 	}
 	options.IsExecutedPermission = false
 
+	// 上一次下载被 Ctrl+C 中断后, 重新允许开始下载
+	downloader.ResetInterrupted()
+
 	// 设置下载配置
 	cfg := &downloader.Config{
 		Mode:                       transfer.RangeGenMode_BlockSize,
@@ -653,6 +673,7 @@ func RunAlbumDownloadFile(albumNames []string, options *DownloadOptions) {
 		InstanceStateStorageFormat: downloader.InstanceStateStorageFormatJSON,
 		ShowProgress:               options.ShowProgress,
 		ExcludeNames:               options.ExcludeNames,
+		SpeedReportInterval:        downloader.DefaultSpeedReportInterval,
 	}
 	if cfg.CacheSize == 0 {
 		cfg.CacheSize = int(DownloadCacheSize)
@@ -724,6 +745,7 @@ func RunAlbumDownloadFile(albumNames []string, options *DownloadOptions) {
 			fmt.Printf("相簿里面没有文件: %s\n", albumNames[k])
 			continue
 		}
+		fileList = selectAlbumFilesByFormat(fileList, options.AlbumFormat)
 		for _, f := range fileList {
 			// 补全虚拟网盘路径，规则：/<相簿名称>/文件名称
 			f.Path = "/" + albumNames[k] + "/" + f.FileName
@@ -785,3 +807,94 @@ func RunAlbumDownloadFile(albumNames []string, options *DownloadOptions) {
 		tb.Render()
 	}
 }
+
+const (
+	// AlbumFormatRaw RAW格式
+	AlbumFormatRaw = "raw"
+	// AlbumFormatHeic HEIC格式
+	AlbumFormatHeic = "heic"
+	// AlbumFormatJpeg JPEG格式
+	AlbumFormatJpeg = "jpeg"
+	// AlbumFormatMov MOV格式, 实况照片的视频部分
+	AlbumFormatMov = "mov"
+	// AlbumFormatOriginal 下载所有格式的文件
+	AlbumFormatOriginal = "original"
+)
+
+var (
+	// albumFormatOrder 首选格式不存在时依次选择的格式
+	albumFormatOrder = []string{AlbumFormatRaw, AlbumFormatHeic, AlbumFormatJpeg, AlbumFormatMov}
+
+	// albumFormatExts 各个格式对应的文件扩展名
+	albumFormatExts = map[string][]string{
+		AlbumFormatRaw:  {".dng", ".cr2", ".cr3", ".nef", ".arw", ".raf", ".orf", ".rw2"},
+		AlbumFormatHeic: {".heic", ".heif"},
+		AlbumFormatJpeg: {".jpg", ".jpeg"},
+		AlbumFormatMov:  {".mov"},
+	}
+)
+
+// isAlbumDownloadFormat 是否是支持的相簿下载格式
+func isAlbumDownloadFormat(format string) bool {
+	if format == "" || format == AlbumFormatOriginal {
+		return true
+	}
+	_, ok := albumFormatExts[format]
+	return ok
+}
+
+// albumFileFormat 根据扩展名获取文件的格式, 不是照片格式返回空
+func albumFileFormat(fileName string) string {
+	ext := strings.ToLower(path.Ext(fileName))
+	for format, exts := range albumFormatExts {
+		for _, e := range exts {
+			if e == ext {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// selectAlbumFilesByFormat 同一张照片(文件名相同, 扩展名不同)有多种格式的文件时, 只保留 format 格式的文件,
+// 没有该格式时按照 albumFormatOrder 的顺序选择其他格式并输出警告. 不是照片格式的文件全部保留
+func selectAlbumFilesByFormat(fileList aliyunpan.FileList, format string) aliyunpan.FileList {
+	if format == "" || format == AlbumFormatOriginal {
+		return fileList
+	}
+
+	// 按照去掉扩展名的文件名分组
+	names := []string{}
+	groups := map[string]map[string]*aliyunpan.FileEntity{}
+	result := aliyunpan.FileList{}
+	for _, f := range fileList {
+		fileFormat := albumFileFormat(f.FileName)
+		if fileFormat == "" {
+			result = append(result, f)
+			continue
+		}
+		name := strings.TrimSuffix(f.FileName, path.Ext(f.FileName))
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+			groups[name] = map[string]*aliyunpan.FileEntity{}
+		}
+		if _, ok := groups[name][fileFormat]; !ok {
+			groups[name][fileFormat] = f
+		}
+	}
+
+	for _, name := range names {
+		if f, ok := groups[name][format]; ok {
+			result = append(result, f)
+			continue
+		}
+		for _, fallback := range albumFormatOrder {
+			if f, ok := groups[name][fallback]; ok {
+				fmt.Printf("警告: %s 没有 %s 格式的文件, 使用 %s 格式: %s\n", name, format, fallback, f.FileName)
+				result = append(result, f)
+				break
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan/cmder"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/aliyunpan/internal/file/downloader"
+	"github.com/tickstep/aliyunpan/internal/functions/pandownload"
+	"github.com/tickstep/aliyunpan/internal/localfile"
+	"github.com/urfave/cli"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// BundleManifestName 打包文件中的清单文件名
+	BundleManifestName = "manifest.json"
+)
+
+type (
+	// BundleManifest 打包清单，记录打包文件在网盘的原始路径和SHA1，用于接收方校验完整性
+	BundleManifest struct {
+		Name      string                `json:"name"`
+		CreatedAt string                `json:"created_at"`
+		Files     []*BundleManifestItem `json:"files"`
+	}
+
+	// BundleManifestItem 打包清单文件项
+	BundleManifestItem struct {
+		Path string `json:"path"` // 网盘的原始路径
+		Size int64  `json:"size"`
+		SHA1 string `json:"sha1"`
+	}
+)
+
+func CmdBundle() cli.Command {
+	return cli.Command{
+		Name:      "bundle",
+		Usage:     "将多个网盘文件/目录打包成一个tar文件并上传",
+		UsageText: cmder.App().Name + " bundle <文件/目录1> <文件/目录2> ... <保存的网盘目录>",
+		Description: `
+	下载指定的网盘文件/目录到临时目录，打包成一个tar文件上传到指定的网盘目录，可选创建快传链接。
+	tar文件中包含 manifest.json 清单文件，记录每个文件在网盘的原始路径、大小和SHA1，接收方可以据此校验文件完整性。
+
+	示例:
+
+	将 /我的文档 和 /我的视频/1.mp4 打包成 资料.tar 并上传到 /打包 目录
+	aliyunpan bundle -name 资料.tar /我的文档 /我的视频/1.mp4 /打包
+
+	打包并创建快传链接
+	aliyunpan bundle -name 资料.tar -share /我的文档 /打包
+`,
+		Category: "阿里云盘",
+		Before:   ReloadConfigFunc,
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 2 {
+				cli.ShowCommandHelp(c, c.Command.Name)
+				return nil
+			}
+			if config.Config.ActiveUser() == nil {
+				fmt.Println("未登录账号")
+				return nil
+			}
+			if c.Bool("share") && config.Config.ActiveUser().PanClient().WebapiPanClient() == nil {
+				fmt.Println("WEB客户端未登录，无法创建分享链接，请登录后再使用该命令")
+				return nil
+			}
+			args := c.Args()
+			RunBundle(parseDriveId(c), args[:c.NArg()-1], c.String("name"), args[c.NArg()-1], c.Bool("share"))
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "driveId",
+				Usage: "网盘ID",
+				Value: "",
+			},
+			cli.StringFlag{
+				Name:  "name",
+				Usage: "打包的文件名，默认为 bundle-<当前时间>.tar",
+				Value: "",
+			},
+			cli.BoolFlag{
+				Name:  "share",
+				Usage: "上传完成后为打包文件创建快传链接",
+			},
+		},
+	}
+}
+
+// RunBundle 打包网盘文件并上传
+func RunBundle(driveId string, paths []string, bundleName, dstPanPath string, createShare bool) {
+	if bundleName == "" {
+		bundleName = "bundle-" + time.Now().Format("20060102150405") + ".tar"
+	}
+	if !strings.HasSuffix(strings.ToLower(bundleName), ".tar") {
+		bundleName += ".tar"
+	}
+
+	tmpDir, err := os.MkdirTemp("", "aliyunpan-bundle-")
+	if err != nil {
+		fmt.Printf("创建临时目录失败: %s\n", err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 下载到临时目录，文件保存在 <临时目录>/<网盘路径>
+	downloadDir := filepath.Join(tmpDir, "files")
+	downloadOptions := &DownloadOptions{
+		SaveTo:       downloadDir,
+		DriveId:      driveId,
+		MaxRetry:     DefaultUploadMaxRetry,
+		ShowProgress: true,
+	}
+	// 记录网盘文件的SHA1，清单中使用网盘的SHA1而不是下载后计算的SHA1
+	panHashes := map[string]string{}
+	walkDownloadFiles(paths, downloadOptions, &downloader.Config{}, func(f *aliyunpan.FileEntity) {
+		panHashes[f.Path] = strings.ToUpper(f.ContentHash)
+	})
+	if failedCount := RunDownload(paths, downloadOptions); failedCount > 0 {
+		fmt.Printf("有 %d 个文件下载失败，取消打包\n", failedCount)
+		return
+	}
+
+	bundlePath := filepath.Join(tmpDir, bundleName)
+	manifest, err := createBundleTar(bundlePath, bundleName, downloadDir, panHashes)
+	if err != nil {
+		fmt.Printf("创建打包文件失败: %s\n", err)
+		return
+	}
+	if len(manifest.Files) == 0 {
+		fmt.Println("没有下载到任何文件，取消打包")
+		return
+	}
+	fmt.Printf("打包完成, 共 %d 个文件\n", len(manifest.Files))
+
+	bundleSum, err := localfile.GetFileSum(bundlePath, localfile.CHECKSUM_SHA1)
+	if err != nil {
+		fmt.Printf("计算打包文件SHA1失败: %s\n", err)
+		return
+	}
+	RunUpload([]string{bundlePath}, dstPanPath, &UploadOptions{
+		DriveId:      driveId,
+		MaxRetry:     DefaultUploadMaxRetry,
+		ShowProgress: true,
+		BlockSize:    10240 * 1024,
+	})
+
+	// 检查打包文件是否上传成功
+	activeUser := GetActiveUser()
+	bundlePanPath := path.Join(activeUser.PathJoin(driveId, dstPanPath), bundleName)
+	fe, apierr := activeUser.PanClient().OpenapiPanClient().FileInfoByPath(driveId, bundlePanPath)
+	if apierr != nil {
+		fmt.Printf("打包文件上传失败: %s, %s\n", bundlePanPath, apierr)
+		return
+	}
+	if !strings.EqualFold(fe.ContentHash, bundleSum.SHA1) {
+		fmt.Printf("打包文件上传失败: %s, 网盘文件和本地打包文件的SHA1不一致\n", bundlePanPath)
+		return
+	}
+
+	if createShare {
+		RunShareSet([]string{bundlePanPath}, &ShareSetOptions{
+			Mode:    "3",
+			DriveId: driveId,
+		})
+	}
+}
+
+// createBundleTar 将目录下的所有文件和清单文件打包成tar文件, panHashes 为网盘路径对应的SHA1, 没有记录的文件使用本地计算的SHA1
+func createBundleTar(bundlePath, bundleName, fileDir string, panHashes map[string]string) (*BundleManifest, error) {
+	manifest := &BundleManifest{
+		Name:      bundleName,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Files:     []*BundleManifestItem{},
+	}
+
+	fp, err := os.Create(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	tw := tar.NewWriter(fp)
+
+	err = filepath.Walk(fileDir, func(filePath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == fileDir {
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir() || strings.HasSuffix(filePath, pandownload.DownloadSuffix) {
+			return nil
+		}
+		relPath, err := filepath.Rel(fileDir, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		sha1 := panHashes["/"+relPath]
+		if sha1 == "" {
+			sum, err := localfile.GetFileSum(filePath, localfile.CHECKSUM_SHA1)
+			if err != nil {
+				return err
+			}
+			sha1 = strings.ToUpper(sum.SHA1)
+		}
+		manifest.Files = append(manifest.Files, &BundleManifestItem{
+			Path: "/" + relPath,
+			Size: fi.Size(),
+			SHA1: sha1,
+		})
+
+		header, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		header.Name = relPath
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    BundleManifestName,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tw.Write(data); err != nil {
+		return nil, err
+	}
+	return manifest, tw.Close()
+}
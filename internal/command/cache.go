@@ -0,0 +1,103 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan/cmder"
+	"github.com/tickstep/aliyunpan/cmder/cmdtable"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/urfave/cli"
+	"os"
+	"strconv"
+)
+
+func CmdCache() cli.Command {
+	return cli.Command{
+		Name:      "cache",
+		Usage:     "目录列表缓存管理",
+		UsageText: cmder.App().Name + " cache",
+		Description: `
+	交互模式下，命令补全等功能会在内存中缓存网盘的目录列表，缓存有效期为10分钟。
+	在网页端或者其他设备上批量修改了网盘文件后，可以清除缓存以获取最新的目录列表。
+	目录列表缓存只保存在内存中，不会写入磁盘。
+
+	示例:
+
+	查看缓存命中率、缓存项数量
+	aliyunpan cache stats
+
+	清除所有网盘的目录列表缓存
+	aliyunpan cache clear
+`,
+		Category: "配置",
+		Action: func(c *cli.Context) error {
+			cli.ShowCommandHelp(c, c.Command.Name)
+			return nil
+		},
+		Subcommands: []cli.Command{
+			{
+				Name:      "clear",
+				Usage:     "清除目录列表缓存",
+				UsageText: cmder.App().Name + " cache clear",
+				Action: func(c *cli.Context) error {
+					if config.Config.ActiveUser() == nil {
+						fmt.Println("未登录账号")
+						return nil
+					}
+					RunCacheClear()
+					return nil
+				},
+			},
+			{
+				Name:      "stats",
+				Usage:     "显示目录列表缓存统计",
+				UsageText: cmder.App().Name + " cache stats",
+				Action: func(c *cli.Context) error {
+					if config.Config.ActiveUser() == nil {
+						fmt.Println("未登录账号")
+						return nil
+					}
+					RunCacheStats()
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// RunCacheClear 清除当前账号的目录列表缓存
+func RunCacheClear() {
+	activeUser := GetActiveUser()
+	cleared := activeUser.ClearCache()
+	if activeUser.PanClient().OpenapiPanClient() != nil {
+		activeUser.PanClient().OpenapiPanClient().ClearCache()
+	}
+	fmt.Printf("已清除目录列表缓存, 共 %d 项\n", cleared)
+}
+
+// RunCacheStats 显示当前账号的目录列表缓存统计
+func RunCacheStats() {
+	stats := GetActiveUser().CacheStats()
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"缓存项数量", "命中次数", "未命中次数", "命中率", "磁盘占用"})
+	tb.Append([]string{
+		strconv.Itoa(stats.Entries),
+		strconv.FormatInt(stats.Hits, 10),
+		strconv.FormatInt(stats.Misses, 10),
+		fmt.Sprintf("%.2f%%", stats.HitRatio()*100),
+		"0B (仅内存缓存)",
+	})
+	tb.Render()
+}
@@ -85,10 +85,12 @@ func CmdConfig() cli.Command {
 
 		cache_size 的值支持可选设置单位, 单位不区分大小写, b 和 B 均表示字节的意思, 如 64KB, 1MB, 32kb, 65536b, 65536
 		max_download_rate, max_upload_rate 的值支持可选设置单位, 单位为每秒的传输速率, 后缀'/s' 可省略, 如 2MB/s, 2MB, 2m, 2mb 均为一个意思
+		bandwidth_profile 的值为 名称=速度, 速度的格式和 max_download_rate 相同, 速度为空则删除该方案, 可以多次指定
 
 	例子:
 		aliyunpan config set -cache_size 64KB
-		aliyunpan config set -cache_size 16384 -max_download_parallel 200 -savedir D:/download`,
+		aliyunpan config set -cache_size 16384 -max_download_parallel 200 -savedir D:/download
+		aliyunpan config set -bandwidth_profile day=1MB -bandwidth_profile night=0`,
 				Action: func(c *cli.Context) error {
 					if c.NumFlags() <= 0 || c.NArg() > 0 {
 						cli.ShowCommandHelp(c, c.Command.Name)
@@ -107,6 +109,13 @@ func CmdConfig() cli.Command {
 					if c.IsSet("max_upload_parallel") {
 						config.Config.MaxUploadParallel = c.Int("max_upload_parallel")
 					}
+					if c.IsSet("chunk_size_mb") {
+						if c.Int("chunk_size_mb") < 0 || c.Int("chunk_size_mb") > config.MaxChunkSizeMB {
+							fmt.Printf("设置 chunk_size_mb 错误: 分片大小必须在 0 ~ %d 之间, 0代表使用默认值\n", config.MaxChunkSizeMB)
+							return nil
+						}
+						config.Config.ChunkSizeMB = c.Int("chunk_size_mb")
+					}
 					if c.IsSet("max_download_rate") {
 						err := config.Config.SetMaxDownloadRateByStr(c.String("max_download_rate"))
 						if err != nil {
@@ -121,6 +130,14 @@ func CmdConfig() cli.Command {
 							return nil
 						}
 					}
+					if c.IsSet("bandwidth_profile") {
+						for _, s := range c.StringSlice("bandwidth_profile") {
+							if err := config.Config.SetBandwidthProfileByStr(s); err != nil {
+								fmt.Printf("设置 bandwidth_profile 错误: %s\n", err)
+								return nil
+							}
+						}
+					}
 					if c.IsSet("savedir") {
 						config.Config.SaveDir = c.String("savedir")
 					}
@@ -164,6 +181,10 @@ func CmdConfig() cli.Command {
 						Name:  "max_upload_parallel",
 						Usage: "上传文件最大并发量",
 					},
+					cli.IntFlag{
+						Name:  "chunk_size_mb",
+						Usage: "默认上传分片大小，单位MB，0代表使用默认值",
+					},
 					cli.StringFlag{
 						Name:  "max_download_rate",
 						Usage: "限制最大下载速度, 0代表不限制",
@@ -172,6 +193,10 @@ func CmdConfig() cli.Command {
 						Name:  "max_upload_rate",
 						Usage: "限制最大上传速度, 0代表不限制",
 					},
+					cli.StringSliceFlag{
+						Name:  "bandwidth_profile",
+						Usage: "设置下载限速方案, 格式为 名称=速度, 速度为空则删除该方案",
+					},
 					cli.StringFlag{
 						Name:  "savedir",
 						Usage: "下载文件的储存目录",
@@ -198,6 +223,112 @@ func CmdConfig() cli.Command {
 					},
 				},
 			},
+			{
+				Name:      "proxy",
+				Usage:     "查看和测试代理设置",
+				UsageText: cmder.App().Name + " config proxy",
+				Action: func(c *cli.Context) error {
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				},
+				Subcommands: []cli.Command{
+					{
+						Name:      "list",
+						Usage:     "显示当前配置的代理，并测试代理的连通性",
+						UsageText: cmder.App().Name + " config proxy list",
+						Action: func(c *cli.Context) error {
+							RunProxyList()
+							return nil
+						},
+					},
+					{
+						Name:      "test",
+						Usage:     "测试指定的代理，不会保存到配置",
+						UsageText: cmder.App().Name + " config proxy test <代理地址>",
+						Description: `
+	例子:
+		aliyunpan config proxy test http://127.0.0.1:8888
+		aliyunpan config proxy test socks5://127.0.0.1:8889`,
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								cli.ShowCommandHelp(c, c.Command.Name)
+								return nil
+							}
+							RunProxyTest(c.Args().Get(0))
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:      "backup",
+				Usage:     "备份配置目录到网盘",
+				UsageText: cmder.App().Name + " config backup [-include-tokens] <网盘目录>",
+				Description: `
+	将配置目录(不包括日志目录)打包成 aliyunpan_config_backup_<时间>.zip 并上传到网盘指定的目录。
+	默认不备份账号的授权Token，恢复到新设备后需要重新登录，或者指定 -include-tokens 一起备份。
+
+	例子:
+		aliyunpan config backup /备份/aliyunpan
+		aliyunpan config backup -include-tokens /备份/aliyunpan`,
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						cli.ShowCommandHelp(c, c.Command.Name)
+						return nil
+					}
+					if config.Config.ActiveUser() == nil {
+						fmt.Println("未登录账号")
+						return nil
+					}
+					RunConfigBackup(parseDriveId(c), c.Args().Get(0), c.Bool("include-tokens"))
+					return nil
+				},
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "driveId",
+						Usage: "网盘ID",
+						Value: "",
+					},
+					cli.BoolFlag{
+						Name:  "include-tokens",
+						Usage: "备份包含账号的授权Token，持有备份文件即可登录账号，请妥善保管",
+					},
+				},
+			},
+			{
+				Name:      "restore",
+				Usage:     "从网盘的备份文件恢复配置目录",
+				UsageText: cmder.App().Name + " config restore [-y] <网盘备份文件路径>",
+				Description: `
+	下载 config backup 创建的备份文件并解压到配置目录，覆盖同名的文件。
+	备份中没有授权Token的账号，恢复后继续使用当前的Token。
+
+	例子:
+		aliyunpan config restore /备份/aliyunpan/aliyunpan_config_backup_20240101_120000.zip`,
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						cli.ShowCommandHelp(c, c.Command.Name)
+						return nil
+					}
+					if config.Config.ActiveUser() == nil {
+						fmt.Println("未登录账号")
+						return nil
+					}
+					RunConfigRestore(parseDriveId(c), c.Args().Get(0), c.Bool("y"))
+					return nil
+				},
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "driveId",
+						Usage: "网盘ID",
+						Value: "",
+					},
+					cli.BoolFlag{
+						Name:  "y",
+						Usage: "跳过确认，直接覆盖当前配置",
+					},
+				},
+			},
 		},
 	}
 }
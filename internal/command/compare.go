@@ -0,0 +1,267 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan/cmder"
+	"github.com/tickstep/aliyunpan/cmder/cmdtable"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/aliyunpan/internal/localfile"
+	"github.com/tickstep/library-go/converter"
+	"github.com/urfave/cli"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// compareFileItem 对比的文件项
+	compareFileItem struct {
+		RelativePath string // 相对于对比根目录的路径，使用 / 分隔
+		Size         int64
+		Hash         string // SHA1，本地文件只有在需要时才计算
+		FullPath     string // 本地或者网盘的完整路径
+	}
+
+	// compareFilter 对比文件过滤器
+	compareFilter struct {
+		includePatterns []string
+		excludePatterns []string
+	}
+)
+
+func CmdCompareLocalPan() cli.Command {
+	return cli.Command{
+		Name:      "compare-local-pan",
+		Usage:     "对比本地目录和网盘目录的差异",
+		UsageText: cmder.App().Name + " compare-local-pan <本地目录> <网盘目录>",
+		Description: `
+	遍历本地目录和网盘目录，按相对路径、文件大小和SHA1对比文件，输出三部分结果：
+	仅本地存在的文件，仅网盘存在的文件，两边都存在但内容不一致的文件。
+	--include 和 --exclude 使用通配符匹配文件名，支持多个
+
+	示例:
+
+	对比本地 D:/Photos 和网盘 /我的相册
+	aliyunpan compare-local-pan D:/Photos /我的相册
+
+	只对比jpg文件，排除.号开头的文件
+	aliyunpan compare-local-pan --include "*.jpg" --exclude ".*" D:/Photos /我的相册
+`,
+		Category: "阿里云盘",
+		Before:   ReloadConfigFunc,
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 2 {
+				cli.ShowCommandHelp(c, c.Command.Name)
+				return nil
+			}
+			if config.Config.ActiveUser() == nil {
+				fmt.Println("未登录账号")
+				return nil
+			}
+			RunCompareLocalPan(c.Args().Get(0), parseDriveId(c), c.Args().Get(1), c.StringSlice("include"), c.StringSlice("exclude"))
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "driveId",
+				Usage: "网盘ID",
+				Value: "",
+			},
+			cli.StringSliceFlag{
+				Name:  "include",
+				Usage: "只对比文件名匹配的文件，支持通配符，可以指定多个",
+			},
+			cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "排除文件名匹配的文件和目录，支持通配符，可以指定多个",
+			},
+		},
+	}
+}
+
+// accept 文件是否参与对比
+func (cf *compareFilter) accept(name string, isDir bool) bool {
+	for _, p := range cf.excludePatterns {
+		if isIncludeFile(p, name) {
+			return false
+		}
+	}
+	if isDir || len(cf.includePatterns) == 0 {
+		return true
+	}
+	for _, p := range cf.includePatterns {
+		if isIncludeFile(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunCompareLocalPan 对比本地目录和网盘目录
+func RunCompareLocalPan(localDir, driveId, panPath string, includes, excludes []string) {
+	filter := &compareFilter{
+		includePatterns: includes,
+		excludePatterns: excludes,
+	}
+
+	localDir = filepath.Clean(localDir)
+	if fi, err := os.Stat(localDir); err != nil || !fi.IsDir() {
+		fmt.Printf("本地目录不存在: %s\n", localDir)
+		return
+	}
+	localFiles, err := listLocalCompareFiles(localDir, filter)
+	if err != nil {
+		fmt.Printf("遍历本地目录出错: %s\n", err)
+		return
+	}
+
+	activeUser := GetActiveUser()
+	panPath = path.Clean(activeUser.PathJoin(driveId, panPath))
+	panDirInfo, apierr := activeUser.PanClient().OpenapiPanClient().FileInfoByPath(driveId, panPath)
+	if apierr != nil || panDirInfo == nil || !panDirInfo.IsFolder() {
+		fmt.Printf("网盘目录不存在: %s\n", panPath)
+		return
+	}
+	panFiles := map[string]*compareFileItem{}
+	if err = listPanCompareFiles(driveId, panDirInfo, "", filter, panFiles); err != nil {
+		fmt.Printf("遍历网盘目录出错: %s\n", err)
+		return
+	}
+
+	onlyLocal := []*compareFileItem{}
+	onlyPan := []*compareFileItem{}
+	different := [][2]*compareFileItem{}
+	for relPath, lf := range localFiles {
+		pf, ok := panFiles[relPath]
+		if !ok {
+			onlyLocal = append(onlyLocal, lf)
+			continue
+		}
+		if lf.Size != pf.Size {
+			different = append(different, [2]*compareFileItem{lf, pf})
+			continue
+		}
+		// 大小一致，再对比SHA1
+		sum, e := localfile.GetFileSum(lf.FullPath, localfile.CHECKSUM_SHA1)
+		if e != nil {
+			fmt.Printf("计算文件SHA1出错: %s, %s\n", lf.FullPath, e)
+			continue
+		}
+		lf.Hash = sum.SHA1
+		if !strings.EqualFold(lf.Hash, pf.Hash) {
+			different = append(different, [2]*compareFileItem{lf, pf})
+		}
+	}
+	for relPath, pf := range panFiles {
+		if _, ok := localFiles[relPath]; !ok {
+			onlyPan = append(onlyPan, pf)
+		}
+	}
+	sort.Slice(onlyLocal, func(i, j int) bool { return onlyLocal[i].RelativePath < onlyLocal[j].RelativePath })
+	sort.Slice(onlyPan, func(i, j int) bool { return onlyPan[i].RelativePath < onlyPan[j].RelativePath })
+	sort.Slice(different, func(i, j int) bool { return different[i][0].RelativePath < different[j][0].RelativePath })
+
+	fmt.Printf("\n仅本地存在的文件 (%d):\n", len(onlyLocal))
+	printCompareFiles(onlyLocal)
+	fmt.Printf("\n仅网盘存在的文件 (%d):\n", len(onlyPan))
+	printCompareFiles(onlyPan)
+
+	fmt.Printf("\n内容不一致的文件 (%d):\n", len(different))
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "文件", "本地大小", "网盘大小", "本地SHA1", "网盘SHA1"})
+	for k, pair := range different {
+		tb.Append([]string{strconv.Itoa(k + 1), pair[0].RelativePath,
+			converter.ConvertFileSize(pair[0].Size, 2), converter.ConvertFileSize(pair[1].Size, 2),
+			pair[0].Hash, pair[1].Hash})
+	}
+	tb.Render()
+}
+
+func printCompareFiles(files []*compareFileItem) {
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "文件", "大小"})
+	for k, f := range files {
+		tb.Append([]string{strconv.Itoa(k + 1), f.RelativePath, converter.ConvertFileSize(f.Size, 2)})
+	}
+	tb.Render()
+}
+
+// listLocalCompareFiles 遍历本地目录
+func listLocalCompareFiles(localDir string, filter *compareFilter) (map[string]*compareFileItem, error) {
+	files := map[string]*compareFileItem{}
+	rootPath := path.Clean(strings.ReplaceAll(localDir, "\\", "/"))
+	err := localfile.WalkAllFile(localfile.NewSymlinkFile(localDir), func(file localfile.SymlinkFile, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if file.LogicPath == rootPath {
+			return nil
+		}
+		if !filter.accept(fi.Name(), fi.IsDir()) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		relPath := strings.TrimPrefix(strings.TrimPrefix(file.LogicPath, rootPath), "/")
+		files[relPath] = &compareFileItem{
+			RelativePath: relPath,
+			Size:         fi.Size(),
+			FullPath:     file.RealPath,
+		}
+		return nil
+	})
+	return files, err
+}
+
+// listPanCompareFiles 递归遍历网盘目录
+func listPanCompareFiles(driveId string, dir *aliyunpan.FileEntity, relDir string, filter *compareFilter, files map[string]*compareFileItem) error {
+	fileList, apierr := GetActivePanClient().OpenapiPanClient().FileListGetAll(&aliyunpan.FileListParam{
+		DriveId:      driveId,
+		ParentFileId: dir.FileId,
+	}, 500)
+	if apierr != nil {
+		return apierr
+	}
+	for _, f := range fileList {
+		if !filter.accept(f.FileName, f.IsFolder()) {
+			continue
+		}
+		relPath := path.Join(relDir, f.FileName)
+		if f.IsFolder() {
+			time.Sleep(200 * time.Millisecond) // 避免触发风控
+			if err := listPanCompareFiles(driveId, f, relPath, filter, files); err != nil {
+				return err
+			}
+			continue
+		}
+		files[relPath] = &compareFileItem{
+			RelativePath: relPath,
+			Size:         f.FileSize,
+			Hash:         strings.ToUpper(f.ContentHash),
+			FullPath:     path.Join(dir.Path, f.FileName),
+		}
+	}
+	return nil
+}
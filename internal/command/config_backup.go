@@ -0,0 +1,115 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RunConfigBackup 将配置目录打包成带时间戳的zip文件并上传到网盘的 panPath 目录.
+// includeTokens 为false时备份中不包含账号的授权Token
+func RunConfigBackup(driveId, panPath string, includeTokens bool) {
+	tmpDir, err := os.MkdirTemp("", "aliyunpan_config_backup")
+	if err != nil {
+		fmt.Printf("创建临时目录失败: %s\n", err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	backupName := "aliyunpan_config_backup_" + time.Now().Format("20060102_150405") + ".zip"
+	zipPath := filepath.Join(tmpDir, backupName)
+	count, err := config.CreateConfigBackup(zipPath, config.GetConfigDir(), includeTokens)
+	if err != nil {
+		fmt.Printf("打包配置目录失败: %s\n", err)
+		return
+	}
+	if includeTokens {
+		fmt.Printf("打包配置目录完成, 共 %d 个文件, 备份包含账号的授权Token, 请妥善保管\n", count)
+	} else {
+		fmt.Printf("打包配置目录完成, 共 %d 个文件, 备份不包含账号的授权Token\n", count)
+	}
+
+	RunUpload([]string{zipPath}, panPath, &UploadOptions{
+		DriveId:      driveId,
+		MaxRetry:     DefaultUploadMaxRetry,
+		ShowProgress: true,
+		BlockSize:    10240 * 1024,
+	})
+	fmt.Printf("配置备份文件: %s\n", path.Join(GetActiveUser().PathJoin(driveId, panPath), backupName))
+}
+
+// RunConfigRestore 下载网盘中的配置备份文件并解压到配置目录, 会覆盖当前的配置
+func RunConfigRestore(driveId, panPath string, skipConfirm bool) {
+	activeUser := GetActiveUser()
+	fullPath := activeUser.PathJoin(driveId, panPath)
+	fileInfo, apierr := activeUser.PanClient().OpenapiPanClient().FileInfoByPath(driveId, fullPath)
+	if apierr != nil {
+		fmt.Printf("获取备份文件失败: %s, %s\n", fullPath, apierr)
+		return
+	}
+	if fileInfo.IsFolder() || !strings.EqualFold(path.Ext(fileInfo.FileName), ".zip") {
+		fmt.Printf("备份文件必须是 config backup 创建的zip文件: %s\n", fullPath)
+		return
+	}
+
+	if !skipConfirm {
+		var confirm string
+		fmt.Printf("恢复配置会覆盖配置目录 %s 中的同名文件, 确认恢复? (y/n) > ", config.GetConfigDir())
+		if _, err := fmt.Scanln(&confirm); err != nil || (confirm != "y" && confirm != "Y") {
+			return
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "aliyunpan_config_restore")
+	if err != nil {
+		fmt.Printf("创建临时目录失败: %s\n", err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 下载到临时目录，文件保存在 <临时目录>/<网盘路径>
+	RunDownload([]string{fullPath}, &DownloadOptions{
+		SaveTo:       tmpDir,
+		DriveId:      driveId,
+		MaxRetry:     DefaultUploadMaxRetry,
+		ShowProgress: true,
+	})
+	zipPath := filepath.Join(tmpDir, fullPath)
+	if _, err = os.Stat(zipPath); err != nil {
+		fmt.Printf("下载备份文件失败: %s\n", fullPath)
+		return
+	}
+
+	// 备份默认不包含Token, 恢复后使用当前同一账号的Token
+	tokens := config.Config.UserTokenSnapshot()
+	files, err := config.RestoreConfigBackup(zipPath, config.GetConfigDir())
+	if err != nil {
+		fmt.Printf("恢复配置失败: %s\n", err)
+		return
+	}
+	if err = config.Config.Reload(); err != nil {
+		fmt.Printf("重载配置错误: %s\n", err)
+		return
+	}
+	if n := config.Config.FillMissingTokens(tokens); n > 0 {
+		fmt.Printf("备份中 %d 个账号没有授权Token, 已使用恢复前的Token\n", n)
+	}
+	fmt.Printf("恢复配置完成, 共 %d 个文件\n", len(files))
+}
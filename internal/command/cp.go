@@ -16,17 +16,21 @@ package command
 import (
 	"fmt"
 	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan_web"
 	"github.com/tickstep/aliyunpan/cmder/cmdtable"
 	"github.com/tickstep/aliyunpan/internal/config"
 	"github.com/urfave/cli"
 	"os"
+	"path"
 	"strconv"
+	"strings"
 )
 
 func CmdCp() cli.Command {
 	return cli.Command{
-		Name:  "cp",
-		Usage: "复制文件/目录",
+		Name:    "cp",
+		Aliases: []string{"copy"},
+		Usage:   "复制文件/目录",
 		UsageText: `
 	aliyunpan cp <文件/目录1> <文件/目录2> <文件/目录3> ... <目标目录>`,
 		Description: `
@@ -39,6 +43,9 @@ func CmdCp() cli.Command {
 
 	将 /我的资源 目录下所有的.png文件 复制到 /我的图片 目录下面，使用通配符匹配
 	aliyunpan cp /我的资源/*.png /我的图片
+
+	逐个文件复制 /我的资源 目录到 /备份 目录下面，/备份/我的资源 已经存在时合并到该目录
+	aliyunpan copy -r /我的资源 /备份
 `,
 		Category: "阿里云盘",
 		Before:   ReloadConfigFunc,
@@ -51,6 +58,10 @@ func CmdCp() cli.Command {
 				fmt.Println("未登录账号")
 				return nil
 			}
+			if c.Bool("r") {
+				RunCopyRecursive(parseDriveId(c), c.Args()...)
+				return nil
+			}
 			RunCopy(parseDriveId(c), c.Args()...)
 			return nil
 		},
@@ -60,6 +71,10 @@ func CmdCp() cli.Command {
 				Usage: "网盘ID",
 				Value: "",
 			},
+			cli.BoolFlag{
+				Name:  "r",
+				Usage: "递归复制目录，遍历源目录逐个复制文件，目标目录下已存在同名目录时合并到该目录，已存在内容相同的同名文件时跳过",
+			},
 		},
 	}
 }
@@ -121,3 +136,138 @@ func RunCopy(driveId string, paths ...string) {
 		fmt.Println("无法复制文件，请稍后重试")
 	}
 }
+
+// RunFileCopy 复制文件/目录到目标目录，源网盘和目标网盘不同时使用跨网盘复制
+func RunFileCopy(srcDriveId, srcFileId, dstDriveId, dstFolderId string) error {
+	panClient := GetActivePanClient()
+	if srcDriveId == dstDriveId {
+		_, apierr := panClient.OpenapiPanClient().FileCopy(&aliyunpan.FileCopyParam{
+			DriveId:        srcDriveId,
+			FileId:         srcFileId,
+			ToParentFileId: dstFolderId,
+		})
+		if apierr != nil {
+			return apierr
+		}
+		return nil
+	}
+
+	if panClient.WebapiPanClient() == nil {
+		return fmt.Errorf("跨网盘复制需要登录WEB客户端")
+	}
+	results, apierr := panClient.WebapiPanClient().FileCrossDriveCopy(&aliyunpan_web.FileCrossCopyParam{
+		FromDriveId:    srcDriveId,
+		FromFileIds:    []string{srcFileId},
+		ToDriveId:      dstDriveId,
+		ToParentFileId: dstFolderId,
+	})
+	if apierr != nil {
+		return apierr
+	}
+	for _, rs := range results {
+		if rs.Status != 201 {
+			return fmt.Errorf("复制失败, 状态码: %d", rs.Status)
+		}
+	}
+	return nil
+}
+
+// RunCopyRecursive 递归复制文件/目录, 目录会在目标目录下创建同名目录(已存在则直接使用)后逐个复制文件
+func RunCopyRecursive(driveId string, paths ...string) {
+	activeUser := GetActiveUser()
+	opFileList, targetFile, _, err := getFileInfo(driveId, paths...)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if opFileList == nil || len(opFileList) == 0 {
+		fmt.Println("没有有效的文件可复制")
+		return
+	}
+
+	// 目标目录的文件列表，按目录ID缓存，重复执行时跳过目标目录下已存在并且内容相同的文件
+	dstFilesCache := map[string]map[string]*aliyunpan.FileEntity{}
+	getDstFiles := func(folderId string) map[string]*aliyunpan.FileEntity {
+		if files, ok := dstFilesCache[folderId]; ok {
+			return files
+		}
+		files := map[string]*aliyunpan.FileEntity{}
+		list, er := activeUser.PanClient().OpenapiPanClient().FileListGetAll(&aliyunpan.FileListParam{
+			DriveId:      driveId,
+			ParentFileId: folderId,
+		}, 500)
+		if er == nil {
+			for _, f := range list {
+				files[f.FileName] = f
+			}
+		}
+		dstFilesCache[folderId] = files
+		return files
+	}
+
+	successCount, failedCount, skippedCount := 0, 0, 0
+	var copyEntity func(file *aliyunpan.FileEntity, srcPath, dstFolderId, dstPath string)
+	copyEntity = func(file *aliyunpan.FileEntity, srcPath, dstFolderId, dstPath string) {
+		if !file.IsFolder() {
+			if dst, ok := getDstFiles(dstFolderId)[file.FileName]; ok && !dst.IsFolder() &&
+				dst.ContentHash != "" && strings.EqualFold(dst.ContentHash, file.ContentHash) {
+				fmt.Printf("跳过已存在的相同文件: %s\n", dstPath)
+				skippedCount++
+				return
+			}
+			if er := RunFileCopy(driveId, file.FileId, driveId, dstFolderId); er != nil {
+				fmt.Printf("复制文件失败: %s, %s\n", srcPath, er)
+				failedCount++
+				return
+			}
+			fmt.Printf("复制文件: %s -> %s\n", srcPath, dstPath)
+			successCount++
+			return
+		}
+
+		// 目标目录下已存在同名目录则合并
+		folderPath := path.Join(dstPath, file.FileName)
+		folderId := ""
+		if fi, er := activeUser.PanClient().OpenapiPanClient().FileInfoByPath(driveId, folderPath); er == nil && fi != nil && fi.IsFolder() {
+			folderId = fi.FileId
+		} else {
+			r, er := activeUser.PanClient().OpenapiPanClient().Mkdir(driveId, dstFolderId, file.FileName)
+			if er != nil || r == nil {
+				fmt.Printf("创建目录失败: %s, %s\n", folderPath, er)
+				failedCount++
+				return
+			}
+			folderId = r.FileId
+			// 新建的目录没有文件，不需要再获取文件列表
+			dstFilesCache[folderId] = map[string]*aliyunpan.FileEntity{}
+		}
+
+		children, er := activeUser.PanClient().OpenapiPanClient().FileListGetAll(&aliyunpan.FileListParam{
+			DriveId:      driveId,
+			ParentFileId: file.FileId,
+		}, 500)
+		if er != nil {
+			fmt.Printf("获取目录文件列表失败: %s, %s\n", srcPath, er)
+			failedCount++
+			return
+		}
+		for _, child := range children {
+			childPath := path.Join(srcPath, child.FileName)
+			if child.IsFolder() {
+				copyEntity(child, childPath, folderId, folderPath)
+			} else {
+				copyEntity(child, childPath, folderId, path.Join(folderPath, child.FileName))
+			}
+		}
+	}
+
+	for _, f := range opFileList {
+		if f.IsFolder() {
+			copyEntity(f, f.Path, targetFile.FileId, targetFile.Path)
+		} else {
+			copyEntity(f, f.Path, targetFile.FileId, path.Join(targetFile.Path, f.FileName))
+		}
+	}
+	fmt.Printf("\n复制完成, 成功 %d 个文件, 跳过 %d 个已存在的相同文件, 失败 %d 个\n", successCount, skippedCount, failedCount)
+	activeUser.DeleteCache([]string{targetFile.Path})
+}
@@ -14,23 +14,33 @@
 package command
 
 import (
+	"errors"
 	"fmt"
+	"github.com/GeertJohan/go.incremental"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
 	"github.com/tickstep/aliyunpan/cmder"
 	"github.com/tickstep/aliyunpan/cmder/cmdtable"
 	"github.com/tickstep/aliyunpan/internal/config"
 	"github.com/tickstep/aliyunpan/internal/file/downloader"
 	"github.com/tickstep/aliyunpan/internal/functions/pandownload"
+	"github.com/tickstep/aliyunpan/internal/functions/pantag"
+	"github.com/tickstep/aliyunpan/internal/global"
 	"github.com/tickstep/aliyunpan/internal/log"
 	"github.com/tickstep/aliyunpan/internal/taskframework"
 	"github.com/tickstep/aliyunpan/internal/utils"
 	"github.com/tickstep/aliyunpan/library/requester/transfer"
 	"github.com/tickstep/library-go/converter"
+	"github.com/tickstep/library-go/logger"
 	"github.com/tickstep/library-go/requester/rio/speeds"
 	"github.com/urfave/cli"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type (
@@ -47,6 +57,46 @@ type (
 		ShowProgress         bool
 		DriveId              string
 		ExcludeNames         []string // 排除的文件名，包括文件夹和文件。即这些文件/文件夹不进行下载，支持正则表达式
+		ExcludeGlobs         []string // 排除的文件名通配符，匹配的文件不进行下载
+		ConnectionPoolSize   int      // 单个文件下载线程共享的连接池大小
+		OutputStructure      string   // 本地目录结构，preserve-保留网盘目录结构，flat-全部文件保存到同一目录
+		FlatConflict         string   // 平铺保存时同名文件的处理策略，rename-自动重命名，skip-跳过
+		ETAFormat            string   // 剩余时间显示格式，duration-时长，datetime-预计完成时间
+		IOPriority           string   // 磁盘IO优先级，background, normal, high，只支持Linux
+		Decrypt              string   // 解密密码，用于解密 upload -encrypt 上传的文件
+		IPBind               string   // 下载连接绑定的本地IP地址
+		Proxy                string   // 下载请求使用的代理地址
+		MaxRedirects         int      // 最多跟随的HTTP重定向次数，0代表使用默认策略
+		MonitorPort          int      // Prometheus 指标服务端口，0代表不启动
+		MonitorHost          string   // Prometheus 指标服务监听地址
+		SplitOutput          int64    // 将文件直接下载为指定大小的分块文件，0代表不分割
+
+		WorkerTimeout    time.Duration // 单个下载线程超过该时间没有收到数据则重新分配，0代表不限制
+		RequestTimeout   time.Duration // 分段请求的空闲超时时间，0代表不限制
+		NetRetry         int           // 下载线程遇到临时性网络错误时的重试次数，0代表不重试
+		ConnReuseTTL     time.Duration // 单个TCP连接最多复用的时长，0代表不限制
+		SpeedInterval    time.Duration // 下载速度的刷新间隔
+		TimeoutOnSlow    string        // 下载速度持续过慢时取消下载，格式为 速度,时长
+		OutputDirPerDate bool          // 按照文件修改日期保存到 YYYY/MM/DD 子目录
+		MaxMemory        int64         // 下载缓存占用的内存上限，0代表不限制
+		VerifyChecksum   bool          // 下载完成后校验文件的SHA1/MD5
+		SaveHeaders      string        // 记录每个分段响应头的文件
+		SegmentLog       string        // 记录每个分段下载数据的CSV文件
+		TrackIPChanges   string        // 记录每个TCP连接CDN IP地址的文件
+		NoPreAlloc       bool          // 不预分配文件空间
+		AutoScale        bool          // 根据实时速度动态调整下载线程数
+		FallbackSingle   bool          // 多线程下载失败后使用单线程重新下载
+		WorkerRateLimit  bool          // 将限速平均分配给每个下载线程
+		SegmentOverlap   int64         // 分段重叠的字节数
+		MaxFiles         int           // 同时下载的最大文件数量, 0代表和下载线程数相同
+		BandwidthProfile string        // 下载限速方案名称，为空则使用配置的 max_download_rate
+		ContinueOnError  bool          // 文件下载失败后继续下载其他文件，默认中止剩余的下载
+		NotifyDoneSound  bool          // 文件下载结束后播放提示音
+		NotifySound      string        // 提示音文件路径
+		AlbumFormat      string        // 相簿下载优先选择的文件格式
+		StartAt          int64         // 从指定的字节位置开始下载
+		DryRun           bool          // 只列出将要下载的文件，不下载任何数据
+		SaveUrls         string        // 将下载链接以 aria2c 输入文件的格式保存到该文件，不下载任何数据
 	}
 
 	// LocateDownloadOption 获取下载链接可选参数
@@ -61,6 +111,12 @@ var (
 
 	// DownloadCacheSize 默认每个线程下载缓存大小
 	DownloadCacheSize = 64 * converter.KB
+
+	// MaxSegmentOverlap 分段重叠的最大字节数
+	MaxSegmentOverlap = 1 * converter.MB
+
+	// aliyunpanReferer 请求下载链接时需要携带的Referer
+	aliyunpanReferer = "https://www.aliyundrive.com/"
 )
 
 func CmdDownload() cli.Command {
@@ -95,6 +151,18 @@ func CmdDownload() cli.Command {
 	下载 /我的资源/1.mp4 并保存下载的文件到本地的 d:/panfile
 	aliyunpan download --saveto d:/panfile /我的资源/1.mp4
 
+	下载 /我的资源 整个目录，在本地 d:/mirror 下重建网盘的目录结构，文件保存为 d:/mirror/我的资源/...
+	aliyunpan download --output-dir d:/mirror /我的资源
+
+	下载 /我的资源 整个目录，所有文件直接保存到 d:/panfile 下，不创建子目录，同名文件自动重命名
+	aliyunpan download --saveto d:/panfile --output-structure flat /我的资源
+
+	下载 /我的相册 整个目录，按照文件修改日期保存到 d:/photos/2023/06/01/1.jpg 这样的子目录
+	aliyunpan download --saveto d:/photos --output-structure flat --output-dir-per-date /我的相册
+
+	预览将要下载的文件和本地保存路径，不下载任何数据
+	aliyunpan download --dry-run /我的资源
+
   参考：
     以下是典型的排除特定文件或者文件夹的例子，注意：参数值必须是正则表达式。在正则表达式中，^表示匹配开头，$表示匹配结尾。
     1)排除@eadir文件或者文件夹：-exn "^@eadir$"
@@ -115,12 +183,23 @@ func CmdDownload() cli.Command {
 			var (
 				saveTo string
 			)
+			if c.String("output-dir") != "" && (c.Bool("save") || c.String("saveto") != "") {
+				fmt.Println("output-dir 不能和 --save, --saveto 一起使用")
+				return nil
+			}
 			if c.Bool("save") {
 				// 使用当前工作目录
 				pwd, _ := os.Getwd()
 				saveTo = path.Clean(pwd)
 			} else if c.String("saveto") != "" {
 				saveTo = filepath.Clean(c.String("saveto"))
+			} else if c.String("output-dir") != "" {
+				// 在指定目录下按照网盘根目录开始的完整路径创建子目录
+				if c.String("output-structure") == pandownload.OutputStructureFlat {
+					fmt.Println("output-dir 会保留网盘的目录结构，不能和 --output-structure flat 一起使用")
+					return nil
+				}
+				saveTo = filepath.Clean(c.String("output-dir"))
 			}
 
 			do := &DownloadOptions{
@@ -135,6 +214,63 @@ func CmdDownload() cli.Command {
 				ShowProgress:         !c.Bool("np"),
 				DriveId:              parseDriveId(c),
 				ExcludeNames:         c.StringSlice("exn"),
+				ExcludeGlobs:         c.StringSlice("exclude"),
+				ConnectionPoolSize:   c.Int("connection-pool-size"),
+				OutputStructure:      c.String("output-structure"),
+				FlatConflict:         c.String("flat-conflict"),
+				ETAFormat:            c.String("eta-format"),
+				IOPriority:           c.String("io-priority"),
+				Decrypt:              c.String("decrypt"),
+				IPBind:               c.String("ip-bind"),
+				Proxy:                c.String("proxy"),
+				MaxRedirects:         c.Int("max-redirects"),
+				MonitorPort:          c.Int("monitor-port"),
+				MonitorHost:          c.String("monitor-host"),
+				SplitOutput:          c.Int64("split-output"),
+				WorkerTimeout:        c.Duration("worker-timeout"),
+				RequestTimeout:       c.Duration("request-timeout"),
+				NetRetry:             c.Int("net-retry"),
+				ConnReuseTTL:         c.Duration("connection-reuse-ttl"),
+				SpeedInterval:        c.Duration("speed-report-interval"),
+				TimeoutOnSlow:        c.String("timeout-on-slow"),
+				OutputDirPerDate:     c.Bool("output-dir-per-date"),
+				MaxMemory:            c.Int64("max-memory"),
+				VerifyChecksum:       c.Bool("verify-checksum"),
+				SaveHeaders:          c.String("save-headers"),
+				SegmentLog:           c.String("segment-log"),
+				TrackIPChanges:       c.String("track-ip-changes"),
+				NoPreAlloc:           c.Bool("no-prealloc"),
+				AutoScale:            c.Bool("auto-scale"),
+				FallbackSingle:       c.Bool("fallback-single-thread"),
+				WorkerRateLimit:      c.Bool("worker-rate-limit"),
+				SegmentOverlap:       c.Int64("segment-overlap"),
+				MaxFiles:             c.Int("max-files"),
+				NotifyDoneSound:      c.Bool("notify-done-sound"),
+				NotifySound:          c.String("notify-sound"),
+				StartAt:              c.Int64("start-at"),
+				DryRun:               c.Bool("dry-run"),
+				SaveUrls:             c.String("save-urls"),
+				BandwidthProfile:     c.String("bandwidth-profile"),
+				ContinueOnError:      c.Bool("continue-on-error"),
+			}
+			if c.Bool("abort-on-error") && c.Bool("continue-on-error") {
+				fmt.Println("abort-on-error 和 continue-on-error 不能同时使用")
+				return nil
+			}
+
+			if profile := do.BandwidthProfile; profile != "" {
+				if _, ok := config.Config.GetBandwidthProfile(profile); !ok {
+					fmt.Printf("未知的限速方案: %s\n", profile)
+					if names := config.Config.BandwidthProfileNames(); len(names) > 0 {
+						fmt.Printf("可用的限速方案: %s\n", strings.Join(names, ", "))
+					} else {
+						fmt.Println("没有配置任何限速方案，请使用 config set -bandwidth_profile 名称=速度 添加")
+					}
+					if !global.IsAppInCliMode {
+						os.Exit(1)
+					}
+					return nil
+				}
 			}
 
 			// 获取下载文件锁，保证下载操作单实例
@@ -145,12 +281,17 @@ func CmdDownload() cli.Command {
 			//	return nil
 			//}
 
-			RunDownload(c.Args(), do)
+			failedCount := RunDownload(c.Args(), do)
 
 			// 释放文件锁
 			//if locker != nil {
 			//	filelocker.UnlockFile(locker)
 			//}
+
+			// 有文件下载失败时以错误状态退出, 交互模式下不退出
+			if failedCount > 0 && !global.IsAppInCliMode {
+				os.Exit(1)
+			}
 			return nil
 		},
 		Flags: []cli.Flag{
@@ -170,6 +311,10 @@ func CmdDownload() cli.Command {
 				Name:  "saveto",
 				Usage: "将下载的文件直接保存到指定的目录",
 			},
+			cli.StringFlag{
+				Name:  "output-dir",
+				Usage: "本地保存的根目录，在该目录下按照网盘根目录开始的完整路径创建子目录保存文件，例如 /我的资源/a/1.mp4 保存为 <output-dir>/我的资源/a/1.mp4，不能和 --save, --saveto 一起使用",
+			},
 			cli.BoolFlag{
 				Name:  "x",
 				Usage: "为文件加上执行权限, (windows系统无效)",
@@ -201,10 +346,260 @@ func CmdDownload() cli.Command {
 				Usage: "exclude name，指定排除的文件夹或者文件的名称，被排除的文件不会进行下载，只支持正则表达式。支持同时排除多个名称，每一个名称就是一个exn参数",
 				Value: nil,
 			},
+			cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "指定排除的文件名通配符，例如 *.tmp、Thumbs.db，匹配的文件不会进行下载，下载结束后输出排除的文件数量。支持同时排除多个通配符，每一个通配符就是一个exclude参数",
+			},
+			cli.IntFlag{
+				Name:  "connection-pool-size",
+				Usage: "单个文件的下载线程共享的TCP连接池大小，复用连接以减少握手开销，0代表每个线程使用独立连接",
+				Value: 0,
+			},
+			cli.StringFlag{
+				Name:  "output-structure",
+				Usage: "本地保存的目录结构，preserve-保留网盘的目录结构，flat-所有文件直接保存到目标目录",
+				Value: pandownload.OutputStructurePreserve,
+			},
+			cli.StringFlag{
+				Name:  "flat-conflict",
+				Usage: "flat 模式下不同目录存在同名文件的处理策略，rename-自动重命名，skip-跳过",
+				Value: pandownload.FlatConflictRename,
+			},
+			cli.StringFlag{
+				Name:  "eta-format",
+				Usage: "剩余时间显示格式，duration-剩余时长，例如3m42s，datetime-预计完成的本地时间，例如14:27:35",
+				Value: downloader.ETAFormatDuration,
+			},
+			cli.StringFlag{
+				Name:  "io-priority",
+				Usage: "下载写入磁盘的IO优先级，background-后台，normal-普通，high-实时(需要root权限)，只支持Linux系统，其他系统忽略该选项",
+			},
+			cli.StringFlag{
+				Name:  "decrypt",
+				Usage: "解密密码，下载完成后解密使用 upload -encrypt 加密上传的文件",
+			},
+			cli.StringFlag{
+				Name:  "ip-bind",
+				Usage: "下载连接绑定的本地IP地址，用于多网卡的机器指定下载使用的网卡",
+			},
+			cli.StringFlag{
+				Name:  "proxy",
+				Usage: "下载文件数据使用的代理地址，支持 http/https/socks5 代理，例如 http://127.0.0.1:8888。不指定则使用 config set -proxy 配置的代理",
+			},
+			cli.IntFlag{
+				Name:  "max-redirects",
+				Usage: "下载请求最多跟随的重定向次数，超过则下载失败，防止无限重定向。0代表使用默认策略",
+				Value: 0,
+			},
+			cli.IntFlag{
+				Name:  "monitor-port",
+				Usage: "下载过程中在指定端口启动 Prometheus 指标服务，访问 http://localhost:<端口>/metrics 获取下载指标。0代表不启动",
+				Value: 0,
+			},
+			cli.StringFlag{
+				Name:  "monitor-host",
+				Usage: "Prometheus 指标服务的监听地址，默认只允许本机访问，0.0.0.0 代表监听所有网卡",
+				Value: downloader.DefaultMetricsHost,
+			},
+			cli.Int64Flag{
+				Name:  "split-output",
+				Usage: "将文件直接下载为指定大小(字节)的分块文件 <文件名>.part001, <文件名>.part002 ...，不生成完整的文件。用于保存到有单文件大小限制的文件系统，例如FAT32。分块文件已经存在则跳过。0代表不分割",
+				Value: 0,
+			},
+			cli.DurationFlag{
+				Name:  "worker-timeout",
+				Usage: "单个下载线程超过指定的时间没有收到数据则停止该线程，例如 30s。剩余的数据分配给新的线程下载。0代表不限制",
+			},
+			cli.DurationFlag{
+				Name:  "connection-reuse-ttl",
+				Usage: "单个TCP连接最多复用的时长，例如 5m。超过后使用新的连接，避免长时间下载时复用已失效的CDN连接。0代表不限制",
+			},
+			cli.DurationFlag{
+				Name:  "speed-report-interval",
+				Usage: "下载速度和进度的刷新间隔，例如 5s。下载大量小文件时调大该值可以减少输出",
+				Value: downloader.DefaultSpeedReportInterval,
+			},
+			cli.StringFlag{
+				Name:  "timeout-on-slow",
+				Usage: "下载速度持续低于指定值时取消该文件的下载，格式为 速度,时长，例如 50KB,2m 表示最近2分钟的平均速度低于50KB/s则取消。取消的文件不再重试，可以稍后重新下载续传",
+			},
+			cli.DurationFlag{
+				Name:  "request-timeout",
+				Usage: "分段请求的空闲超时时间，例如 30s、2m。建立连接或者单次读取数据超过该时间没有完成则取消请求，剩余的数据分配给新的线程下载。0代表不限制",
+			},
+			cli.IntFlag{
+				Name:  "net-retry",
+				Usage: "下载线程遇到临时性网络错误(连接断开、连接被重置、超时)时的重试次数，重试间隔从1秒开始按指数增长，最长30秒，重试期间从已下载的位置继续。0代表不重试，由监控线程重设连接",
+			},
+			cli.BoolFlag{
+				Name:  "output-dir-per-date",
+				Usage: "按照网盘文件的修改日期，将文件保存到本地保存目录下的 年/月/日(YYYY/MM/DD) 子目录",
+			},
+			cli.Int64Flag{
+				Name:  "max-memory",
+				Usage: "下载缓存占用的内存上限(字节)，超过时自动调低下载缓存或者下载线程数，用于内存较小的设备例如树莓派。0代表不限制",
+				Value: 0,
+			},
+			cli.BoolFlag{
+				Name:  "verify-checksum",
+				Usage: "下载完成后计算本地文件的SHA1/MD5并与网盘记录的校验值比较，不一致则删除文件并重新下载",
+			},
+			cli.StringFlag{
+				Name:  "save-headers",
+				Usage: "将每个分段下载请求的HTTP响应头以JSON格式追加到指定的文件，用于分析CDN节点的情况",
+			},
+			cli.BoolFlag{
+				Name:  "abort-on-error",
+				Usage: "文件下载失败(重试次数用完)后中止剩余的下载，默认行为",
+			},
+			cli.BoolFlag{
+				Name:  "continue-on-error",
+				Usage: "文件下载失败后继续下载其他文件，失败的文件记录到日志目录的 download_error_records.csv",
+			},
+			cli.StringFlag{
+				Name:  "bandwidth-profile",
+				Usage: "使用 config set -bandwidth_profile 配置的下载限速方案，覆盖 max_download_rate 配置",
+			},
+			cli.StringFlag{
+				Name:  "segment-log",
+				Usage: "每个分段下载结束后将线程ID、范围、字节数、耗时、速度、CDN主机和HTTP状态码以CSV格式追加到指定的文件，用于离线分析CDN节点的性能",
+			},
+			cli.StringFlag{
+				Name:  "track-ip-changes",
+				Usage: "将每个TCP连接的CDN主机、IP地址和当时已下载的字节数以JSON格式追加到指定的文件，同一主机的IP发生变化(CDN切换)时标记 changed，用于排查下载问题",
+			},
+			cli.BoolFlag{
+				Name:  "no-prealloc",
+				Usage: "不预分配文件空间，用于预分配会出错的网络文件系统(SMB、NFS)",
+			},
+			cli.BoolFlag{
+				Name:  "auto-scale",
+				Usage: "根据实时下载速度动态调整线程数，速度低于峰值的一半时增加线程，出错的线程过多时减少线程",
+			},
+			cli.BoolFlag{
+				Name:  "fallback-single-thread",
+				Usage: "多线程下载失败后使用单线程重新下载整个文件，用于不支持多个Range并发请求的CDN节点",
+			},
+			cli.BoolFlag{
+				Name:  "worker-rate-limit",
+				Usage: "将最大下载速度平均分配给每个下载线程，每个线程单独限速，使各线程的带宽更加均匀",
+			},
+			cli.Int64Flag{
+				Name:  "segment-overlap",
+				Usage: "每个分段向前多请求的字节数，和前一个分段重叠，重叠部分读取后丢弃。用于规避部分CDN节点在分段边界处丢失数据的问题，例如 512",
+				Value: 0,
+			},
+			cli.IntFlag{
+				Name:  "max-files",
+				Usage: "下载目录或者多个文件时，同时下载的最大文件数量，所有文件下载结束后输出成功和失败的数量。0代表不限制，和下载线程数相同",
+				Value: 0,
+			},
+			cli.BoolFlag{
+				Name:  "notify-done-sound",
+				Usage: "每个文件下载成功或者失败后播放系统提示音。macOS使用afplay，Linux使用PulseAudio的paplay，找不到播放器时忽略",
+			},
+			cli.StringFlag{
+				Name:  "notify-sound",
+				Usage: "notify-done-sound 使用的提示音文件路径，为空则使用系统默认的提示音",
+			},
+			cli.Int64Flag{
+				Name:  "start-at",
+				Usage: "从指定的字节位置开始下载，之前的数据不下载，用于在已有的部分文件后面追加剩余的数据。本地文件已存在时需要配合 ow 参数使用，不能和 verify-checksum 一起使用",
+				Value: 0,
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "只列出将要下载的文件、大小和本地保存路径，以及预计的下载数据总量，不下载任何数据",
+			},
+			cli.StringFlag{
+				Name:  "save-urls",
+				Usage: "获取文件的下载链接，以 aria2c 输入文件的格式追加到指定的文件，不下载任何数据。可以使用 aria2c -i <文件> 下载",
+			},
+		},
+		Subcommands: []cli.Command{
+			{
+				Name:      "resume-list",
+				Usage:     "列出未完成的下载，选择后继续下载",
+				UsageText: cmder.App().Name + " download resume-list",
+				Description: `
+	下载过程中会将未完成的下载登记到配置目录下的 downloads.json 文件，下载完成或者取消后自动移除。
+	该命令列出所有登记的下载任务，输入 # 值后继续下载对应的文件。
+
+	示例:
+
+	列出未完成的下载，并选择继续下载
+	aliyunpan download resume-list
+`,
+				Action: func(c *cli.Context) error {
+					if config.Config.ActiveUser() == nil {
+						fmt.Println("未登录账号")
+						return nil
+					}
+					RunDownloadResumeList()
+					return nil
+				},
+			},
 		},
 	}
 }
 
+// downloadRegistryFilePath 未完成下载的登记文件路径
+func downloadRegistryFilePath() string {
+	return filepath.Join(config.GetConfigDir(), "downloads.json")
+}
+
+// RunDownloadResumeList 列出登记的未完成下载, 选择其中一个继续下载
+func RunDownloadResumeList() {
+	registry, err := pandownload.NewDownloadRegistry(downloadRegistryFilePath())
+	if err != nil {
+		fmt.Printf("读取下载登记文件失败: %s\n", err)
+		return
+	}
+	entries := registry.Entries()
+	if len(entries) == 0 {
+		fmt.Println("没有未完成的下载")
+		return
+	}
+
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "文件路径", "保存位置", "进度", "状态", "更新时间"})
+	for k, entry := range entries {
+		progress := converter.ConvertFileSize(entry.Downloaded, 2) + "/" + converter.ConvertFileSize(entry.TotalSize, 2)
+		if entry.TotalSize > 0 {
+			progress += fmt.Sprintf("(%.2f%%)", float64(entry.Downloaded)/float64(entry.TotalSize)*100)
+		}
+		status := "已暂停"
+		if entry.Status == pandownload.RegistryStatusDownloading {
+			// 程序被强制退出时也会保留该状态
+			status = "下载中/已中断"
+		}
+		tb.Append([]string{strconv.Itoa(k + 1), entry.PanPath, entry.SavePath, progress, status, entry.UpdatedAt})
+	}
+	tb.Render()
+
+	// 提示输入 index
+	var index string
+	fmt.Printf("输入要继续下载的 # 值，直接回车退出 > ")
+	if _, err = fmt.Scanln(&index); err != nil || index == "" {
+		return
+	}
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 1 || n > len(entries) {
+		fmt.Printf("# 值不正确\n")
+		return
+	}
+
+	// 平铺保存到原来的目录, 保存路径和之前的下载一致, 从断点继续下载
+	entry := entries[n-1]
+	RunDownload([]string{entry.PanPath}, &DownloadOptions{
+		SaveTo:          filepath.Dir(entry.SavePath),
+		OutputStructure: pandownload.OutputStructureFlat,
+		DriveId:         entry.DriveId,
+		MaxRetry:        pandownload.DefaultDownloadMaxRetry,
+		ShowProgress:    true,
+	})
+}
+
 func downloadPrintFormat(load int) string {
 	if load <= 1 {
 		return pandownload.DefaultPrintFormat
@@ -212,8 +607,8 @@ func downloadPrintFormat(load int) string {
 	return "\r[%s] ↓ %s/%s %s/s in %s, left %s ..."
 }
 
-// RunDownload 执行下载网盘内文件
-func RunDownload(paths []string, options *DownloadOptions) {
+// RunDownload 执行下载网盘内文件, 返回下载失败的文件数量
+func RunDownload(paths []string, options *DownloadOptions) (failedCount int) {
 	activeUser := GetActiveUser()
 	activeUser.PanClient().OpenapiPanClient().EnableCache()
 	activeUser.PanClient().OpenapiPanClient().ClearCache()
@@ -247,19 +642,222 @@ func RunDownload(paths []string, options *DownloadOptions) {
 		options.IsExecutedPermission = false
 	}
 
+	// 上一次下载被 Ctrl+C 中断后, 重新允许开始下载
+	downloader.ResetInterrupted()
+
+	// 使用限速方案的最大下载速度
+	maxRate := config.Config.MaxDownloadRate
+	if options.BandwidthProfile != "" {
+		if rate, ok := config.Config.GetBandwidthProfile(options.BandwidthProfile); ok {
+			maxRate = rate
+		}
+	}
+
 	// 设置下载配置
 	cfg := &downloader.Config{
 		Mode:                       transfer.RangeGenMode_BlockSize,
 		CacheSize:                  config.Config.CacheSize,
 		BlockSize:                  MaxDownloadRangeSize,
-		MaxRate:                    config.Config.MaxDownloadRate,
+		MaxRate:                    maxRate,
 		InstanceStateStorageFormat: downloader.InstanceStateStorageFormatJSON,
 		ShowProgress:               options.ShowProgress,
 		ExcludeNames:               options.ExcludeNames,
+		ExcludeGlobs:               options.ExcludeGlobs,
+		ConnectionPoolSize:         options.ConnectionPoolSize,
+		ETAFormat:                  options.ETAFormat,
+		IPBind:                     options.IPBind,
+		ProxyURL:                   options.Proxy,
+		MaxRedirects:               options.MaxRedirects,
+		MonitorPort:                options.MonitorPort,
+		MonitorHost:                options.MonitorHost,
+		SplitSize:                  options.SplitOutput,
+		WorkerTimeout:              options.WorkerTimeout,
+		RequestTimeout:             options.RequestTimeout,
+		RetryPolicy:                downloader.NewRetryPolicy(options.NetRetry),
+		ConnectionReuseTTL:         options.ConnReuseTTL,
+		SpeedReportInterval:        options.SpeedInterval,
+		MaxMemoryBytes:             options.MaxMemory,
+		VerifyChecksum:             options.VerifyChecksum,
+		SaveHeadersFile:            options.SaveHeaders,
+		SegmentLogFile:             options.SegmentLog,
+		TrackIPChangesFile:         options.TrackIPChanges,
+		DisablePreAlloc:            options.NoPreAlloc,
+		AutoScale:                  options.AutoScale,
+		FallbackSingleThread:       options.FallbackSingle,
+		PerWorkerRateLimit:         options.WorkerRateLimit,
+		SegmentOverlap:             options.SegmentOverlap,
+		NotifyDoneSound:            options.NotifyDoneSound,
+		NotifySound:                options.NotifySound,
+		StartAt:                    options.StartAt,
 	}
 	if cfg.CacheSize == 0 {
 		cfg.CacheSize = int(DownloadCacheSize)
 	}
+	if cfg.SpeedReportInterval == 0 {
+		cfg.SpeedReportInterval = downloader.DefaultSpeedReportInterval
+	}
+	if options.MaxFiles < 0 {
+		fmt.Printf("同时下载的文件数量不能小于0\n")
+		return
+	}
+	for _, pattern := range options.ExcludeGlobs {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			fmt.Printf("无效的排除通配符: %s\n", pattern)
+			return
+		}
+	}
+	if cfg.StartAt < 0 {
+		fmt.Printf("开始下载的位置不能小于0\n")
+		return
+	}
+	if cfg.StartAt > 0 && len(paths) != 1 {
+		fmt.Printf("start-at 只能用于下载单个文件\n")
+		return
+	}
+	if cfg.StartAt > 0 && cfg.VerifyChecksum {
+		// 跳过的部分没有写入本地文件, 校验一定不通过
+		fmt.Printf("start-at 不能和 verify-checksum 一起使用\n")
+		return
+	}
+	if cfg.SegmentOverlap < 0 || cfg.SegmentOverlap > MaxSegmentOverlap {
+		fmt.Printf("分段重叠的字节数必须在 0 ~ %d 之间\n", MaxSegmentOverlap)
+		return
+	}
+
+	if cfg.ETAFormat != "" && cfg.ETAFormat != downloader.ETAFormatDuration && cfg.ETAFormat != downloader.ETAFormatDatetime {
+		fmt.Printf("不支持的剩余时间显示格式: %s\n", cfg.ETAFormat)
+		return
+	}
+
+	if cfg.MaxMemoryBytes < 0 {
+		fmt.Printf("内存上限不能小于0\n")
+		return
+	}
+
+	if cfg.WorkerTimeout < 0 {
+		fmt.Printf("线程超时时间不能小于0\n")
+		return
+	}
+
+	if cfg.RequestTimeout < 0 {
+		fmt.Printf("分段请求超时时间不能小于0\n")
+		return
+	}
+
+	if options.NetRetry < 0 {
+		fmt.Printf("网络错误重试次数不能小于0\n")
+		return
+	}
+
+	if cfg.ConnectionReuseTTL < 0 {
+		fmt.Printf("连接复用时长不能小于0\n")
+		return
+	}
+
+	if cfg.SpeedReportInterval < 0 {
+		fmt.Printf("速度刷新间隔不能小于0\n")
+		return
+	}
+
+	if options.TimeoutOnSlow != "" {
+		slowSpeed, err := parseSlowSpeedOption(options.TimeoutOnSlow)
+		if err != nil {
+			fmt.Printf("timeout-on-slow 参数错误: %s\n", err)
+			return
+		}
+		cfg.SlowSpeedKillAfter = slowSpeed
+	}
+
+	if cfg.SplitSize < 0 {
+		fmt.Printf("分块大小不能小于0\n")
+		return
+	}
+	if cfg.SplitSize > 0 && options.Decrypt != "" {
+		fmt.Printf("split-output 不能和 decrypt 同时使用\n")
+		return
+	}
+
+	if cfg.MaxRedirects < 0 {
+		fmt.Printf("重定向次数不能小于0\n")
+		return
+	}
+
+	if cfg.ProxyURL != "" {
+		if _, err := downloader.ParseProxyURL(cfg.ProxyURL); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if cfg.IPBind != "" {
+		if err := downloader.CheckLocalIP(cfg.IPBind); err != nil {
+			fmt.Printf("绑定本地IP地址失败: %s\n", err)
+			return
+		}
+	}
+
+	// 启动 Prometheus 指标服务，所有文件下载完成后关闭
+	if cfg.MonitorPort > 0 && !options.DryRun && options.SaveUrls == "" {
+		addr := downloader.MetricsAddr(cfg.MonitorHost, cfg.MonitorPort)
+		metricsServer, err := downloader.StartMetricsServer(addr)
+		if err != nil {
+			fmt.Printf("启动指标服务失败: %s\n", err)
+			return
+		}
+		defer metricsServer.Close()
+		fmt.Printf("指标服务已启动: http://%s/metrics\n", addr)
+	}
+
+	// 设置磁盘IO优先级
+	if options.IOPriority != "" && !options.DryRun && options.SaveUrls == "" {
+		if err := downloader.SetIOPriority(options.IOPriority); err != nil {
+			fmt.Printf("设置IO优先级失败: %s\n", err)
+			if err == downloader.ErrIOPriorityUnknown {
+				return
+			}
+		}
+	}
+
+	// 本地目录结构
+	var flatSavePaths *pandownload.FlatSavePathRegistry
+	switch options.OutputStructure {
+	case "", pandownload.OutputStructurePreserve:
+	case pandownload.OutputStructureFlat:
+		if options.FlatConflict != "" && options.FlatConflict != pandownload.FlatConflictRename && options.FlatConflict != pandownload.FlatConflictSkip {
+			fmt.Printf("不支持的同名文件处理策略: %s\n", options.FlatConflict)
+			return
+		}
+		flatSavePaths = pandownload.NewFlatSavePathRegistry(options.FlatConflict)
+	default:
+		fmt.Printf("不支持的目录结构选项: %s\n", options.OutputStructure)
+		return
+	}
+
+	if options.DryRun {
+		paths, err := makePathAbsolute(options.DriveId, paths...)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		saveRootPath := options.SaveTo
+		if saveRootPath == "" {
+			saveRootPath = GetActiveUser().GetSavePath("")
+		}
+		runDownloadDryRun(paths, options, cfg, saveRootPath, flatSavePaths != nil)
+		return
+	}
+
+	if options.SaveUrls != "" {
+		paths, err := makePathAbsolute(options.DriveId, paths...)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		saveRootPath := options.SaveTo
+		if saveRootPath == "" {
+			saveRootPath = GetActiveUser().GetSavePath("")
+		}
+		return runDownloadSaveUrls(paths, options, cfg, saveRootPath, flatSavePaths != nil, options.SaveUrls)
+	}
 
 	// 设置下载最大并发量
 	if options.Parallel < 1 {
@@ -304,7 +902,8 @@ func RunDownload(paths []string, options *DownloadOptions) {
 
 	var (
 		executor = taskframework.TaskExecutor{
-			IsFailedDeque: true, // 统计失败的列表
+			IsFailedDeque: true,                     // 统计失败的列表
+			AbortOnFailed: !options.ContinueOnError, // 文件下载失败后中止剩余的下载
 		}
 		statistic = &pandownload.DownloadStatistic{}
 	)
@@ -317,6 +916,74 @@ func RunDownload(paths []string, options *DownloadOptions) {
 	// 下载记录器
 	fileRecorder := log.NewFileRecorder(config.GetLogDir() + "/download_file_records.csv")
 
+	// 下载失败记录器, 失败后继续下载其他文件时记录失败的文件
+	var errorRecorder *log.FileRecorder
+	if options.ContinueOnError {
+		errorRecorder = log.NewFileRecorder(config.GetLogDir() + "/download_error_records.csv")
+	}
+
+	// 未完成下载的登记文件
+	registry, err := pandownload.NewDownloadRegistry(downloadRegistryFilePath())
+	if err != nil {
+		logger.Verbosef("open download registry error: %s\n", err)
+		registry = nil
+	}
+
+	// 本地标签数据库，存在时才在下载完成后恢复文件扩展属性
+	var tagDatabase *pantag.TagDatabase
+	if _, err := os.Stat(pantag.TagDatabasePath()); err == nil {
+		if tagDatabase, err = pantag.NewTagDatabase(); err != nil {
+			logger.Verbosef("open tag database error: %s\n", err)
+			tagDatabase = nil
+		} else {
+			defer tagDatabase.Close()
+		}
+	}
+
+	// downloadSavePath 文件在本地的保存路径
+	downloadSavePath := func(f *aliyunpan.FileEntity) string {
+		if flatSavePaths != nil {
+			return filepath.Join(originSaveRootPath, f.FileName)
+		}
+		if options.SaveTo != "" {
+			return filepath.Join(options.SaveTo, f.Path)
+		}
+		return GetActiveUser().GetSavePath(f.Path)
+	}
+
+	// newDownloadTaskUnit 创建文件的下载任务, 子目录的下载任务加入 parent 执行
+	newDownloadTaskUnit := func(f *aliyunpan.FileEntity, parent *taskframework.TaskExecutor) *pandownload.DownloadTaskUnit {
+		newCfg := *cfg
+		return &pandownload.DownloadTaskUnit{
+			Cfg:                  &newCfg, // 复制一份新的cfg
+			PanClient:            panClient,
+			VerbosePrinter:       panCommandVerbose,
+			PrintFormat:          downloadPrintFormat(options.Load),
+			ParentTaskExecutor:   parent,
+			DownloadStatistic:    statistic,
+			IsPrintStatus:        options.IsPrintStatus,
+			IsExecutedPermission: options.IsExecutedPermission,
+			IsOverwrite:          options.IsOverwrite,
+			NoCheck:              options.NoCheck,
+			FilePanPath:          f.Path,
+			DriveId:              options.DriveId,
+			GlobalSpeedsStat:     globalSpeedsStat,
+			FileRecorder:         fileRecorder,
+			ErrorRecorder:        errorRecorder,
+			FlatSavePaths:        flatSavePaths,
+			OutputDirPerDate:     options.OutputDirPerDate,
+			DecryptPassphrase:    options.Decrypt,
+			TagDatabase:          tagDatabase,
+			Registry:             registry,
+			OriginSaveRootPath:   originSaveRootPath,
+			SavePath:             downloadSavePath(f),
+		}
+	}
+
+	if options.MaxFiles > 0 {
+		return runDownloadQueue(paths, options, cfg, statistic, newDownloadTaskUnit, downloadSavePath)
+	}
+
 	// 处理队列
 	for k := range paths {
 		// 使用通配符匹配
@@ -331,42 +998,18 @@ func RunDownload(paths []string, options *DownloadOptions) {
 			continue
 		}
 		for _, f := range fileList {
-			newCfg := *cfg
-
 			// 是否排除下载
-			if utils.IsExcludeFile(f.Path, &newCfg.ExcludeNames) {
+			if utils.IsExcludeFile(f.Path, &cfg.ExcludeNames) {
 				fmt.Printf("排除文件: %s\n", f.Path)
 				continue
 			}
-
-			// 匹配的文件
-			unit := pandownload.DownloadTaskUnit{
-				Cfg:                  &newCfg, // 复制一份新的cfg
-				PanClient:            panClient,
-				VerbosePrinter:       panCommandVerbose,
-				PrintFormat:          downloadPrintFormat(options.Load),
-				ParentTaskExecutor:   &executor,
-				DownloadStatistic:    statistic,
-				IsPrintStatus:        options.IsPrintStatus,
-				IsExecutedPermission: options.IsExecutedPermission,
-				IsOverwrite:          options.IsOverwrite,
-				NoCheck:              options.NoCheck,
-				FilePanPath:          f.Path,
-				DriveId:              options.DriveId,
-				GlobalSpeedsStat:     globalSpeedsStat,
-				FileRecorder:         fileRecorder,
+			if !f.IsFolder() && utils.IsExcludeGlobFile(f.Path, cfg.ExcludeGlobs) {
+				statistic.AddExcludedCount(1)
+				continue
 			}
 
-			// 设置储存的路径
-			if options.SaveTo != "" {
-				unit.OriginSaveRootPath = options.SaveTo
-				unit.SavePath = filepath.Join(options.SaveTo, f.Path)
-			} else {
-				// 使用默认的保存路径
-				unit.OriginSaveRootPath = GetActiveUser().GetSavePath("")
-				unit.SavePath = GetActiveUser().GetSavePath(f.Path)
-			}
-			info := executor.Append(&unit, options.MaxRetry)
+			// 匹配的文件
+			info := executor.Append(newDownloadTaskUnit(f, &executor), options.MaxRetry)
 			fmt.Printf("[%s] 加入下载队列: %s\n", info.Id(), f.Path)
 		}
 	}
@@ -378,9 +1021,20 @@ func RunDownload(paths []string, options *DownloadOptions) {
 	executor.Execute()
 
 	fmt.Printf("\n下载结束, 时间: %s, 数据总量: %s\n", utils.ConvertTime(statistic.Elapsed()), converter.ConvertFileSize(statistic.TotalSize(), 2))
+	if len(options.ExcludeGlobs) > 0 {
+		fmt.Printf("排除的文件数量: %d\n", statistic.ExcludedCount())
+	}
+
+	if executor.Stopped() && executor.Count() > 0 {
+		fmt.Printf("下载已暂停, 剩余的 %d 个下载任务未开始\n", executor.Count())
+	}
+	if executor.Aborted() {
+		fmt.Printf("有文件下载失败, 已中止剩余的 %d 个下载任务, 使用 --continue-on-error 可以在失败后继续下载其他文件\n", executor.Count())
+	}
 
 	// 输出失败的文件列表
 	failedList := executor.FailedDeque()
+	failedCount = failedList.Size()
 	if failedList.Size() != 0 {
 		fmt.Printf("以下文件下载失败: \n")
 		tb := cmdtable.NewTable(os.Stdout)
@@ -390,4 +1044,192 @@ func RunDownload(paths []string, options *DownloadOptions) {
 		}
 		tb.Render()
 	}
+	return
+}
+
+// runDownloadQueue 展开目录中的文件后加入下载队列, 由下载队列限制同时下载的文件数量, 单个文件的下载线程数不变.
+// 每个文件使用单独的执行器运行下载任务, 保留任务的重试和失败处理, 返回下载失败的文件数量
+func runDownloadQueue(paths []string, options *DownloadOptions, cfg *downloader.Config, statistic *pandownload.DownloadStatistic,
+	newDownloadTaskUnit func(f *aliyunpan.FileEntity, parent *taskframework.TaskExecutor) *pandownload.DownloadTaskUnit,
+	downloadSavePath func(f *aliyunpan.FileEntity) string) int {
+	queue := downloader.NewDownloadQueue(options.MaxFiles, cfg, GetActivePanClient(), nil)
+	taskIds := &incremental.Int{} // 所有执行器共用, 任务id不重复
+	var stopped int32
+	queue.SetDownloadFunc(func(item *downloader.DownloadQueueItem) error {
+		if atomic.LoadInt32(&stopped) == 1 {
+			return fmt.Errorf("已取消")
+		}
+		executor := &taskframework.TaskExecutor{IsFailedDeque: true}
+		executor.SetIdGenerator(taskIds)
+		info := executor.Append(newDownloadTaskUnit(item.FileInfo, executor), options.MaxRetry)
+		fmt.Printf("[%s] 开始下载: %s\n", info.Id(), item.FileInfo.Path)
+		executor.Execute()
+		if executor.Stopped() {
+			// 下载被中断, 不再开始剩余的文件
+			atomic.StoreInt32(&stopped, 1)
+			return fmt.Errorf("下载已暂停")
+		}
+		if executor.FailedDeque().Size() > 0 {
+			if !options.ContinueOnError {
+				atomic.StoreInt32(&stopped, 1)
+			}
+			return fmt.Errorf("[%s] 下载失败", info.Id())
+		}
+		return nil
+	})
+
+	// 先开始队列, 边遍历目录边下载
+	statistic.StartTimer()
+	queue.Start()
+	excludedCount := walkDownloadFiles(paths, options, cfg, func(f *aliyunpan.FileEntity) {
+		queue.Add(f, downloadSavePath(f))
+		fmt.Printf("加入下载队列: %s\n", f.Path)
+	})
+	statistic.AddExcludedCount(int64(excludedCount))
+	result := queue.WaitAll()
+
+	fmt.Printf("\n下载结束, 时间: %s, 数据总量: %s\n", utils.ConvertTime(statistic.Elapsed()), converter.ConvertFileSize(statistic.TotalSize(), 2))
+	if len(options.ExcludeGlobs) > 0 {
+		fmt.Printf("排除的文件数量: %d\n", statistic.ExcludedCount())
+	}
+	if atomic.LoadInt32(&stopped) == 1 && !options.ContinueOnError {
+		fmt.Printf("已中止剩余的下载任务, 使用 --continue-on-error 可以在失败后继续下载其他文件\n")
+	}
+	return result.Failed
+}
+
+// walkDownloadFiles 遍历网盘目录，对每个将要下载的文件调用 fn，返回按通配符排除的文件数量
+func walkDownloadFiles(paths []string, options *DownloadOptions, cfg *downloader.Config, fn func(f *aliyunpan.FileEntity)) (excludedCount int) {
+	panClient := GetActivePanClient()
+	var walk func(f *aliyunpan.FileEntity)
+	walk = func(f *aliyunpan.FileEntity) {
+		if utils.IsExcludeFile(f.Path, &cfg.ExcludeNames) {
+			return
+		}
+		if f.IsFolder() {
+			fileList, apierr := panClient.OpenapiPanClient().FileListGetAll(&aliyunpan.FileListParam{
+				DriveId:      options.DriveId,
+				ParentFileId: f.FileId,
+			}, 1000)
+			if apierr != nil {
+				fmt.Printf("获取目录信息错误: %s, %s\n", f.Path, apierr)
+				return
+			}
+			for _, child := range fileList {
+				child.Path = path.Join(f.Path, child.FileName)
+				walk(child)
+			}
+			return
+		}
+		if utils.IsExcludeGlobFile(f.Path, cfg.ExcludeGlobs) {
+			excludedCount++
+			return
+		}
+		fn(f)
+	}
+
+	for _, p := range paths {
+		fileList, err := matchPathByShellPattern(options.DriveId, p)
+		if err != nil {
+			fmt.Printf("获取文件出错，请稍后重试: %s\n", p)
+			continue
+		}
+		if len(fileList) == 0 {
+			fmt.Printf("文件不存在: %s\n", p)
+			continue
+		}
+		for _, f := range fileList {
+			walk(f)
+		}
+	}
+	return
+}
+
+// downloadSavePath 文件在本地的保存路径
+func downloadSavePath(f *aliyunpan.FileEntity, options *DownloadOptions, saveRootPath string, flat bool) string {
+	savePath := filepath.Join(saveRootPath, f.Path)
+	if flat {
+		savePath = filepath.Join(saveRootPath, f.FileName)
+	}
+	if options.OutputDirPerDate {
+		savePath = pandownload.DateSavePath(saveRootPath, savePath, f.UpdatedAt)
+	}
+	return savePath
+}
+
+// runDownloadDryRun 遍历网盘目录，列出将要下载的文件和本地保存路径，不下载任何数据
+func runDownloadDryRun(paths []string, options *DownloadOptions, cfg *downloader.Config, saveRootPath string, flat bool) {
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "文件路径", "文件大小", "本地保存路径"})
+
+	var (
+		fileCount int
+		totalSize int64
+	)
+	excludedCount := walkDownloadFiles(paths, options, cfg, func(f *aliyunpan.FileEntity) {
+		fileCount++
+		totalSize += f.FileSize
+		tb.Append([]string{strconv.Itoa(fileCount), f.Path, converter.ConvertFileSize(f.FileSize, 2), downloadSavePath(f, options, saveRootPath, flat)})
+	})
+
+	tb.Render()
+	if len(cfg.ExcludeGlobs) > 0 {
+		fmt.Printf("排除的文件数量: %d\n", excludedCount)
+	}
+	fmt.Printf("dry-run: 共 %d 个文件, 预计下载数据总量: %s, 未下载任何数据\n", fileCount, converter.ConvertFileSize(totalSize, 2))
+}
+
+// runDownloadSaveUrls 遍历网盘目录，获取每个文件的下载链接，以 aria2c 输入文件的格式追加到 urlFile，不下载任何数据
+func runDownloadSaveUrls(paths []string, options *DownloadOptions, cfg *downloader.Config, saveRootPath string, flat bool, urlFile string) (failedCount int) {
+	file, err := os.OpenFile(urlFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Printf("打开下载链接文件失败: %s\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	panClient := GetActivePanClient()
+	fileCount := 0
+	walkDownloadFiles(paths, options, cfg, func(f *aliyunpan.FileEntity) {
+		durl, apierr := panClient.OpenapiPanClient().GetFileDownloadUrl(&aliyunpan.GetFileDownloadUrlParam{
+			DriveId: options.DriveId,
+			FileId:  f.FileId,
+		})
+		if apierr != nil || durl == nil || durl.Url == "" || strings.HasPrefix(durl.Url, aliyunpan.IllegalDownloadUrlPrefix) {
+			fmt.Printf("获取下载链接失败: %s, %v\n", f.Path, apierr)
+			failedCount++
+			return
+		}
+		out, err := filepath.Rel(saveRootPath, downloadSavePath(f, options, saveRootPath, flat))
+		if err != nil {
+			out = f.FileName
+		}
+		// aria2c 输入文件格式: 链接一行, 之后以空白开头的行为该链接的选项
+		if _, err = fmt.Fprintf(file, "%s\n  out=%s\n  header=Referer: %s\n", durl.Url, filepath.ToSlash(out), aliyunpanReferer); err != nil {
+			fmt.Printf("写入下载链接文件失败: %s\n", err)
+			failedCount++
+			return
+		}
+		fileCount++
+		fmt.Printf("[%d] %s\n", fileCount, f.Path)
+	})
+	fmt.Printf("共保存 %d 个文件的下载链接到: %s, 下载链接有效期较短，请尽快下载\n", fileCount, urlFile)
+	return
+}
+
+// parseSlowSpeedOption 解析 速度,时长 格式的参数，例如 50KB,2m
+func parseSlowSpeedOption(value string) (downloader.SlowSpeedConfig, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return downloader.SlowSpeedConfig{}, errors.New("格式应为 速度,时长，例如 50KB,2m")
+	}
+	rate, err := converter.ParseFileSizeStr(strings.TrimSpace(parts[0]))
+	if err != nil || rate <= 0 {
+		return downloader.SlowSpeedConfig{}, fmt.Errorf("无效的速度: %s", parts[0])
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || duration <= 0 {
+		return downloader.SlowSpeedConfig{}, fmt.Errorf("无效的时长: %s", parts[1])
+	}
+	return downloader.SlowSpeedConfig{Rate: rate, Duration: duration}, nil
 }
@@ -0,0 +1,150 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan/cmder"
+	"github.com/tickstep/aliyunpan/cmder/cmdtable"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/library-go/converter"
+	"github.com/urfave/cli"
+	"os"
+	"strconv"
+)
+
+func CmdFile() cli.Command {
+	return cli.Command{
+		Name:      "file",
+		Usage:     "文件管理",
+		UsageText: cmder.App().Name + " file <子命令>",
+		Description: `
+	示例:
+
+	显示 /我的文档/1.mp4 的详细信息，包括文件ID、网盘ID、内容Hash、大小、创建和修改时间、分类
+	aliyunpan file info /我的文档/1.mp4
+
+	以JSON格式输出文件的详细信息
+	aliyunpan file info -json /我的文档/1.mp4
+`,
+		Category: "阿里云盘",
+		Before:   ReloadConfigFunc,
+		Action: func(c *cli.Context) error {
+			cli.ShowCommandHelp(c, c.Command.Name)
+			return nil
+		},
+		Subcommands: []cli.Command{
+			{
+				Name:      "info",
+				Usage:     "显示文件/目录的详细信息",
+				UsageText: cmder.App().Name + " file info <文件/目录>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						cli.ShowCommandHelp(c, c.Command.Name)
+						return nil
+					}
+					if config.Config.ActiveUser() == nil {
+						fmt.Println("未登录账号")
+						return nil
+					}
+					RunFileInfo(parseDriveId(c), c.Args().Get(0), c.Bool("json"))
+					return nil
+				},
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "driveId",
+						Usage: "网盘ID",
+						Value: "",
+					},
+					cli.BoolFlag{
+						Name:  "json",
+						Usage: "以JSON格式输出文件的详细信息，便于脚本处理",
+					},
+				},
+			},
+		},
+	}
+}
+
+// RunFileInfo 显示文件/目录的详细信息
+func RunFileInfo(driveId, filePath string, jsonOutput bool) {
+	activeUser := GetActiveUser()
+	fullPath := activeUser.PathJoin(driveId, filePath)
+	fileInfo, err := activeUser.PanClient().OpenapiPanClient().FileInfoByPath(driveId, fullPath)
+	if err != nil {
+		fmt.Printf("获取文件信息失败: %s, %s\n", fullPath, err)
+		return
+	}
+	if fileInfo.Path == "" {
+		fileInfo.Path = fullPath
+	}
+
+	if jsonOutput {
+		data, er := json.MarshalIndent(fileInfo, "", "  ")
+		if er != nil {
+			fmt.Printf("生成JSON失败: %s\n", er)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"名称", "值"})
+	tb.AppendBulk(fileInfoRows(fileInfo))
+	tb.Render()
+}
+
+// fileInfoRows 文件详细信息的显示内容
+func fileInfoRows(f *aliyunpan.FileEntity) [][]string {
+	fileType := "文件"
+	if f.IsFolder() {
+		fileType = "目录"
+	}
+	size := "-"
+	if !f.IsFolder() {
+		size = converter.ConvertFileSize(f.FileSize, 2) + " (" + strconv.FormatInt(f.FileSize, 10) + " 字节)"
+	}
+	rows := [][]string{
+		{"文件名", f.FileName},
+		{"路径", f.Path},
+		{"类型", fileType},
+		{"大小", size},
+		{"文件ID", f.FileId},
+		{"网盘ID", f.DriveId},
+		{"父目录ID", f.ParentFileId},
+		{"创建时间", f.CreatedAt},
+		{"修改时间", f.UpdatedAt},
+		{"分类", f.Category},
+		{"扩展名", f.FileExtension},
+	}
+	if !f.IsFolder() {
+		rows = append(rows,
+			[]string{"内容Hash(" + f.ContentHashName + ")", f.ContentHash},
+			[]string{"CRC64", f.Crc64Hash},
+		)
+	}
+	if f.DomainId != "" {
+		rows = append(rows, []string{"域ID", f.DomainId})
+	}
+	if f.UploadId != "" {
+		rows = append(rows, []string{"上传ID", f.UploadId})
+	}
+	if f.SyncFlag {
+		rows = append(rows, []string{"同步盘信息", f.SyncMeta})
+	}
+	return rows
+}
@@ -15,6 +15,7 @@ package command
 
 import (
 	"fmt"
+	"github.com/skip2/go-qrcode"
 	"github.com/tickstep/aliyunpan/cmder/cmdliner"
 	"github.com/tickstep/aliyunpan/internal/config"
 	"github.com/tickstep/aliyunpan/internal/functions/panlogin"
@@ -22,6 +23,14 @@ import (
 	_ "github.com/tickstep/library-go/requester"
 	"github.com/urfave/cli"
 	"strings"
+	"time"
+)
+
+const (
+	// QRLoginPollInterval 二维码登录轮询扫码状态的间隔
+	QRLoginPollInterval = 2 * time.Second
+	// QRLoginTimeout 二维码登录等待扫码的最长时间
+	QRLoginTimeout = 5 * time.Minute
 )
 
 func CmdLogin() cli.Command {
@@ -33,6 +42,9 @@ func CmdLogin() cli.Command {
 		1.常规登录，按提示一步一步来即可
 		aliyunpan login
 
+		2.二维码登录，在终端显示登录二维码，使用阿里云盘App扫码即可，不需要再按Enter键确认
+		aliyunpan login -qr
+
 `,
 		Category: "阿里云盘账号",
 		Before:   ReloadConfigFunc, // 每次进行登录动作的时候需要调用刷新配置
@@ -42,7 +54,11 @@ func CmdLogin() cli.Command {
 			openToken := &config.PanClientToken{}
 			webToken := &config.PanClientToken{}
 			var err error
-			ticketId, openToken, webToken, err = RunLogin()
+			if c.Bool("qr") {
+				ticketId, openToken, webToken, err = RunLoginQR()
+			} else {
+				ticketId, openToken, webToken, err = RunLogin()
+			}
 			if err != nil {
 				fmt.Println(err)
 				return err
@@ -62,7 +78,12 @@ func CmdLogin() cli.Command {
 			return nil
 		},
 		// 命令的附加options参数说明，使用 help panlogin 命令即可查看
-		Flags: []cli.Flag{},
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "qr",
+				Usage: "在终端显示登录二维码，使用阿里云盘App扫码登录",
+			},
+		},
 	}
 }
 
@@ -129,6 +150,57 @@ func RunLogin() (ticketId string, openapiToken, webapiToken *config.PanClientTok
 		return "", nil, nil, err
 	}
 	ticketId = qrCodeUrlResult.TokenId
+	loginUrl := openapiLoginUrl(ticketId)
+	fmt.Printf("请在浏览器打开以下链接进行登录，链接有效时间为5分钟。\n注意：你需要进行一次授权一次扫码的两次登录。\n%s\n\n", loginUrl)
+
+	// handler waiting
+	line := cmdliner.NewLiner()
+	defer line.Close()
+	line.State.Prompt("请在浏览器里面完成扫码登录，然后再按Enter键继续...")
+
+	return getLoginToken(h, ticketId)
+}
+
+// RunLoginQR 在终端显示登录二维码，轮询扫码状态直到用户使用阿里云盘App扫码确认
+func RunLoginQR() (ticketId string, openapiToken, webapiToken *config.PanClientToken, error error) {
+	h := panlogin.NewLoginHelper(config.DefaultTokenServiceWebHost)
+
+	qrCodeUrlResult, err := h.GetQRCodeLoginUrl("")
+	if err != nil {
+		fmt.Println("登录出错：", err)
+		return "", nil, nil, err
+	}
+	ticketId = qrCodeUrlResult.TokenId
+	loginUrl := openapiLoginUrl(ticketId)
+
+	qr, err := qrcode.New(loginUrl, qrcode.Low)
+	if err != nil {
+		return ticketId, nil, nil, fmt.Errorf("生成登录二维码失败: %s", err)
+	}
+	fmt.Printf("请使用阿里云盘App扫描以下二维码进行登录，二维码有效时间为%s。\n", QRLoginTimeout)
+	fmt.Println(qr.ToSmallString(false))
+	fmt.Printf("如果二维码显示不完整，也可以在浏览器打开以下链接进行登录：\n%s\n\n", loginUrl)
+
+	deadline := time.Now().Add(QRLoginTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(QRLoginPollInterval)
+		r, er := h.GetQRCodeLoginResult(ticketId)
+		if er != nil {
+			// 网络波动等临时错误，继续轮询
+			continue
+		}
+		switch r.QrCodeStatus {
+		case "CONFIRMED":
+			return getLoginToken(h, ticketId)
+		case "EXPIRED":
+			return ticketId, nil, nil, fmt.Errorf("二维码已过期，请重新登录")
+		}
+	}
+	return ticketId, nil, nil, fmt.Errorf("等待扫码超时，请重新登录")
+}
+
+// openapiLoginUrl 获取 ticketId 对应的授权登录链接
+func openapiLoginUrl(ticketId string) string {
 	loginUrl := &strings.Builder{}
 	if global.IsSupportNoneOpenApiCommands {
 		// 兼容以前的版本
@@ -138,14 +210,11 @@ func RunLogin() (ticketId string, openapiToken, webapiToken *config.PanClientTok
 		fmt.Fprintf(loginUrl, "https://openapi.alipan.com/oauth/authorize?client_id=%s&redirect_uri=https%%3A%%2F%%2Fapi.tickstep.com%%2Fauth%%2Ftickstep%%2Faliyunpan%%2Ftoken%%2Fopenapi%%2F%s%%2Fauth2&scope=user:base,file:all:read,file:all:write",
 			config.Config.ClientId, ticketId)
 	}
-	fmt.Printf("请在浏览器打开以下链接进行登录，链接有效时间为5分钟。\n注意：你需要进行一次授权一次扫码的两次登录。\n%s\n\n", loginUrl)
-
-	// handler waiting
-	line := cmdliner.NewLiner()
-	defer line.Close()
-	line.State.Prompt("请在浏览器里面完成扫码登录，然后再按Enter键继续...")
+	return loginUrl.String()
+}
 
-	// get login token
+// getLoginToken 登录完成后获取 Openapi 和 Webapi 的Token
+func getLoginToken(h *panlogin.LoginHelper, ticketId string) (string, *config.PanClientToken, *config.PanClientToken, error) {
 	comToken, er := h.GetLoginToken(ticketId)
 	if er != nil {
 		return ticketId, nil, nil, fmt.Errorf("登录失败，请稍后尝试重新登录")
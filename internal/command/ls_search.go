@@ -23,7 +23,9 @@ import (
 	"github.com/tickstep/library-go/converter"
 	"github.com/urfave/cli"
 	"os"
+	"path"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -44,6 +46,22 @@ const (
 	opSearch
 )
 
+const (
+	// DefaultSearchLimit 默认最多显示的搜索结果数量
+	DefaultSearchLimit = 100
+)
+
+var (
+	// searchFileTypes 搜索支持的文件类型, 对应网盘文件的分类
+	searchFileTypes = map[string]bool{
+		"all":   true,
+		"image": true,
+		"video": true,
+		"audio": true,
+		"doc":   true,
+	}
+)
+
 func CmdLs() cli.Command {
 	return cli.Command{
 		Name:      "ls",
@@ -136,6 +154,128 @@ func CmdLs() cli.Command {
 	}
 }
 
+func CmdSearch() cli.Command {
+	return cli.Command{
+		Name:      "search",
+		Usage:     "搜索文件",
+		UsageText: cmder.App().Name + " search [arguments...] <关键字>",
+		Description: `
+	在当前工作目录下递归搜索文件名包含关键字的文件和目录, 关键字不区分大小写
+
+	示例:
+
+	搜索文件名包含 报告 的文件和目录
+	aliyunpan search 报告
+
+	只搜索视频文件, 最多显示20个结果
+	aliyunpan search -type video -limit 20 2023
+
+	先切换到 /我的资源 再搜索, 缩小搜索范围
+	aliyunpan cd /我的资源
+	aliyunpan search -type image 封面
+`,
+		Category: "阿里云盘",
+		Before:   ReloadConfigFunc,
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				cli.ShowCommandHelp(c, c.Command.Name)
+				return nil
+			}
+			if config.Config.ActiveUser() == nil {
+				fmt.Println("未登录账号")
+				return nil
+			}
+			fileType := c.String("type")
+			if !searchFileTypes[fileType] {
+				fmt.Printf("不支持的文件类型: %s\n", fileType)
+				return nil
+			}
+			if c.Int("limit") < 0 {
+				fmt.Println("搜索结果数量不能小于0")
+				return nil
+			}
+			RunFileSearch(parseDriveId(c), c.Args().Get(0), fileType, c.Int("limit"))
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "type",
+				Usage: "文件类型: image-图片, video-视频, audio-音频, doc-文档, all-全部",
+				Value: "all",
+			},
+			cli.IntFlag{
+				Name:  "limit",
+				Usage: "最多显示的搜索结果数量, 0代表不限制",
+				Value: DefaultSearchLimit,
+			},
+			cli.StringFlag{
+				Name:  "driveId",
+				Usage: "网盘ID",
+				Value: "",
+			},
+		},
+	}
+}
+
+// RunFileSearch 在当前工作目录下递归搜索文件名包含 keyword 的文件, 最多显示 maxResults 个结果, 0代表不限制
+func RunFileSearch(driveId, keyword, fileType string, maxResults int) {
+	activeUser := config.Config.ActiveUser()
+	searchPath := activeUser.PathJoin(driveId, "")
+	searchPathInfo, err := activeUser.PanClient().OpenapiPanClient().FileInfoByPath(driveId, searchPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	keyword = strings.ToLower(keyword)
+	results := aliyunpan.FileList{}
+	queue := []*aliyunpan.FileEntity{searchPathInfo}
+	for len(queue) > 0 && (maxResults <= 0 || len(results) < maxResults) {
+		dir := queue[0]
+		queue = queue[1:]
+		fileList, err1 := activeUser.PanClient().OpenapiPanClient().FileListGetAll(&aliyunpan.FileListParam{
+			DriveId:      driveId,
+			ParentFileId: dir.FileId,
+		}, 500)
+		if err1 != nil {
+			fmt.Printf("获取目录信息错误: %s, %s\n", dir.Path, err1)
+			continue
+		}
+		for _, file := range fileList {
+			file.Path = path.Join(dir.Path, file.FileName)
+			if file.IsFolder() {
+				queue = append(queue, file)
+			}
+			if !strings.Contains(strings.ToLower(file.FileName), keyword) {
+				continue
+			}
+			if fileType != "all" && (file.IsFolder() || file.Category != fileType) {
+				continue
+			}
+			results = append(results, file)
+			if maxResults > 0 && len(results) >= maxResults {
+				break
+			}
+		}
+	}
+
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "路径", "文件大小", "修改日期", "文件类型"})
+	tb.SetColumnAlignment([]int{tablewriter.ALIGN_DEFAULT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
+	for k, file := range results {
+		if file.IsFolder() {
+			tb.Append([]string{strconv.Itoa(k + 1), file.Path + aliyunpan.PathSeparator, "-", file.UpdatedAt, "目录"})
+			continue
+		}
+		tb.Append([]string{strconv.Itoa(k + 1), file.Path, converter.ConvertFileSize(file.FileSize, 2), file.UpdatedAt, file.Category})
+	}
+	fmt.Printf("\n搜索目录: %s\n", searchPathInfo.Path)
+	fmt.Printf("----\n")
+	tb.Render()
+	fmt.Printf("----\n")
+	fmt.Printf("共找到 %d 个结果\n", len(results))
+}
+
 func RunLs(driveId, targetPath string, lsOptions *LsOptions,
 	orderBy aliyunpan.FileOrderBy, orderDirection aliyunpan.FileOrderDirection) {
 	activeUser := config.Config.ActiveUser()
@@ -0,0 +1,85 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ProxyTestUrl 测试代理连通性请求的地址
+	ProxyTestUrl = "https://openapi.alipan.com"
+
+	// ProxyTestTimeout 测试代理连通性的超时时间
+	ProxyTestTimeout = 15 * time.Second
+)
+
+// RunProxyList 显示当前配置的代理，并测试连通性
+func RunProxyList() {
+	proxy := config.Config.Proxy
+	if proxy == "" {
+		fmt.Println("当前代理: 未设置")
+	} else {
+		fmt.Printf("当前代理: %s\n", proxy)
+	}
+	RunProxyTest(proxy)
+}
+
+// RunProxyTest 测试通过指定代理访问阿里云盘API服务器，代理为空则直接连接
+func RunProxyTest(proxy string) {
+	if proxy == "" {
+		fmt.Printf("测试直接连接: %s\n", ProxyTestUrl)
+	} else {
+		fmt.Printf("测试代理 %s 连接: %s\n", proxy, ProxyTestUrl)
+	}
+	status, latency, err := testProxyConnectivity(proxy, ProxyTestUrl)
+	if err != nil {
+		fmt.Printf("连接失败: %s\n", err)
+		return
+	}
+	fmt.Printf("连接成功, 响应状态: %s, 延迟: %s\n", status, latency.Round(time.Millisecond))
+}
+
+// testProxyConnectivity 通过代理请求目标地址，返回响应状态和耗时
+func testProxyConnectivity(proxy, targetUrl string) (status string, latency time.Duration, err error) {
+	transport := &http.Transport{}
+	if proxy != "" {
+		if !strings.Contains(proxy, "://") {
+			proxy = "http://" + proxy
+		}
+		proxyUrl, e := url.Parse(proxy)
+		if e != nil {
+			return "", 0, fmt.Errorf("代理地址格式错误: %s", e)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   ProxyTestTimeout,
+	}
+	defer transport.CloseIdleConnections()
+
+	start := time.Now()
+	resp, err := client.Get(targetUrl)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	return resp.Status, time.Since(start), nil
+}
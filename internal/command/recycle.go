@@ -31,8 +31,9 @@ import (
 
 func CmdRecycle() cli.Command {
 	return cli.Command{
-		Name:  "recycle",
-		Usage: "回收站",
+		Name:    "recycle",
+		Aliases: []string{"trash"},
+		Usage:   "回收站",
 		Description: `
 	回收站操作.
 
@@ -46,6 +47,9 @@ func CmdRecycle() cli.Command {
 
 	3. 清空回收站, 程序不会进行二次确认, 谨慎操作!!!
 	aliyunpan recycle delete -all
+
+	4. trash 是 recycle 的别名, 列出回收站文件列表
+	aliyunpan trash list
 `,
 		Category: "阿里云盘",
 		Before:   ReloadConfigFunc,
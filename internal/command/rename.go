@@ -80,10 +80,53 @@ func CmdRename() cli.Command {
 
     5. 批量重命名，将当前目录下所有.mp4文件全部进行 "视频+编号.mp4" 的重命名操作，旧的名称全部去掉，直接重命名无需人工确认操作
     aliyunpan rename -y * 视频###.mp4 *.mp4
+
+    模板批量重命名，规则：rename -replacement <模板> [-pattern <正则表达式>] <文件1> <文件2> ...
+    其中，
+    replacement - 新文件名模板，支持以下占位符：
+        {index}    按文件名排序后的编号，从1开始。{index:3} 代表不足3位时前面补0，例如 001
+        {original} 原文件名，不包括扩展名
+        {ext}      原文件的扩展名，包括 . 号，例如 .mp4
+    pattern - 正则表达式，只替换文件名中匹配的部分。不指定则整个文件名替换为模板
+    文件 - 需要重命名的文件，支持通配符，可以指定多个
+
+    6. 将 /电视剧 目录下所有.mp4文件重命名为 "第001集.mp4" 格式
+    aliyunpan rename -replacement "第{index:3}集{ext}" /电视剧/*.mp4
+
+    7. 将当前目录下所有.jpg和.png文件的文件名中的 "IMG_" 替换为 "旅行_编号_"
+    aliyunpan rename -pattern "^IMG_" -replacement "旅行_{index}_" *.jpg *.png
+
+    8. 根据文件ID重命名文件
+    aliyunpan rename -fileId 62f5f9e3c7d4e86b5f0d4c3da0d8c2f1d1c6e8a9 新文件名.mp4
 `,
 		Category: "阿里云盘",
 		Before:   ReloadConfigFunc,
 		Action: func(c *cli.Context) error {
+			if c.String("replacement") != "" {
+				// 模板批量重命名
+				if c.NArg() < 1 {
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
+				if config.Config.ActiveUser() == nil {
+					fmt.Println("未登录账号")
+					return nil
+				}
+				RunFileBatchRename(c.Bool("y"), parseDriveId(c), c.String("pattern"), c.String("replacement"), c.Args())
+				return nil
+			}
+			if c.String("fileId") != "" {
+				if c.NArg() != 1 {
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
+				if config.Config.ActiveUser() == nil {
+					fmt.Println("未登录账号")
+					return nil
+				}
+				RunFileRename(parseDriveId(c), c.String("fileId"), c.Args().Get(0))
+				return nil
+			}
 			if c.NArg() != 2 && c.NArg() != 3 {
 				cli.ShowCommandHelp(c, c.Command.Name)
 				return nil
@@ -110,6 +153,21 @@ func CmdRename() cli.Command {
 				Name:  "y",
 				Usage: "跳过人工确认，对批量操作有效",
 			},
+			cli.StringFlag{
+				Name:  "replacement",
+				Usage: "模板批量重命名的新文件名模板，支持 {index} {index:3} {original} {ext} 占位符",
+				Value: "",
+			},
+			cli.StringFlag{
+				Name:  "pattern",
+				Usage: "模板批量重命名时只替换文件名中匹配该正则表达式的部分，不指定则替换整个文件名",
+				Value: "",
+			},
+			cli.StringFlag{
+				Name:  "fileId",
+				Usage: "根据文件ID重命名文件",
+				Value: "",
+			},
 		},
 	}
 }
@@ -156,6 +214,44 @@ func RunRename(driveId string, oldName string, newName string) {
 	activeUser.DeleteOneCache(path.Dir(newName))
 }
 
+// RunFileRename 根据文件ID重命名文件
+func RunFileRename(driveId, fileId, newName string) {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		fmt.Println("请指定文件新名称")
+		return
+	}
+	if strings.ContainsAny(newName, "/\\") {
+		fmt.Println("新文件名不能包含路径分隔符")
+		return
+	}
+	if !apiutil.CheckFileNameValid(newName) {
+		fmt.Println("文件名不能包含特殊字符：" + apiutil.FileNameSpecialChars)
+		return
+	}
+
+	activeUser := GetActiveUser()
+	fileInfo, err := activeUser.PanClient().OpenapiPanClient().FileInfoById(driveId, fileId)
+	if err != nil {
+		fmt.Printf("文件不存在： %s, %s\n", fileId, err)
+		return
+	}
+
+	b, e := activeUser.PanClient().OpenapiPanClient().FileRename(driveId, fileId, newName)
+	if e != nil {
+		fmt.Println(e.Err)
+		return
+	}
+	if !b {
+		fmt.Println("重命名文件失败")
+		return
+	}
+	fmt.Printf("重命名文件成功：%s -> %s\n", fileInfo.FileName, newName)
+	if fileInfo.Path != "" {
+		activeUser.DeleteOneCache(path.Dir(fileInfo.Path))
+	}
+}
+
 // RunRenameBatch 批量重命名文件
 func RunRenameBatch(skipConfirm bool, driveId string, expression, replacement, filePattern string) {
 	if len(expression) == 0 {
@@ -248,6 +344,125 @@ func RunRenameBatch(skipConfirm bool, driveId string, expression, replacement, f
 	}
 }
 
+// RunFileBatchRename 按照模板批量重命名文件, paths 支持通配符.
+// pattern 为空则整个文件名替换为模板, 否则只替换文件名中匹配正则表达式的部分
+func RunFileBatchRename(skipConfirm bool, driveId string, pattern, replacement string, paths []string) {
+	var re *regexp.Regexp
+	if pattern != "" {
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			fmt.Printf("正则表达式错误: %s\n", err)
+			return
+		}
+	}
+
+	// 查找所有匹配的文件, 同一个文件只处理一次
+	activeUser := GetActiveUser()
+	files := fileArray{}
+	fileIds := map[string]bool{}
+	for _, p := range paths {
+		absolutePath := path.Clean(activeUser.PathJoin(driveId, p))
+		fileList, err := matchPathByShellPattern(driveId, absolutePath)
+		if err != nil {
+			fmt.Printf("查询文件出错：%s, %s\n", p, err)
+			return
+		}
+		for _, f := range fileList {
+			if fileIds[f.FileId] {
+				continue
+			}
+			if re != nil && !re.MatchString(f.FileName) {
+				continue
+			}
+			fileIds[f.FileId] = true
+			files = append(files, newFileItem(f))
+		}
+	}
+	if len(files) == 0 {
+		fmt.Println("没有找到符合的文件")
+		return
+	}
+	if len(files) > 1 {
+		sort.Sort(files)
+	}
+
+	// 生成新文件名
+	newPaths := map[string]bool{}
+	for k, file := range files {
+		newName := expandRenameTemplate(replacement, k+1, file.file.FileName)
+		if re != nil {
+			newName = re.ReplaceAllLiteralString(file.file.FileName, newName)
+		}
+		if newName == "" || !apiutil.CheckFileNameValid(newName) || strings.ContainsAny(newName, "/\\") {
+			fmt.Printf("新文件名不合法：%s -> %s\n", file.file.FileName, newName)
+			return
+		}
+		newPath := path.Join(path.Dir(file.file.Path), newName)
+		if newPaths[newPath] {
+			fmt.Printf("新文件名重复：%s，请在模板中使用 {index} 编号\n", newPath)
+			return
+		}
+		newPaths[newPath] = true
+		file.newFileName = newName
+	}
+
+	// 确认
+	if !skipConfirm {
+		fmt.Printf("以下文件将进行对应的重命名\n\n")
+		for k, file := range files {
+			fmt.Printf("%d) %s -> %s\n", k+1, file.file.Path, file.newFileName)
+		}
+		fmt.Printf("\n是否进行批量重命名，该操作不可逆(y/n): ")
+		confirm := ""
+		_, err := fmt.Scanln(&confirm)
+		if err != nil || (confirm != "y" && confirm != "Y") {
+			fmt.Println("用户取消了操作")
+			return
+		}
+	}
+
+	// 重命名
+	for _, file := range files {
+		if file.newFileName == file.file.FileName {
+			continue
+		}
+		b, e := activeUser.PanClient().OpenapiPanClient().FileRename(driveId, file.file.FileId, file.newFileName)
+		if e != nil {
+			fmt.Println(e.Err)
+			return
+		}
+		if !b {
+			fmt.Println("重命名文件失败")
+			return
+		}
+		fmt.Printf("重命名文件成功：%s -> %s\n", file.file.FileName, file.newFileName)
+		activeUser.DeleteOneCache(path.Dir(file.file.Path))
+	}
+}
+
+var renameTemplateRE = regexp.MustCompile(`\{(index(?::(\d+))?|original|ext)\}`)
+
+// expandRenameTemplate 替换新文件名模板中的占位符: {index} {index:3} {original} {ext}
+func expandRenameTemplate(template string, index int, fileName string) string {
+	ext := path.Ext(fileName)
+	original := strings.TrimSuffix(fileName, ext)
+	return renameTemplateRE.ReplaceAllStringFunc(template, func(s string) string {
+		m := renameTemplateRE.FindStringSubmatch(s)
+		switch {
+		case m[1] == "original":
+			return original
+		case m[1] == "ext":
+			return ext
+		case m[2] != "":
+			width, _ := strconv.Atoi(m[2])
+			return generateNumStr(index, width)
+		default:
+			return strconv.Itoa(index)
+		}
+	})
+}
+
 // replaceNumStr 将#替换成数字编号
 func replaceNumStr(name string, num int) string {
 	pattern, _ := regexp.Compile("[#]+")
@@ -39,3 +39,23 @@ func TestRenameNum4(t *testing.T) {
 func TestRenameNum5(t *testing.T) {
 	fmt.Println(replaceNumStr("", 1233))
 }
+
+func TestExpandRenameTemplate(t *testing.T) {
+	cases := []struct {
+		template string
+		index    int
+		fileName string
+		want     string
+	}{
+		{"第{index:3}集{ext}", 2, "abc.mp4", "第002集.mp4"},
+		{"{original}_{index}{ext}", 12, "我的视频.mp4", "我的视频_12.mp4"},
+		{"{original}-备份", 1, "README", "README-备份"},
+		{"{index:1}{ext}", 123, "a.tar.gz", "123.gz"},
+		{"{unknown}{ext}", 1, "a.txt", "{unknown}.txt"},
+	}
+	for _, c := range cases {
+		if got := expandRenameTemplate(c.template, c.index, c.fileName); got != c.want {
+			t.Errorf("expandRenameTemplate(%q, %d, %q) = %q, want %q", c.template, c.index, c.fileName, got, c.want)
+		}
+	}
+}
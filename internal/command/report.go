@@ -0,0 +1,410 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan/cmder"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/library-go/converter"
+	"github.com/urfave/cli"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ReportCssFileName 不内嵌样式时，样式文件保存在报告同目录下的文件名
+	ReportCssFileName = "aliyunpan-report.css"
+	// ReportTopFilesNum 报告中显示的最大文件数量
+	ReportTopFilesNum = 10
+	// ReportRecentUploadsNum 报告中显示的最近上传记录数量
+	ReportRecentUploadsNum = 20
+	// ReportDownloadHistoryDays 报告中显示的下载记录天数
+	ReportDownloadHistoryDays = 7
+	// ReportMaxScanFiles 统计最大文件时最多扫描的文件数量
+	ReportMaxScanFiles = 50000
+)
+
+type (
+	// ReportOptions 生成报告可选参数
+	ReportOptions struct {
+		EmbedCss bool // 将样式内嵌到HTML中，生成单个自包含的报告文件
+	}
+
+	// ReportData 报告数据
+	ReportData struct {
+		GeneratedAt     string
+		EmbedCss        bool
+		Css             template.CSS
+		CssFileName     string
+		UserId          string
+		Nickname        string
+		AccountName     string
+		DriveName       string
+		QuotaUsed       string
+		QuotaTotal      string
+		QuotaPercent    string
+		TopFiles        []*ReportFileItem
+		ScanTruncated   bool
+		RecentUploads   []*ReportRecordItem
+		DownloadHistory []*ReportRecordItem
+		ActiveShares    []*ReportShareItem
+		SharesError     string
+	}
+
+	// ReportFileItem 报告中的网盘文件
+	ReportFileItem struct {
+		Path      string
+		Size      string
+		UpdatedAt string
+		size      int64
+	}
+
+	// ReportRecordItem 报告中的上传/下载记录
+	ReportRecordItem struct {
+		Status string
+		Time   string
+		Size   string
+		Path   string
+	}
+
+	// ReportShareItem 报告中的有效分享
+	ReportShareItem struct {
+		ShareId    string
+		Name       string
+		Url        string
+		Expiration string
+	}
+)
+
+const reportCss = `body { font-family: -apple-system, "Segoe UI", "Microsoft YaHei", sans-serif; margin: 2em auto; max-width: 1100px; color: #333; }
+h1 { border-bottom: 2px solid #4a90e2; padding-bottom: .3em; }
+h2 { margin-top: 1.8em; color: #4a90e2; }
+table { border-collapse: collapse; width: 100%; font-size: 14px; }
+th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: left; word-break: break-all; }
+th { background: #f5f7fa; }
+tr:nth-child(even) td { background: #fafafa; }
+.meta { color: #888; font-size: 13px; }
+.bar { background: #eee; border-radius: 4px; height: 12px; width: 300px; }
+.bar span { background: #4a90e2; border-radius: 4px; display: block; height: 12px; }
+.empty { color: #888; }
+`
+
+const reportTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>阿里云盘账号报告 - {{.Nickname}}</title>
+{{if .EmbedCss}}<style>
+{{.Css}}</style>{{else}}<link rel="stylesheet" href="{{.CssFileName}}">{{end}}
+</head>
+<body>
+<h1>阿里云盘账号报告</h1>
+<p class="meta">生成时间: {{.GeneratedAt}}</p>
+
+<h2>账号信息</h2>
+<table>
+<tr><th>UID</th><td>{{.UserId}}</td></tr>
+<tr><th>昵称</th><td>{{.Nickname}}</td></tr>
+<tr><th>用户名</th><td>{{.AccountName}}</td></tr>
+<tr><th>当前网盘</th><td>{{.DriveName}}</td></tr>
+</table>
+
+<h2>空间配额</h2>
+<p>已使用 {{.QuotaUsed}} / 总空间 {{.QuotaTotal}} ({{.QuotaPercent}}%)</p>
+<div class="bar"><span style="width: {{.QuotaPercent}}%"></span></div>
+
+<h2>最大的{{len .TopFiles}}个文件</h2>
+{{if .ScanTruncated}}<p class="meta">文件数量过多，只统计了部分文件</p>{{end}}
+{{if .TopFiles}}<table>
+<tr><th>#</th><th>文件路径</th><th>大小</th><th>修改时间</th></tr>
+{{range $i, $f := .TopFiles}}<tr><td>{{inc $i}}</td><td>{{$f.Path}}</td><td>{{$f.Size}}</td><td>{{$f.UpdatedAt}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">没有文件</p>{{end}}
+
+<h2>最近上传</h2>
+{{template "records" .RecentUploads}}
+
+<h2>下载记录(最近7天)</h2>
+{{template "records" .DownloadHistory}}
+
+<h2>有效分享</h2>
+{{if .SharesError}}<p class="empty">{{.SharesError}}</p>{{else if .ActiveShares}}<table>
+<tr><th>分享ID</th><th>名称</th><th>分享链接</th><th>过期时间</th></tr>
+{{range .ActiveShares}}<tr><td>{{.ShareId}}</td><td>{{.Name}}</td><td><a href="{{.Url}}">{{.Url}}</a></td><td>{{.Expiration}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">没有有效的分享</p>{{end}}
+</body>
+</html>
+{{define "records"}}{{if .}}<table>
+<tr><th>状态</th><th>时间</th><th>文件大小</th><th>文件路径</th></tr>
+{{range .}}<tr><td>{{.Status}}</td><td>{{.Time}}</td><td>{{.Size}}</td><td>{{.Path}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">没有记录，开启文件记录配置(config set -file_record_config 1)后才会记录</p>{{end}}{{end}}
+`
+
+func CmdReport() cli.Command {
+	return cli.Command{
+		Name:      "report",
+		Usage:     "生成网盘账号状态的HTML报告",
+		UsageText: cmder.App().Name + " report <保存的HTML文件路径>",
+		Description: `
+	生成当前账号的HTML报告，包括账号信息、空间配额、最大的10个文件、最近上传、最近7天的下载记录以及有效的分享。
+	上传和下载记录来自本地的文件记录，需要开启文件记录配置(config set -file_record_config 1)。
+	有效分享需要WEB客户端登录。
+
+	示例:
+
+	生成报告，样式文件保存在报告同目录下的 aliyunpan-report.css
+	aliyunpan report d:/report.html
+
+	生成样式内嵌的单个报告文件
+	aliyunpan report -embed-css d:/report.html
+`,
+		Category: "阿里云盘账号",
+		Before:   ReloadConfigFunc,
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				cli.ShowCommandHelp(c, c.Command.Name)
+				return nil
+			}
+			if config.Config.ActiveUser() == nil {
+				fmt.Println("未登录账号")
+				return nil
+			}
+			RunReport(c.Args().Get(0), &ReportOptions{
+				EmbedCss: c.Bool("embed-css"),
+			})
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "embed-css",
+				Usage: "将样式内嵌到HTML文件中，生成单个自包含的报告文件",
+			},
+		},
+	}
+}
+
+// RunReport 生成网盘账号状态的HTML报告
+func RunReport(outputHtmlPath string, option *ReportOptions) {
+	if option == nil {
+		option = &ReportOptions{}
+	}
+	activeUser := GetActiveUser()
+	data := &ReportData{
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+		EmbedCss:    option.EmbedCss,
+		Css:         template.CSS(reportCss),
+		CssFileName: ReportCssFileName,
+		UserId:      activeUser.UserId,
+		Nickname:    activeUser.Nickname,
+		AccountName: activeUser.AccountName,
+	}
+	if drive := activeUser.GetActiveDriveInfo(); drive != nil {
+		data.DriveName = drive.DriveName
+	}
+
+	// 空间配额
+	fmt.Println("获取空间配额...")
+	if q, err := RunGetQuotaInfo(); err == nil {
+		data.QuotaUsed = converter.ConvertFileSize(q.UsedSize, 2)
+		data.QuotaTotal = converter.ConvertFileSize(q.Quota, 2)
+		data.QuotaPercent = "0"
+		if q.Quota > 0 {
+			data.QuotaPercent = fmt.Sprintf("%.2f", 100*float64(q.UsedSize)/float64(q.Quota))
+		}
+	} else {
+		fmt.Printf("获取空间配额失败: %s\n", err)
+		data.QuotaUsed, data.QuotaTotal, data.QuotaPercent = "-", "-", "0"
+	}
+
+	// 最大的文件
+	fmt.Println("统计最大的文件...")
+	driveId := activeUser.ActiveDriveId
+	topFiles := []*ReportFileItem{}
+	scanned := 0
+	rootDir, apierr := activeUser.PanClient().OpenapiPanClient().FileInfoByPath(driveId, "/")
+	if apierr != nil {
+		fmt.Printf("统计最大的文件失败: %s\n", apierr)
+	} else if err := collectReportTopFiles(driveId, rootDir, &topFiles, &scanned); err != nil {
+		fmt.Printf("统计最大的文件失败: %s\n", err)
+	}
+	data.TopFiles = topFiles
+	data.ScanTruncated = scanned >= ReportMaxScanFiles
+
+	// 上传、下载记录
+	since := time.Now().AddDate(0, 0, -ReportDownloadHistoryDays)
+	uploads := readReportRecords(config.GetLogDir()+"/upload_file_records.csv", time.Time{})
+	if len(uploads) > ReportRecentUploadsNum {
+		uploads = uploads[:ReportRecentUploadsNum]
+	}
+	data.RecentUploads = uploads
+	data.DownloadHistory = readReportRecords(config.GetLogDir()+"/download_file_records.csv", since)
+
+	// 有效分享
+	if activeUser.PanClient().WebapiPanClient() == nil {
+		data.SharesError = "WEB客户端未登录，无法获取分享列表"
+	} else {
+		fmt.Println("获取分享列表...")
+		records, err := activeUser.PanClient().WebapiPanClient().ShareLinkList(activeUser.UserId)
+		if err != nil {
+			data.SharesError = fmt.Sprintf("获取分享列表失败: %s", err)
+		} else {
+			cz := time.FixedZone("CST", 8*3600)
+			now := time.Now()
+			for _, record := range records {
+				if record.Status != "enabled" || record.FirstFile == nil {
+					continue
+				}
+				et := "永久有效"
+				if len(record.Expiration) > 0 {
+					expiredTime, _ := time.ParseInLocation("2006-01-02 15:04:05", record.Expiration, cz)
+					if expiredTime.Before(now) {
+						continue
+					}
+					et = record.Expiration
+				}
+				data.ActiveShares = append(data.ActiveShares, &ReportShareItem{
+					ShareId:    record.ShareId,
+					Name:       record.ShareName,
+					Url:        record.ShareUrl,
+					Expiration: et,
+				})
+			}
+		}
+	}
+
+	if err := writeReportHtml(outputHtmlPath, data); err != nil {
+		fmt.Printf("生成报告失败: %s\n", err)
+		return
+	}
+	fmt.Printf("报告已保存: %s\n", outputHtmlPath)
+}
+
+// writeReportHtml 渲染报告并保存，不内嵌样式时同时在同目录下保存样式文件
+func writeReportHtml(outputHtmlPath string, data *ReportData) error {
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"inc": func(i int) int { return i + 1 },
+	}).Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(outputHtmlPath); dir != "" {
+		os.MkdirAll(dir, 0755)
+	}
+	file, err := os.Create(outputHtmlPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = tmpl.Execute(file, data); err != nil {
+		return err
+	}
+	if !data.EmbedCss {
+		cssPath := filepath.Join(filepath.Dir(outputHtmlPath), data.CssFileName)
+		if err = os.WriteFile(cssPath, []byte(reportCss), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectReportTopFiles 递归遍历网盘目录，保留最大的 ReportTopFilesNum 个文件
+func collectReportTopFiles(driveId string, dir *aliyunpan.FileEntity, topFiles *[]*ReportFileItem, scanned *int) error {
+	if *scanned >= ReportMaxScanFiles {
+		return nil
+	}
+	fileList, apierr := GetActivePanClient().OpenapiPanClient().FileListGetAll(&aliyunpan.FileListParam{
+		DriveId:      driveId,
+		ParentFileId: dir.FileId,
+	}, 500)
+	if apierr != nil {
+		return apierr
+	}
+	for _, f := range fileList {
+		if *scanned >= ReportMaxScanFiles {
+			return nil
+		}
+		*scanned++
+		f.Path = path.Join(dir.Path, f.FileName)
+		if f.IsFolder() {
+			time.Sleep(200 * time.Millisecond) // 避免触发风控
+			if err := collectReportTopFiles(driveId, f, topFiles, scanned); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(*topFiles) >= ReportTopFilesNum && f.FileSize <= (*topFiles)[len(*topFiles)-1].size {
+			continue
+		}
+		*topFiles = append(*topFiles, &ReportFileItem{
+			Path:      f.Path,
+			Size:      converter.ConvertFileSize(f.FileSize, 2),
+			UpdatedAt: f.UpdatedAt,
+			size:      f.FileSize,
+		})
+		sort.SliceStable(*topFiles, func(i, j int) bool {
+			return (*topFiles)[i].size > (*topFiles)[j].size
+		})
+		if len(*topFiles) > ReportTopFilesNum {
+			*topFiles = (*topFiles)[:ReportTopFilesNum]
+		}
+	}
+	return nil
+}
+
+// readReportRecords 读取文件记录，按时间倒序返回 since 之后的记录。since 为零值时返回全部记录
+func readReportRecords(csvPath string, since time.Time) []*ReportRecordItem {
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil
+	}
+
+	items := []*ReportRecordItem{}
+	for i, row := range rows {
+		if i == 0 || len(row) < 4 {
+			// 表头
+			continue
+		}
+		if !since.IsZero() {
+			t, er := time.ParseInLocation("2006-01-02 15:04:05", strings.TrimSpace(row[1]), time.Local)
+			if er != nil || t.Before(since) {
+				continue
+			}
+		}
+		items = append(items, &ReportRecordItem{
+			Status: row[0],
+			Time:   row[1],
+			Size:   row[2],
+			Path:   row[3],
+		})
+	}
+	// 记录按时间顺序追加，倒序后最新的在前
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	return items
+}
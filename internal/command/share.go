@@ -14,22 +14,168 @@
 package command
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/tickstep/aliyunpan-api/aliyunpan"
 	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
 	"github.com/tickstep/aliyunpan-api/aliyunpan_web"
 	"github.com/tickstep/aliyunpan/cmder"
 	"github.com/tickstep/aliyunpan/cmder/cmdtable"
 	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/aliyunpan/internal/global"
+	"github.com/tickstep/aliyunpan/internal/utils"
+	"github.com/tickstep/library-go/converter"
 	"github.com/urfave/cli"
+	"io"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+type (
+	// ShareSetOptions 创建分享可选参数
+	ShareSetOptions struct {
+		Mode           string // 模式，1-私密分享，2-公开分享，3-快传
+		DriveId        string
+		ExpiredTime    string // 过期时间，空代表永久有效
+		SharePwd       string // 分享密码
+		AutoPassword   bool   // 使用文件hash派生确定性的分享密码
+		PasswordSecret string // 派生分享密码使用的密钥
+		DryRun         bool   // 只输出将要分享的内容，不创建分享
+		AuditLog       string // 审计日志文件路径，每次创建分享追加一行JSON记录
+
+		RotatePasswordEvery time.Duration // 私密分享密码的轮换间隔，0代表不轮换
+		MaxRotations        int           // 最多轮换次数，0代表不限制
+
+		ExpiryWebhook string        // 分享过期时通知的 webhook 地址
+		PollInterval  time.Duration // 检查分享是否过期的间隔
+
+		WatermarkUser   string // 接收者标识，用于在分享链接上附加可追溯的水印参数
+		WatermarkSecret string // 计算水印参数使用的密钥
+
+		LinkTitle string // 分享链接的标题，只用于本地输出和审计日志
+		LinkType  string // 链接类型，temporary-链接随源文件删除失效，permanent-分享源文件的副本，源文件删除后链接仍有效
+
+		NotifySlack    string // 分享创建成功后通知的 Slack incoming webhook 地址
+		NotifyDingTalk string // 分享创建成功后通知的钉钉自定义机器人 webhook 地址
+		DingTalkSecret string // 钉钉机器人的加签密钥, 为空代表没有开启加签
+
+		GroupByDir bool // 按照文件所在的网盘目录分组，每个目录创建一个分享链接
+		Parallel   int  // 分组分享时同时创建分享链接的数量
+
+		IncludeGlobs []string // 包含的文件名通配符，指定后目录会展开为其中匹配的文件，不匹配的文件不分享
+	}
+
+	// ShareRotateOptions 分享密码轮换可选参数
+	ShareRotateOptions struct {
+		ExpiredTime  string // 分享过期时间，分享过期后停止轮换
+		MaxRotations int    // 最多轮换次数，0代表不限制
+		AuditLog     string // 记录新旧密码的审计日志文件路径
+	}
+
+	// ShareRotateEvent 分享密码轮换审计记录
+	ShareRotateEvent struct {
+		Time    string `json:"time"`
+		ShareId string `json:"shareId"`
+		OldPwd  string `json:"oldPwd"`
+		NewPwd  string `json:"newPwd"`
+	}
+
+	// ShareAuditEvent 分享创建审计记录
+	ShareAuditEvent struct {
+		Time    string   `json:"time"`
+		User    string   `json:"user"`
+		ShareId string   `json:"shareId"`
+		Url     string   `json:"url"`
+		Files   []string `json:"files"`
+		Expires string   `json:"expires"`
+		Mode    string   `json:"mode"`
+		Title   string   `json:"title,omitempty"`
+	}
+
+	// ShareListOptions 列出分享可选参数
+	ShareListOptions struct {
+		WithFiles bool // 展开显示每个分享包含的文件
+		PageSize  int  // 分享列表接口的分页大小，0代表使用接口默认值
+
+		OutputWide      bool          // 显示更多列：文件数、总大小、网盘ID、创建时间
+		TimeoutPerShare time.Duration // OutputWide 时每个分享获取额外信息的超时时间
+
+		Reverse bool // 倒序显示分享列表
+		Limit   int  // 最多显示的分享数量，0代表不限制
+
+		Watch    bool          // 持续轮询分享列表，显示新出现的分享
+		Interval time.Duration // Watch 时轮询的间隔
+
+		JSON      bool // 以JSON格式输出分享列表，便于脚本处理
+		CountOnly bool // 只输出分享的数量
+
+		ShareId string // 只显示指定分享的详细信息
+
+		ActiveOnly bool // 只显示有效的分享，排除已过期、已删除和违规的分享
+	}
+
+	// shareListJSONItem JSON格式输出的分享记录
+	shareListJSONItem struct {
+		ShareId    string `json:"shareId"`
+		ShareUrl   string `json:"shareUrl"`
+		SharePwd   string `json:"sharePwd"`
+		ShareName  string `json:"shareName"`
+		Expiration string `json:"expiration"`
+		Status     string `json:"status"`
+		CreatedAt  string `json:"createdAt,omitempty"`
+	}
+
+	// ShareCancelOptions 取消分享可选项
+	ShareCancelOptions struct {
+		DryRun bool // 只显示将要取消的分享，不实际取消
+	}
+)
+
+const (
+	// SharePasswordCharset 分享密码字符集
+	SharePasswordCharset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	// SharePasswordLength 分享密码长度
+	SharePasswordLength = 4
+	// DefaultShareListPageSize 分享列表接口默认的分页大小
+	DefaultShareListPageSize = 100
+	// MaxShareListPageSize 分享列表接口允许的最大分页大小
+	MaxShareListPageSize = 200
+
+	// ShareExportFormatCsv 分享导出为csv格式
+	ShareExportFormatCsv = "csv"
+	// ShareExportFormatJson 分享导出为json格式
+	ShareExportFormatJson = "json"
+
+	// ShareLinkTypeTemporary 分享源文件，源文件删除后链接失效
+	ShareLinkTypeTemporary = "temporary"
+	// ShareLinkTypePermanent 分享源文件的副本，源文件删除后链接仍然有效
+	ShareLinkTypePermanent = "permanent"
+	// SharePermanentCopyDir 永久链接保存源文件副本的网盘目录
+	SharePermanentCopyDir = "/aliyunpan_share_copies"
+	// ShareFolderCoverMaxSize 分享封面图片的最大文件大小
+	ShareFolderCoverMaxSize = 5 * converter.MB
+	// ShareListMaxFiles share list -with-files 每个分享最多显示的文件数
+	ShareListMaxFiles = 10
+)
+
+var (
+	// sharePermanentCopyMutex 保证永久链接的副本依次复制
+	sharePermanentCopyMutex = &sync.Mutex{}
+)
+
 func CmdShare() cli.Command {
 	return cli.Command{
 		Name:      "share",
@@ -62,8 +208,53 @@ func CmdShare() cli.Command {
     创建文件 1.mp4 的分享链接，并指定有效期为1天
 	aliyunpan share set -mode 1 -time 1 1.mp4
 
+    创建文件 1.mp4 的分享链接，使用文件hash和密钥派生固定的分享密码，相同的密钥和文件总是得到相同的密码
+	aliyunpan share set -mode 1 -auto-password -password-secret mysecret 1.mp4
+
     创建文件 1.mp4 的快传链接
 	aliyunpan share set 1.mp4
+
+    测试创建 /我的视频/ 目录下所有mp4文件的分享链接，只输出将要分享的文件，不创建分享
+	aliyunpan share set -mode 1 -test /我的视频/*.mp4
+
+    创建文件 1.mp4 的分享链接，并将分享记录追加到审计日志 share_audit.log
+	aliyunpan share set -mode 1 -audit-log share_audit.log 1.mp4
+
+    创建文件 1.mp4 的30天有效期的私密分享
+	aliyunpan share set -mode 1 -time 3 1.mp4
+
+    创建文件 1.mp4 的7天有效期的私密分享，每60分钟自动更换一次提取码，最多更换24次
+	aliyunpan share set -mode 1 -time 2 -rotate-password-every 60 -max-rotations 24 1.mp4
+
+    创建文件 1.mp4 的1天有效期的私密分享，分享过期后向 webhook 发送通知，每5分钟检查一次
+	aliyunpan share set -mode 1 -time 1 -expiry-webhook https://example.com/hook -poll-interval 300 1.mp4
+
+    创建文件 1.mp4 的分享链接，并在链接上附加接收者 alice@example.com 的水印参数，链接泄露时可以追溯到接收者
+	aliyunpan share set -mode 1 -watermark-user alice@example.com -watermark-secret mysecret 1.mp4
+
+    创建文件 1.mp4 的分享链接，并指定链接标题，标题会显示在输出和审计日志中
+	aliyunpan share set -mode 1 -link-title "项目资料" -audit-log share_audit.log 1.mp4
+
+    创建文件 1.mp4 的永久分享链接，先复制文件到 /aliyunpan_share_copies 目录再分享副本，删除 1.mp4 后链接仍然有效
+	aliyunpan share set -mode 1 -link-type permanent 1.mp4
+
+    创建文件 1.mp4 的分享链接，并发送通知到 Slack
+	aliyunpan share set -mode 1 -notify-slack https://hooks.slack.com/services/T000/B000/XXXX 1.mp4
+
+    创建文件 1.mp4 的分享链接，并发送通知到开启了加签的钉钉机器人
+	aliyunpan share set -mode 1 -notify-dingtalk "https://oapi.dingtalk.com/robot/send?access_token=XXXX" -dingtalk-secret SECxxxx 1.mp4
+
+    不同目录下的文件按照所在目录分组，每个目录创建一个分享链接
+	aliyunpan share set -mode 1 -group-by-dir /视频/a/1.mp4 /视频/a/2.mp4 /文档/1.pdf
+
+    按照所在目录分组，同时创建4个分享链接
+	aliyunpan share set -mode 1 -group-by-dir -parallel 4 /视频/a/1.mp4 /视频/b/1.mp4 /视频/c/1.mp4 /文档/1.pdf
+
+    只分享 /movies 目录(包括子目录)下的 mp4 和 mkv 文件
+	aliyunpan share set -mode 1 -include "*.mp4" -include "*.mkv" /movies
+
+    监控两个分享，每隔5分钟检查一次，过期后使用相同的文件、提取码和有效期自动重建，按 Ctrl+C 退出
+	aliyunpan share set -auto-refresh -poll-interval 300 5kXgbsbpr3N 9kJrdYXiQG2
 `,
 				Action: func(c *cli.Context) error {
 					if c.NArg() < 1 {
@@ -78,6 +269,11 @@ func CmdShare() cli.Command {
 						fmt.Println("WEB客户端未登录，请登录后再使用该命令")
 						return nil
 					}
+					if c.Bool("auto-refresh") {
+						// 参数为分享ID，不创建新的分享
+						RunShareAutoRefresh(c.Args(), time.Duration(c.Int("poll-interval"))*time.Second)
+						return nil
+					}
 					et := ""
 					timeFlag := "0"
 					if c.IsSet("time") {
@@ -88,6 +284,8 @@ func CmdShare() cli.Command {
 						et = now.Add(time.Duration(1) * time.Hour * 24).Format("2006-01-02 15:04:05")
 					} else if timeFlag == "2" {
 						et = now.Add(time.Duration(7) * time.Hour * 24).Format("2006-01-02 15:04:05")
+					} else if timeFlag == "3" {
+						et = now.Add(time.Duration(30) * time.Hour * 24).Format("2006-01-02 15:04:05")
 					} else {
 						et = ""
 					}
@@ -109,14 +307,139 @@ func CmdShare() cli.Command {
 						}
 					}
 
+					autoPassword := c.Bool("auto-password")
+					if autoPassword {
+						if modeFlag != "1" {
+							fmt.Println("只有私密分享才支持 auto-password 选项")
+							return nil
+						}
+						if sharePwd != "" {
+							fmt.Println("auto-password 和 sharePwd 不能同时使用")
+							return nil
+						}
+						if c.String("password-secret") == "" {
+							fmt.Println("使用 auto-password 必须指定 password-secret 密钥")
+							return nil
+						}
+					}
+
 					if modeFlag == "1" {
-						if sharePwd == "" {
-							sharePwd = RandomStr(4)
+						if sharePwd == "" && !autoPassword {
+							sharePwd = RandomStr(SharePasswordLength)
 						}
 					} else {
 						sharePwd = ""
 					}
-					RunShareSet(modeFlag, parseDriveId(c), c.Args(), et, sharePwd)
+					if c.Int("rotate-password-every") > 0 && modeFlag != "1" {
+						fmt.Println("只有私密分享才支持 rotate-password-every 选项")
+						return nil
+					}
+					if c.Int("rotate-password-every") > 0 && c.Bool("group-by-dir") {
+						fmt.Println("group-by-dir 会创建多个分享，不支持 rotate-password-every 选项")
+						return nil
+					}
+					if c.Int("parallel") < 1 {
+						fmt.Println("parallel 必须大于等于1")
+						return nil
+					}
+					if c.Int("parallel") > 1 && !c.Bool("group-by-dir") {
+						fmt.Println("parallel 需要配合 group-by-dir 选项使用")
+						return nil
+					}
+					if c.String("expiry-webhook") != "" && et == "" {
+						fmt.Println("永久有效的分享不会过期，expiry-webhook 需要配合 time 选项使用")
+						return nil
+					}
+					if c.String("watermark-user") != "" && c.String("watermark-secret") == "" {
+						fmt.Println("使用 watermark-user 必须指定 watermark-secret 密钥")
+						return nil
+					}
+					for _, pattern := range c.StringSlice("include") {
+						if _, err := filepath.Match(pattern, ""); err != nil {
+							fmt.Printf("无效的包含通配符: %s\n", pattern)
+							return nil
+						}
+					}
+					linkType := c.String("link-type")
+					if linkType != ShareLinkTypeTemporary && linkType != ShareLinkTypePermanent {
+						fmt.Printf("不支持的链接类型: %s\n", linkType)
+						return nil
+					}
+					if c.String("limit-ip") != "" {
+						if _, err := parseShareLimitIp(c.String("limit-ip")); err != nil {
+							fmt.Println(err)
+							return nil
+						}
+						// 分享接口没有IP白名单参数，不能忽略该选项创建一个没有限制的分享
+						fmt.Println("阿里云盘分享接口不支持限制访问IP，limit-ip 选项暂不可用")
+						return nil
+					}
+					if c.Bool("protect-with-captcha") {
+						if modeFlag != "1" && modeFlag != "2" {
+							fmt.Println("快传链接不支持 protect-with-captcha 选项，只能用于私密分享或公开分享")
+							return nil
+						}
+						// 验证码由分享平台服务端控制，分享接口没有对应参数
+						fmt.Println("阿里云盘分享接口不支持开启访问验证码，protect-with-captcha 选项暂不可用")
+						return nil
+					}
+					if c.String("folder-cover") != "" {
+						activeUser := config.Config.ActiveUser()
+						driveId := parseDriveId(c)
+						coverPath := activeUser.PathJoin(driveId, c.String("folder-cover"))
+						coverFile, apierr := activeUser.PanClient().OpenapiPanClient().FileInfoByPath(driveId, coverPath)
+						if apierr != nil {
+							fmt.Printf("获取封面图片信息出错: %s, %s\n", coverPath, apierr)
+							return nil
+						}
+						if err := checkShareFolderCover(coverFile); err != nil {
+							fmt.Println(err)
+							return nil
+						}
+						// 创建分享的参数中没有封面图片, 封面由分享平台根据分享的文件生成
+						fmt.Println("阿里云盘分享接口不支持设置分享封面，folder-cover 选项暂不可用")
+						return nil
+					}
+					if c.Bool("no-bot") {
+						// 反爬标记需要分享平台支持，分享接口和分享链接都没有对应参数
+						fmt.Println("阿里云盘分享接口不支持反爬虫标记，no-bot 选项暂不可用")
+						return nil
+					}
+					unresolvedCount := RunShareSet(c.Args(), &ShareSetOptions{
+						Mode:           modeFlag,
+						DriveId:        parseDriveId(c),
+						ExpiredTime:    et,
+						SharePwd:       sharePwd,
+						AutoPassword:   autoPassword,
+						PasswordSecret: c.String("password-secret"),
+						DryRun:         c.Bool("test"),
+						AuditLog:       c.String("audit-log"),
+
+						RotatePasswordEvery: time.Duration(c.Int("rotate-password-every")) * time.Minute,
+						MaxRotations:        c.Int("max-rotations"),
+
+						ExpiryWebhook: c.String("expiry-webhook"),
+						PollInterval:  time.Duration(c.Int("poll-interval")) * time.Second,
+
+						WatermarkUser:   c.String("watermark-user"),
+						WatermarkSecret: c.String("watermark-secret"),
+
+						LinkTitle: c.String("link-title"),
+						LinkType:  linkType,
+
+						NotifySlack:    c.String("notify-slack"),
+						NotifyDingTalk: c.String("notify-dingtalk"),
+						DingTalkSecret: c.String("dingtalk-secret"),
+
+						GroupByDir: c.Bool("group-by-dir"),
+						Parallel:   c.Int("parallel"),
+
+						IncludeGlobs: c.StringSlice("include"),
+					})
+					// 测试模式下有路径无法解析时以错误状态退出, 交互模式下不退出
+					if c.Bool("test") && unresolvedCount > 0 && !global.IsAppInCliMode {
+						os.Exit(1)
+					}
 					return nil
 				},
 				Flags: []cli.Flag{
@@ -127,7 +450,7 @@ func CmdShare() cli.Command {
 					},
 					cli.StringFlag{
 						Name:  "time",
-						Usage: "有效期，0-永久，1-1天，2-7天",
+						Usage: "有效期，0-永久，1-1天，2-7天，3-30天",
 						Value: "0",
 					},
 					cli.StringFlag{
@@ -140,6 +463,109 @@ func CmdShare() cli.Command {
 						Usage: "自定义私密分享密码，4个字符，没有指定则随机生成",
 						Value: "",
 					},
+					cli.BoolFlag{
+						Name:  "auto-password",
+						Usage: "使用文件hash和 password-secret 派生固定的私密分享密码，相同的密钥和文件总是得到相同的密码",
+					},
+					cli.StringFlag{
+						Name:  "password-secret",
+						Usage: "派生私密分享密码使用的密钥，配合 auto-password 使用",
+						Value: "",
+					},
+					cli.BoolFlag{
+						Name:  "test",
+						Usage: "测试模式，只输出将要分享的文件、模式和有效期，不创建分享。有路径无法解析时以错误状态退出",
+					},
+					cli.StringFlag{
+						Name:  "audit-log",
+						Usage: "审计日志文件路径，每次创建分享都会追加一行JSON记录，文件不存在则自动创建",
+						Value: "",
+					},
+					cli.IntFlag{
+						Name:  "rotate-password-every",
+						Usage: "私密分享创建后，每隔指定的分钟数自动更换提取码，直到分享过期或者达到最多更换次数。交互模式下在后台更换，不影响执行其他命令。0代表不更换",
+						Value: 0,
+					},
+					cli.IntFlag{
+						Name:  "max-rotations",
+						Usage: "自动更换提取码的最多次数，更换失败不计入次数，0代表不限制，配合 rotate-password-every 使用",
+						Value: 0,
+					},
+					cli.StringFlag{
+						Name:  "expiry-webhook",
+						Usage: "分享过期后，以POST方式向该地址发送JSON通知。监控列表保存在配置目录，程序重启后在交互模式下继续监控",
+						Value: "",
+					},
+					cli.IntFlag{
+						Name:  "poll-interval",
+						Usage: "检查分享是否过期的间隔，单位秒，配合 expiry-webhook 或 auto-refresh 使用",
+						Value: 60,
+					},
+					cli.StringFlag{
+						Name:  "watermark-user",
+						Usage: "接收者标识，例如邮箱或者ID。会在分享链接后附加 ?w=<水印> 参数，用于追溯泄露的链接",
+						Value: "",
+					},
+					cli.StringFlag{
+						Name:  "watermark-secret",
+						Usage: "计算水印参数使用的密钥，配合 watermark-user 使用",
+						Value: "",
+					},
+					cli.StringFlag{
+						Name:  "link-title",
+						Usage: "分享链接的标题。阿里云盘创建分享的接口不支持自定义标题，该标题只显示在命令输出和审计日志中",
+						Value: "",
+					},
+					cli.StringFlag{
+						Name:  "link-type",
+						Usage: "链接类型，temporary-直接分享源文件，源文件删除后链接失效；permanent-先复制源文件到 " + SharePermanentCopyDir + " 目录再分享副本，源文件删除后链接仍然有效，副本会占用网盘空间",
+						Value: ShareLinkTypeTemporary,
+					},
+					cli.StringFlag{
+						Name:  "notify-slack",
+						Usage: "分享创建成功后发送通知到指定的 Slack incoming webhook 地址，包括文件列表、链接、提取码和过期时间",
+					},
+					cli.StringFlag{
+						Name:  "notify-dingtalk",
+						Usage: "分享创建成功后发送通知到该钉钉自定义机器人 webhook 地址，包括文件、链接、提取码和过期时间",
+					},
+					cli.StringFlag{
+						Name:  "dingtalk-secret",
+						Usage: "钉钉机器人的加签密钥(SEC开头)，机器人开启了加签时需要指定",
+					},
+					cli.BoolFlag{
+						Name:  "group-by-dir",
+						Usage: "按照文件所在的网盘目录分组，每个目录创建一个分享链接，最后输出目录和分享链接的对应表",
+					},
+					cli.IntFlag{
+						Name:  "parallel",
+						Usage: "配合 group-by-dir 使用，同时创建分享链接的数量",
+						Value: 1,
+					},
+					cli.StringSliceFlag{
+						Name:  "include",
+						Usage: "只分享文件名匹配通配符的文件，例如 *.mp4。指定后目录会递归展开为其中匹配的文件。支持多个通配符，每一个通配符就是一个include参数",
+					},
+					cli.StringFlag{
+						Name:  "limit-ip",
+						Usage: "只允许指定网段的IP访问分享，多个网段使用逗号分隔，例如 10.0.0.0/8,192.168.1.0/24。当前阿里云盘接口不支持",
+					},
+					cli.BoolFlag{
+						Name:  "auto-refresh",
+						Usage: "持续监控参数指定的分享ID，分享过期后使用相同的文件、提取码和有效期自动重建，按 Ctrl+C 退出",
+					},
+					cli.BoolFlag{
+						Name:  "protect-with-captcha",
+						Usage: "访问分享前需要输入验证码，只支持私密分享和公开分享。当前阿里云盘接口不支持",
+					},
+					cli.StringFlag{
+						Name:  "folder-cover",
+						Usage: "使用网盘中的图片作为分享链接预览卡片的封面，只支持jpg、png格式，大小不超过5MB。当前阿里云盘接口不支持",
+					},
+					cli.BoolFlag{
+						Name:  "no-bot",
+						Usage: "分享链接需要执行JavaScript或者通过验证码才能访问，阻止简单的爬虫。当前阿里云盘接口不支持",
+					},
 				},
 			},
 			{
@@ -147,6 +573,39 @@ func CmdShare() cli.Command {
 				Aliases:   []string{"l"},
 				Usage:     "列出已分享文件/目录",
 				UsageText: cmder.App().Name + " share list",
+				Description: `
+示例:
+
+    列出所有分享
+	aliyunpan share list
+
+    列出所有分享，并展开显示每个分享包含的文件
+	aliyunpan share list -with-files
+
+    分享数量较多时，使用较大的分页大小减少接口请求次数
+	aliyunpan share list -paginate-api 200
+
+    显示更多列：文件数、总大小、网盘ID、创建时间，每个分享获取额外信息最多等待3秒
+	aliyunpan share list -output-wide -timeout-per-share 3
+
+    倒序显示分享列表，只显示前10条，可用于找出最早创建的分享
+	aliyunpan share list -reverse -limit 10
+
+    持续监控分享列表，每隔30秒查询一次，显示其他会话或者自动化工具新创建的分享，按 Ctrl+C 退出
+	aliyunpan share list -watch -interval 30
+
+    以JSON格式输出分享列表，配合 jq 只输出所有分享链接
+	aliyunpan share list -json | jq -r '.[].shareUrl'
+
+    只输出分享的数量，用于脚本判断
+	if [ $(aliyunpan share list -count-only) -gt 100 ]; then echo "too many shares"; fi
+
+    显示指定分享的详细信息，包括所有文件ID、过期时间、状态、创建时间
+	aliyunpan share list -share-id 5kXgbsbpr3N
+
+    只以JSON格式输出当前有效的分享
+	aliyunpan share list -active-only -json
+`,
 				Action: func(c *cli.Context) error {
 					if config.Config.ActiveUser() == nil {
 						fmt.Println("未登录账号")
@@ -156,17 +615,129 @@ func CmdShare() cli.Command {
 						fmt.Println("WEB客户端未登录，请登录后再使用该命令")
 						return nil
 					}
-					RunShareList()
+					if c.Int("limit") < 0 {
+						fmt.Println("显示数量不能小于0")
+						return nil
+					}
+					pageSize := 0
+					if c.IsSet("paginate-api") {
+						pageSize = c.Int("paginate-api")
+						if pageSize < 1 || pageSize > MaxShareListPageSize {
+							fmt.Printf("分页大小必须在 1 ~ %d 之间\n", MaxShareListPageSize)
+							return nil
+						}
+					}
+					if c.Bool("watch") && c.Int("interval") <= 0 {
+						fmt.Println("轮询间隔必须大于0")
+						return nil
+					}
+					if c.Bool("json") && (c.Bool("watch") || c.Bool("with-files") || c.Bool("output-wide")) {
+						fmt.Println("json 不能和 watch、with-files、output-wide 同时使用")
+						return nil
+					}
+					if c.Bool("count-only") && (c.Bool("json") || c.Bool("watch")) {
+						fmt.Println("count-only 不能和 json、watch 同时使用")
+						return nil
+					}
+					if c.String("share-id") != "" && (c.Bool("json") || c.Bool("watch") || c.Bool("count-only")) {
+						fmt.Println("share-id 不能和 json、watch、count-only 同时使用")
+						return nil
+					}
+					RunShareList(&ShareListOptions{
+						WithFiles: c.Bool("with-files"),
+						PageSize:  pageSize,
+
+						OutputWide:      c.Bool("output-wide"),
+						TimeoutPerShare: time.Duration(c.Int("timeout-per-share")) * time.Second,
+
+						Reverse: c.Bool("reverse"),
+						Limit:   c.Int("limit"),
+
+						Watch:    c.Bool("watch"),
+						Interval: time.Duration(c.Int("interval")) * time.Second,
+
+						JSON:      c.Bool("json"),
+						CountOnly: c.Bool("count-only"),
+
+						ShareId: c.String("share-id"),
+
+						ActiveOnly: c.Bool("active-only"),
+					})
 					return nil
 				},
-				Flags: []cli.Flag{},
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "with-files",
+						Usage: "展开显示每个分享包含的文件路径和大小，每个分享最多显示10个文件",
+					},
+					cli.IntFlag{
+						Name:  "paginate-api",
+						Usage: "分享列表接口的分页大小，取值范围 1 ~ 200，不指定则使用接口默认值100",
+					},
+					cli.BoolFlag{
+						Name:  "output-wide",
+						Usage: "显示更多列：文件数、总大小(包括目录中的所有文件)、网盘ID、创建时间。需要为每个分享额外请求文件信息",
+					},
+					cli.IntFlag{
+						Name:  "timeout-per-share",
+						Usage: "output-wide 时每个分享获取文件信息的超时时间，单位秒，超时则总大小显示为超时",
+						Value: 5,
+					},
+					cli.BoolFlag{
+						Name:  "reverse",
+						Usage: "倒序显示分享列表",
+					},
+					cli.IntFlag{
+						Name:  "limit",
+						Usage: "最多显示的分享数量，0代表不限制。配合 reverse 使用可以只显示最早创建的分享",
+						Value: 0,
+					},
+					cli.BoolFlag{
+						Name:  "watch",
+						Usage: "显示分享列表后持续轮询，只显示新出现的分享，按 Ctrl+C 退出",
+					},
+					cli.IntFlag{
+						Name:  "interval",
+						Usage: "watch 时轮询分享列表的间隔，单位秒",
+						Value: 60,
+					},
+					cli.BoolFlag{
+						Name:  "json",
+						Usage: "以JSON格式输出分享列表，包含分享ID、链接、提取码、名称、过期时间和状态，便于脚本处理",
+					},
+					cli.BoolFlag{
+						Name:  "count-only",
+						Usage: "只输出分享的数量(应用 limit 之后)，不显示分享列表，便于脚本处理",
+					},
+					cli.StringFlag{
+						Name:  "share-id",
+						Usage: "只显示指定分享ID的详细信息：所有文件ID、过期时间、状态、创建时间",
+					},
+					cli.BoolFlag{
+						Name:  "active-only",
+						Usage: "只显示状态为有效的分享，排除已过期、已删除和违规的分享。可以和 json、count-only、watch 同时使用",
+					},
+				},
 			},
 			{
-				Name:        "cancel",
-				Aliases:     []string{"c"},
-				Usage:       "取消分享文件/目录",
-				UsageText:   cmder.App().Name + " share cancel <shareid_1> <shareid_2> ...",
-				Description: `目前只支持通过分享id (shareid) 来取消分享.`,
+				Name:      "cancel",
+				Aliases:   []string{"c"},
+				Usage:     "取消分享文件/目录",
+				UsageText: cmder.App().Name + " share cancel <shareid_1 或者 通配符> <shareid_2 或者 通配符> ...",
+				Description: `通过分享id (shareid) 来取消分享.
+只包含字母和数字的参数视为分享id, 其他参数视为通配符, 匹配分享名称相符的所有分享.
+
+示例:
+    取消分享
+	aliyunpan share cancel 5kXgbsbpr3N 9kJrdYXiQG2
+
+    取消所有名称以 .mp4 结尾的分享，建议先使用 -dry-run 确认
+	aliyunpan share cancel -dry-run "*.mp4"
+	aliyunpan share cancel "*.mp4"
+
+    只显示将要取消的分享，不实际取消
+	aliyunpan share cancel -dry-run 5kXgbsbpr3N 9kJrdYXiQG2
+`,
 				Action: func(c *cli.Context) error {
 					if config.Config.ActiveUser() == nil {
 						fmt.Println("未登录账号")
@@ -180,25 +751,59 @@ func CmdShare() cli.Command {
 						cli.ShowCommandHelp(c, c.Command.Name)
 						return nil
 					}
-					RunShareCancel(c.Args())
+					RunShareCancel(c.Args(), &ShareCancelOptions{
+						DryRun: c.Bool("dry-run"),
+					})
 					return nil
 				},
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "只显示将要取消的分享(ID、名称、状态、过期时间)，不实际取消",
+					},
+				},
 			},
 			{
 				Name:      "export",
 				Usage:     "导出分享记录保存到文件",
 				UsageText: cmder.App().Name + " share export <csv file path>",
 				Description: `
-导出分享记录，并保存到指定的文件。目前支持csv格式
+导出分享记录，并保存到指定的文件。支持csv和json格式
   
 示例:
     导出所有有效的分享并保存成文件
 	aliyunpan share export "d:\myfoler\share_list.csv"
 
+    导出所有有效的分享并保存成json文件
+	aliyunpan share export -format json "d:\myfoler\share_list.json"
+
     导出所有的分享并保存成文件
 	aliyunpan share export -option 2 "d:\myfoler\share_list.csv"
+
+    导出所有的分享，每个文件最多1000条记录，保存成 share_list_001.csv、share_list_002.csv ...
+	aliyunpan share export -option 2 -split-size 1000 "d:\myfoler\share_list.csv"
+
+    增量导出，只导出 share_list.csv 中没有的新分享(包括失效的分享)
+	aliyunpan share export -since-export "d:\myfoler\share_list.csv" "d:\myfoler\share_list_new.csv"
+
+    合并两个导出文件，按分享ID去重，按创建时间排序后保存到 share_list_all.csv
+	aliyunpan share export -merge "d:\myfoler\share_list_a.csv" "d:\myfoler\share_list_b.json" "d:\myfoler\share_list_all.csv"
 `,
 				Action: func(c *cli.Context) error {
+					if c.String("merge") != "" {
+						// 合并本地的导出文件，不需要登录
+						format := c.String("format")
+						if format != ShareExportFormatCsv && format != ShareExportFormatJson {
+							fmt.Printf("不支持的导出格式: %s，只支持 csv 或 json\n", format)
+							return nil
+						}
+						if c.NArg() < 2 {
+							fmt.Println("合并导出文件需要指定另一个导出文件和保存的文件路径")
+							return nil
+						}
+						RunShareExportMerge(c.String("merge"), c.Args()[0], c.Args()[1], format)
+						return nil
+					}
 					if config.Config.ActiveUser() == nil {
 						fmt.Println("未登录账号")
 						return nil
@@ -215,8 +820,26 @@ func CmdShare() cli.Command {
 					if opt == "" {
 						opt = "1"
 					}
+					format := c.String("format")
+					if format != ShareExportFormatCsv && format != ShareExportFormatJson {
+						fmt.Printf("不支持的导出格式: %s，只支持 csv 或 json\n", format)
+						return nil
+					}
+					splitSize := c.Int("split-size")
+					if splitSize < 0 {
+						fmt.Println("拆分记录数不能小于0")
+						return nil
+					}
+					if splitSize > 0 && format != ShareExportFormatCsv {
+						fmt.Println("-split-size 只支持csv格式")
+						return nil
+					}
 					filePath := c.Args()[0]
-					RunShareExport(opt, filePath)
+					if c.String("since-export") != "" {
+						RunShareExportIncremental(c.String("since-export"), filePath, format, splitSize)
+						return nil
+					}
+					RunShareExport(opt, filePath, format, splitSize)
 					return nil
 				},
 				Flags: []cli.Flag{
@@ -225,20 +848,73 @@ func CmdShare() cli.Command {
 						Usage: "导出选项，1-有效分享 2-全部分享",
 						Value: "1",
 					},
+					cli.StringFlag{
+						Name:  "since-export",
+						Usage: "上一次导出的csv文件，增量导出该文件中没有的分享",
+						Value: "",
+					},
+					cli.StringFlag{
+						Name:  "format",
+						Usage: "导出文件格式，csv 或 json",
+						Value: ShareExportFormatCsv,
+					},
+					cli.StringFlag{
+						Name:  "merge",
+						Usage: "之前导出的csv或json文件，和另一个导出文件合并，按分享ID去重，不请求分享列表",
+						Value: "",
+					},
+					cli.IntFlag{
+						Name:  "split-size",
+						Usage: "每个csv文件最多保存的分享记录数，超过后拆分保存为 <文件名>_001.csv、<文件名>_002.csv ...。0代表不拆分",
+						Value: 0,
+					},
+				},
+			},
+			{
+				Name:      "import",
+				Usage:     "从导出的文件重新创建分享",
+				UsageText: cmder.App().Name + " share import <csv file path>",
+				Description: `
+读取 share export 导出的csv文件，按照文件名在当前工作目录查找对应的文件，重新创建分享。
+快传链接重新创建为快传链接，其他分享使用原来的提取码和过期时间重新创建。
+文件已经不存在、已过期的分享会被跳过。
+
+示例:
+    从导出的文件重新创建分享
+	aliyunpan share import "d:\myfoler\share_list.csv"
+`,
+				Action: func(c *cli.Context) error {
+					if config.Config.ActiveUser() == nil {
+						fmt.Println("未登录账号")
+						return nil
+					}
+					if config.Config.ActiveUser().PanClient().WebapiPanClient() == nil {
+						fmt.Println("WEB客户端未登录，请登录后再使用该命令")
+						return nil
+					}
+					if c.NArg() < 1 {
+						cli.ShowCommandHelp(c, c.Command.Name)
+						return nil
+					}
+					RunShareImport(c.Args()[0])
+					return nil
 				},
 			},
 		},
 	}
 }
 
-// RunShareSet 执行分享
-func RunShareSet(modeFlag, driveId string, paths []string, expiredTime string, sharePwd string) {
+// RunShareSet 执行分享, 返回无法解析的路径数量
+func RunShareSet(paths []string, option *ShareSetOptions) (unresolvedCount int) {
 	if len(paths) <= 0 {
 		fmt.Println("请指定文件路径")
 		return
 	}
+	if option == nil {
+		option = &ShareSetOptions{Mode: "3"}
+	}
+	driveId := option.DriveId
 	activeUser := GetActiveUser()
-	panClient := activeUser.PanClient()
 
 	allFileList := []*aliyunpan.FileEntity{}
 	for idx := 0; idx < len(paths); idx++ {
@@ -246,27 +922,203 @@ func RunShareSet(modeFlag, driveId string, paths []string, expiredTime string, s
 		fileList, err1 := matchPathByShellPattern(driveId, absolutePath)
 		if err1 != nil {
 			fmt.Println("文件不存在: " + absolutePath)
+			unresolvedCount++
 			continue
 		}
 		if fileList == nil || len(fileList) == 0 {
 			// 文件不存在
 			fmt.Println("文件不存在: " + absolutePath)
+			unresolvedCount++
 			continue
 		}
 		// 匹配的文件
 		allFileList = append(allFileList, fileList...)
 	}
 
-	fidList := []string{}
-	for _, f := range allFileList {
-		fidList = append(fidList, f.FileId)
+	if len(option.IncludeGlobs) > 0 {
+		// 目录展开为其中的文件, 只保留匹配通配符的文件
+		expandedList := aliyunpan.FileList{}
+		for _, f := range allFileList {
+			if !f.IsFolder() {
+				expandedList = append(expandedList, f)
+				continue
+			}
+			expandedList = append(expandedList, activeUser.PanClient().OpenapiPanClient().FilesDirectoriesRecurseList(driveId, f.Path, nil)...)
+		}
+		allFileList = filterShareIncludeFiles(expandedList, option.IncludeGlobs)
+		if len(allFileList) == 0 {
+			fmt.Println("没有匹配 include 通配符的文件")
+			return
+		}
 	}
 
-	if len(fidList) == 0 {
-		fmt.Printf("没有指定有效的文件\n")
+	if option.GroupByDir && len(allFileList) > 0 {
+		runShareSetGroupByDir(allFileList, option)
 		return
 	}
+	runShareSetFiles(os.Stdout, allFileList, option)
+	return
+}
 
+// runShareSetGroupByDir 按照文件所在的网盘目录分组, 每个目录创建一个分享链接, 最后输出目录和分享链接的对应表
+func runShareSetGroupByDir(allFileList []*aliyunpan.FileEntity, option *ShareSetOptions) {
+	dirs, groups := groupShareFilesByDir(allFileList)
+	parallel := option.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	// 每个目录的结果按下标保存，输出时保持目录原来的顺序
+	shareUrls := make([]string, len(dirs))
+	sharePwds := make([]string, len(dirs))
+	shareErrs := make([]string, len(dirs))
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, parallel)
+	for k, dir := range dirs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(k int, dir string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			// 每个目录的输出先写入缓存，完成后整块输出，避免和其他目录的输出交错
+			out := &strings.Builder{}
+			fmt.Fprintf(out, "\n目录: %s\n", dir)
+			shareUrl, sharePwd, err := runShareSetFiles(out, groups[dir], option)
+			fmt.Print(out.String())
+			if err != nil {
+				shareUrl = "-"
+				shareErrs[k] = err.Error()
+			} else if option.DryRun {
+				shareUrl = "预览"
+			}
+			shareUrls[k] = shareUrl
+			sharePwds[k] = sharePwd
+		}(k, dir)
+	}
+	wg.Wait()
+
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "目录", "文件数", "分享链接", "提取码", "错误"})
+	for k, dir := range dirs {
+		tb.Append([]string{strconv.Itoa(k + 1), dir, strconv.Itoa(len(groups[dir])), shareUrls[k], sharePwds[k], shareErrs[k]})
+	}
+	fmt.Println()
+	tb.Render()
+}
+
+// parseShareLimitIp 解析逗号分隔的CIDR网段列表
+func parseShareLimitIp(s string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的网段: %s", item)
+		}
+		nets = append(nets, ipNet)
+	}
+	if len(nets) == 0 {
+		return nil, fmt.Errorf("没有指定网段")
+	}
+	return nets, nil
+}
+
+// checkShareFolderCover 检查封面图片的格式和大小
+func checkShareFolderCover(file *aliyunpan.FileEntity) error {
+	if file == nil || file.IsFolder() {
+		return fmt.Errorf("封面必须是图片文件")
+	}
+	switch strings.ToLower(path.Ext(file.FileName)) {
+	case ".jpg", ".jpeg", ".png":
+	default:
+		return fmt.Errorf("不支持的封面图片格式: %s, 只支持jpg、png格式", file.FileName)
+	}
+	if file.FileSize > ShareFolderCoverMaxSize {
+		return fmt.Errorf("封面图片过大: %s, 不能超过 %s", converter.ConvertFileSize(file.FileSize, 2), converter.ConvertFileSize(ShareFolderCoverMaxSize, 0))
+	}
+	return nil
+}
+
+// filterShareIncludeFiles 只保留文件名匹配任意一个通配符的文件, 目录会被排除
+func filterShareIncludeFiles(fileList aliyunpan.FileList, patterns []string) []*aliyunpan.FileEntity {
+	result := []*aliyunpan.FileEntity{}
+	for _, f := range fileList {
+		if f.IsFolder() {
+			continue
+		}
+		if utils.IsIncludeGlobFile(f.Path, patterns) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// groupShareFilesByDir 按照文件所在的网盘目录分组, dirs 为目录首次出现的顺序
+func groupShareFilesByDir(fileList []*aliyunpan.FileEntity) (dirs []string, groups map[string][]*aliyunpan.FileEntity) {
+	groups = map[string][]*aliyunpan.FileEntity{}
+	for _, f := range fileList {
+		dir := path.Dir(f.Path)
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], f)
+	}
+	return
+}
+
+// runShareSetFiles 为指定的文件创建一个分享链接, 过程信息输出到 out, 返回分享链接和提取码.
+// 测试模式下不创建分享, 返回的分享链接为空
+func runShareSetFiles(out io.Writer, allFileList []*aliyunpan.FileEntity, option *ShareSetOptions) (shareUrl, sharePwd string, err error) {
+	var (
+		modeFlag    = option.Mode
+		driveId     = option.DriveId
+		expiredTime = option.ExpiredTime
+	)
+	sharePwd = option.SharePwd
+	activeUser := GetActiveUser()
+	panClient := activeUser.PanClient()
+
+	fidList := []string{}
+	for _, f := range allFileList {
+		fidList = append(fidList, f.FileId)
+	}
+
+	if len(fidList) == 0 {
+		err = fmt.Errorf("没有指定有效的文件")
+		fmt.Fprintln(out, err)
+		return "", "", err
+	}
+
+	if modeFlag == "1" && option.AutoPassword {
+		sharePwd = DeriveSharePassword(option.PasswordSecret, allFileList)
+	}
+
+	if option.DryRun {
+		printShareSetDryRun(out, modeFlag, expiredTime, sharePwd, allFileList)
+		if option.LinkType == ShareLinkTypePermanent {
+			fmt.Fprintf(out, "永久链接：会先复制以上文件到 %s 目录再分享副本\n", SharePermanentCopyDir)
+		}
+		return "", sharePwd, nil
+	}
+
+	// 永久链接，分享源文件的副本
+	var copyDir *aliyunpan.FileEntity
+	if option.LinkType == ShareLinkTypePermanent {
+		dir, copyFidList, err1 := copyFilesForPermanentShare(out, driveId, allFileList)
+		if err1 != nil {
+			err = fmt.Errorf("复制文件失败: %s", err1)
+			fmt.Fprintln(out, err)
+			return "", "", err
+		}
+		copyDir, fidList = dir, copyFidList
+	}
+
+	var shareId string
 	if modeFlag == "3" {
 		// 快传
 		r, err1 := panClient.WebapiPanClient().FastShareLinkCreate(aliyunpan_web.FastShareCreateParam{
@@ -275,15 +1127,22 @@ func RunShareSet(modeFlag, driveId string, paths []string, expiredTime string, s
 		})
 		if err1 != nil || r == nil {
 			if err1.Code == apierror.ApiCodeFileShareNotAllowed {
-				fmt.Printf("创建快传链接失败: 该文件类型不允许分享\n")
+				err = fmt.Errorf("创建快传链接失败: 该文件类型不允许分享")
 			} else {
-				fmt.Printf("创建快传链接失败: %s\n", err1)
+				err = fmt.Errorf("创建快传链接失败: %s", err1)
 			}
-			return
+			fmt.Fprintln(out, err)
+			removeShareCopyDir(out, driveId, copyDir)
+			return "", "", err
 		}
 
-		fmt.Printf("创建快传链接成功\n")
-		fmt.Printf("链接：%s\n", r.ShareUrl)
+		shareId, shareUrl = r.ShareId, r.ShareUrl
+		if option.WatermarkUser != "" {
+			shareUrl = WatermarkShareUrl(shareUrl, option.WatermarkUser, option.WatermarkSecret)
+		}
+		fmt.Fprintf(out, "创建快传链接成功\n")
+		printShareLinkTitle(out, option.LinkTitle)
+		fmt.Fprintf(out, "链接：%s\n", shareUrl)
 	} else {
 		// 分享
 		r, err1 := panClient.WebapiPanClient().ShareLinkCreate(aliyunpan_web.ShareCreateParam{
@@ -294,73 +1153,743 @@ func RunShareSet(modeFlag, driveId string, paths []string, expiredTime string, s
 		})
 		if err1 != nil || r == nil {
 			if err1.Code == apierror.ApiCodeFileShareNotAllowed {
-				fmt.Printf("创建分享链接失败: 该文件类型不允许分享\n")
+				err = fmt.Errorf("创建分享链接失败: 该文件类型不允许分享")
 			} else {
-				fmt.Printf("创建分享链接失败: %s\n", err1)
+				err = fmt.Errorf("创建分享链接失败: %s", err1)
 			}
-			return
+			fmt.Fprintln(out, err)
+			removeShareCopyDir(out, driveId, copyDir)
+			return "", "", err
 		}
 
-		fmt.Printf("创建分享链接成功\n")
+		shareId, shareUrl = r.ShareId, r.ShareUrl
+		if option.WatermarkUser != "" {
+			shareUrl = WatermarkShareUrl(shareUrl, option.WatermarkUser, option.WatermarkSecret)
+		}
+		fmt.Fprintf(out, "创建分享链接成功\n")
+		printShareLinkTitle(out, option.LinkTitle)
 		if len(sharePwd) > 0 {
-			fmt.Printf("链接：%s 提取码：%s\n", r.ShareUrl, r.SharePwd)
+			fmt.Fprintf(out, "链接：%s 提取码：%s\n", shareUrl, r.SharePwd)
+		} else {
+			fmt.Fprintf(out, "链接：%s\n", shareUrl)
+		}
+		sharePwd = r.SharePwd
+	}
+
+	if option.AuditLog != "" {
+		files := []string{}
+		for _, f := range allFileList {
+			files = append(files, f.Path)
+		}
+		err := appendShareAuditLog(option.AuditLog, &ShareAuditEvent{
+			Time:    time.Now().Format("2006-01-02 15:04:05"),
+			User:    activeUser.UserId,
+			ShareId: shareId,
+			Url:     shareUrl,
+			Files:   files,
+			Expires: expiredTime,
+			Mode:    shareModeName(modeFlag),
+			Title:   option.LinkTitle,
+		})
+		if err != nil {
+			fmt.Fprintf(out, "写入审计日志失败: %s\n", err)
+		}
+	}
+
+	if option.NotifySlack != "" {
+		files := []string{}
+		for _, f := range allFileList {
+			files = append(files, f.Path)
+		}
+		err := postSlackShareNotification(option.NotifySlack, &ShareCreatedNotification{
+			ShareUrl:    shareUrl,
+			SharePwd:    sharePwd,
+			ExpiredTime: expiredTime,
+			Files:       files,
+			Title:       option.LinkTitle,
+		})
+		if err != nil {
+			fmt.Fprintf(out, "警告: 发送Slack通知失败: %s\n", err)
+		}
+	}
+
+	if option.NotifyDingTalk != "" {
+		files := []string{}
+		for _, f := range allFileList {
+			files = append(files, f.Path)
+		}
+		err := postDingTalkShareNotification(option.NotifyDingTalk, option.DingTalkSecret, &ShareCreatedNotification{
+			ShareUrl:    shareUrl,
+			SharePwd:    sharePwd,
+			ExpiredTime: expiredTime,
+			Files:       files,
+			Title:       option.LinkTitle,
+		})
+		if err != nil {
+			fmt.Fprintf(out, "警告: 发送钉钉通知失败: %s\n", err)
+		}
+	}
+
+	if option.ExpiryWebhook != "" && expiredTime != "" {
+		files := []string{}
+		for _, f := range allFileList {
+			files = append(files, f.Path)
+		}
+		err := AddShareExpiryWatch(&ShareExpiryWatch{
+			ShareId:     shareId,
+			ShareUrl:    shareUrl,
+			ExpiredTime: expiredTime,
+			Webhook:     option.ExpiryWebhook,
+			User:        activeUser.UserId,
+			Files:       files,
+		})
+		if err != nil {
+			fmt.Fprintf(out, "添加分享过期监控失败: %s\n", err)
+		} else {
+			StartShareExpiryWatcher(option.PollInterval)
+			fmt.Fprintf(out, "分享将于 %s 过期，过期后通知: %s\n", expiredTime, option.ExpiryWebhook)
+		}
+	}
+
+	if modeFlag == "1" && option.RotatePasswordEvery > 0 {
+		rotateOptions := &ShareRotateOptions{
+			ExpiredTime:  expiredTime,
+			MaxRotations: option.MaxRotations,
+			AuditLog:     option.AuditLog,
+		}
+		if global.IsAppInCliMode {
+			// 交互模式下在后台更换提取码，不阻塞后续命令
+			go RunShareRotatePassword(shareId, sharePwd, option.RotatePasswordEvery, rotateOptions)
 		} else {
-			fmt.Printf("链接：%s\n", r.ShareUrl)
+			RunShareRotatePassword(shareId, sharePwd, option.RotatePasswordEvery, rotateOptions)
+		}
+	}
+	return shareUrl, sharePwd, nil
+}
+
+// RunShareRotatePassword 每隔 interval 为私密分享更换一次随机提取码，直到分享过期或者达到最多更换次数
+func RunShareRotatePassword(shareId, sharePwd string, interval time.Duration, option *ShareRotateOptions) {
+	if option == nil {
+		option = &ShareRotateOptions{}
+	}
+	auditLog := option.AuditLog
+	if auditLog == "" {
+		os.MkdirAll(config.GetLogDir(), 0755)
+		auditLog = filepath.Join(config.GetLogDir(), "share_password_rotation.log")
+	}
+	var expiredAt time.Time
+	if option.ExpiredTime != "" {
+		expiredAt, _ = time.ParseInLocation("2006-01-02 15:04:05", option.ExpiredTime, time.Local)
+	}
+
+	panClient := GetActivePanClient()
+	fmt.Printf("开始自动更换提取码，间隔: %s, 记录文件: %s\n", interval, auditLog)
+	// 只有更换成功才计入更换次数
+	for rotations := 0; option.MaxRotations <= 0 || rotations < option.MaxRotations; {
+		next := time.Now().Add(interval)
+		if !expiredAt.IsZero() && !next.Before(expiredAt) {
+			fmt.Println("分享即将过期，停止更换提取码")
+			return
+		}
+		time.Sleep(interval)
+
+		newPwd := RandomStr(SharePasswordLength)
+		apierr := shareLinkUpdatePwd(panClient.WebapiPanClient(), shareId, newPwd, option.ExpiredTime)
+		if apierr != nil {
+			fmt.Printf("更换提取码失败: %s\n", apierr)
+			continue
+		}
+		rotations++
+		fmt.Printf("%s 更换提取码成功: %s => %s\n", utils.NowTimeStr(), sharePwd, newPwd)
+		err := appendShareAuditLog(auditLog, &ShareRotateEvent{
+			Time:    time.Now().Format("2006-01-02 15:04:05"),
+			ShareId: shareId,
+			OldPwd:  sharePwd,
+			NewPwd:  newPwd,
+		})
+		if err != nil {
+			fmt.Printf("写入审计日志失败: %s\n", err)
+		}
+		sharePwd = newPwd
+	}
+	fmt.Println("已达到最多更换次数，停止更换提取码")
+}
+
+// shareLinkUpdatePwd 修改分享的提取码, expiration 为空代表永久有效.
+// 接口库没有提供更新分享的方法, 这里通过批量接口调用 /share_link/update
+func shareLinkUpdatePwd(webClient *aliyunpan_web.WebPanClient, shareId, sharePwd, expiration string) *apierror.ApiError {
+	body := map[string]interface{}{
+		"share_id":  shareId,
+		"share_pwd": sharePwd,
+	}
+	if expiration != "" {
+		body["expiration"] = apiutil.LocalTime2UtcFormat(expiration)
+	}
+	r, apierr := webClient.BatchTask(aliyunpan_web.API_URL+"/adrive/v4/batch", &aliyunpan_web.BatchRequestParam{
+		Requests: aliyunpan_web.BatchRequestList{
+			{
+				Id:     shareId,
+				Method: "POST",
+				Url:    "/share_link/update",
+				Headers: map[string]string{
+					"Content-Type": "application/json",
+				},
+				Body: body,
+			},
+		},
+		Resource: "file",
+	})
+	if apierr != nil {
+		return apierr
+	}
+	for _, resp := range r.Responses {
+		if resp.Status != 200 {
+			return apierror.NewFailedApiError(fmt.Sprintf("更新分享失败, 状态码: %d", resp.Status))
 		}
 	}
+	return nil
+}
+
+// copyFilesForPermanentShare 复制要分享的文件到 SharePermanentCopyDir 下新建的目录，返回副本目录和副本的文件ID。
+// 复制失败时删除已经创建的副本目录
+func copyFilesForPermanentShare(out io.Writer, driveId string, fileList []*aliyunpan.FileEntity) (copyDir *aliyunpan.FileEntity, fidList []string, err error) {
+	// 副本目录按时间命名并且从目录中读取副本，依次复制避免多个分享使用同一个副本目录
+	sharePermanentCopyMutex.Lock()
+	defer sharePermanentCopyMutex.Unlock()
+
+	panClient := GetActivePanClient()
+	copyDirPath := path.Join(SharePermanentCopyDir, time.Now().Format("20060102150405.000"))
+	mkdirResult, apierr := panClient.OpenapiPanClient().MkdirByFullPath(driveId, copyDirPath)
+	if apierr != nil {
+		return nil, nil, apierr
+	}
+	if mkdirResult == nil || mkdirResult.FileId == "" {
+		return nil, nil, fmt.Errorf("创建目录失败: %s", copyDirPath)
+	}
+	copyDir = &aliyunpan.FileEntity{
+		DriveId:  driveId,
+		FileId:   mkdirResult.FileId,
+		FileName: path.Base(copyDirPath),
+		Path:     copyDirPath,
+	}
+	defer func() {
+		if err != nil {
+			removeShareCopyDir(out, driveId, copyDir)
+			copyDir = nil
+		}
+	}()
+	for _, f := range fileList {
+		_, apierr = panClient.OpenapiPanClient().FileCopy(&aliyunpan.FileCopyParam{
+			DriveId:        driveId,
+			FileId:         f.FileId,
+			ToParentFileId: copyDir.FileId,
+		})
+		if apierr != nil {
+			return nil, nil, fmt.Errorf("%s: %s", f.Path, apierr)
+		}
+	}
+
+	// 复制接口不一定返回新文件ID，从目标目录获取副本
+	copyList, apierr := panClient.OpenapiPanClient().FileListGetAll(&aliyunpan.FileListParam{
+		DriveId:      driveId,
+		ParentFileId: copyDir.FileId,
+	}, 500)
+	if apierr != nil {
+		return nil, nil, apierr
+	}
+	if len(copyList) == 0 {
+		return nil, nil, fmt.Errorf("目录为空: %s", copyDirPath)
+	}
+	for _, f := range copyList {
+		fidList = append(fidList, f.FileId)
+	}
+	fmt.Fprintf(out, "已复制 %d 个文件/目录到: %s\n", len(fidList), copyDirPath)
+	return copyDir, fidList, nil
+}
+
+// removeShareCopyDir 分享创建失败时删除永久链接的副本目录，copyDir 为空则不处理
+func removeShareCopyDir(out io.Writer, driveId string, copyDir *aliyunpan.FileEntity) {
+	if copyDir == nil {
+		return
+	}
+	r, apierr := GetActivePanClient().OpenapiPanClient().FileDelete(&aliyunpan.FileBatchActionParam{
+		DriveId: driveId,
+		FileId:  copyDir.FileId,
+	})
+	if apierr != nil || r == nil || !r.Success {
+		fmt.Fprintf(out, "警告: 删除副本目录失败, 请手动删除: %s\n", copyDir.Path)
+		return
+	}
+	fmt.Fprintf(out, "已删除副本目录: %s\n", copyDir.Path)
+}
+
+// printShareLinkTitle 输出分享链接的标题
+func printShareLinkTitle(out io.Writer, title string) {
+	if title != "" {
+		fmt.Fprintf(out, "标题：%s\n", title)
+	}
+}
+
+// shareModeName 分享模式的名称
+func shareModeName(modeFlag string) string {
+	switch modeFlag {
+	case "1":
+		return "private"
+	case "2":
+		return "public"
+	}
+	return "fast"
+}
+
+// appendShareAuditLog 以追加方式写入一行审计记录，文件不存在则创建
+func appendShareAuditLog(logPath string, event interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// 一次写入完整的一行，O_APPEND 保证多个进程同时写入时不会互相覆盖
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// printShareSetDryRun 输出将要创建的分享内容
+func printShareSetDryRun(out io.Writer, modeFlag, expiredTime, sharePwd string, fileList []*aliyunpan.FileEntity) {
+	modeName := "快传"
+	if modeFlag == "1" {
+		modeName = "私密分享"
+	} else if modeFlag == "2" {
+		modeName = "公开分享"
+	}
+	et := "永久有效"
+	if expiredTime != "" {
+		et = expiredTime
+	}
+	fmt.Fprintf(out, "测试模式，不会创建分享\n")
+	fmt.Fprintf(out, "模式：%s, 过期时间：%s", modeName, et)
+	if sharePwd != "" {
+		fmt.Fprintf(out, ", 提取码：%s", sharePwd)
+	}
+	fmt.Fprintf(out, "\n将会分享以下 %d 个文件/目录:\n", len(fileList))
+
+	tb := cmdtable.NewTable(out)
+	tb.SetHeader([]string{"#", "文件ID", "类型", "文件大小", "路径"})
+	for k, f := range fileList {
+		fileType, fileSize := "文件", converter.ConvertFileSize(f.FileSize, 2)
+		if f.IsFolder() {
+			fileType, fileSize = "目录", "-"
+		}
+		tb.Append([]string{strconv.Itoa(k + 1), f.FileId, fileType, fileSize, f.Path})
+	}
+	tb.Render()
+}
+
+// shareLinkListWithPageSize 按指定的分页大小获取所有分享链接, pageSize 为0或者默认值时直接使用接口库的 ShareLinkList
+func shareLinkListWithPageSize(activeUser *config.PanUser, pageSize int) ([]*aliyunpan_web.ShareEntity, *apierror.ApiError) {
+	webClient := activeUser.PanClient().WebapiPanClient()
+	if pageSize <= 0 || pageSize == DefaultShareListPageSize {
+		return webClient.ShareLinkList(activeUser.UserId)
+	}
+
+	records := []*aliyunpan_web.ShareEntity{}
+	param := aliyunpan_web.ShareListParam{
+		Creator: activeUser.UserId,
+		Limit:   int64(pageSize),
+	}
+	for {
+		r, err := webClient.GetShareLinkListReq(param)
+		if err != nil {
+			return nil, err
+		}
+		// 接口库中转换分享记录的 createShareEntity 没有导出, 这里的字段转换和它保持一致
+		for _, item := range r.Items {
+			record := &aliyunpan_web.ShareEntity{
+				Creator:    item.Creator,
+				DriveId:    item.DriveId,
+				ShareId:    item.ShareId,
+				ShareName:  item.ShareName,
+				SharePwd:   item.SharePwd,
+				ShareUrl:   item.ShareUrl,
+				FileIdList: item.FileIdList,
+				SaveCount:  item.SaveCount,
+				Status:     item.Status,
+				Expiration: apiutil.UtcTime2LocalFormat(item.Expiration),
+				UpdatedAt:  apiutil.UtcTime2LocalFormat(item.UpdatedAt),
+				CreatedAt:  apiutil.UtcTime2LocalFormat(item.CreatedAt),
+			}
+			if f := item.FirstFile; f != nil {
+				record.FirstFile = &aliyunpan.FileEntity{
+					DriveId:         f.DriveId,
+					DomainId:        f.DomainId,
+					FileId:          f.FileId,
+					FileName:        f.Name,
+					FileSize:        f.Size,
+					FileType:        f.Type,
+					CreatedAt:       apiutil.UtcTime2LocalFormat(f.CreatedAt),
+					UpdatedAt:       apiutil.UtcTime2LocalFormat(f.UpdatedAt),
+					FileExtension:   f.FileExtension,
+					UploadId:        f.UploadId,
+					ParentFileId:    f.ParentFileId,
+					Crc64Hash:       f.Crc64Hash,
+					ContentHash:     f.ContentHash,
+					ContentHashName: f.ContentHashName,
+					Path:            f.Name,
+					Category:        f.Category,
+					SyncFlag:        f.SyncFlag,
+					SyncMeta:        f.SyncMeta,
+				}
+			}
+			records = append(records, record)
+		}
+
+		// 下一页
+		if r.NextMarker == "" {
+			break
+		}
+		param.Marker = r.NextMarker
+		time.Sleep(500 * time.Millisecond)
+	}
+	return records, nil
 }
 
 // RunShareList 执行列出分享列表
-func RunShareList() {
+func RunShareList(option *ShareListOptions) {
+	if option == nil {
+		option = &ShareListOptions{}
+	}
 	activeUser := GetActiveUser()
-	records, err := activeUser.PanClient().WebapiPanClient().ShareLinkList(activeUser.UserId)
+	records, err := shareLinkListWithPageSize(activeUser, option.PageSize)
 	if err != nil {
 		fmt.Printf("获取分享列表失败: %s\n", err)
 		return
 	}
+	if option.ShareId != "" {
+		// 分享列表接口不支持按ID查询，获取全部分享后在本地过滤
+		idx := -1
+		for k, record := range records {
+			if record.ShareId == option.ShareId {
+				idx = k
+				break
+			}
+		}
+		if idx < 0 {
+			fmt.Printf("分享不存在: %s\n", option.ShareId)
+			return
+		}
+		record := records[idx]
+		et := "永久有效"
+		if len(record.Expiration) > 0 {
+			et = record.Expiration
+		}
+		driveId := "-"
+		if record.FirstFile != nil {
+			driveId = record.FirstFile.DriveId
+		}
+		tb := cmdtable.NewTable(os.Stdout)
+		tb.SetHeader([]string{"名称", "值"})
+		tb.AppendBulk([][]string{
+			{"分享ID", record.ShareId},
+			{"分享名称", record.ShareName},
+			{"分享链接", record.ShareUrl},
+			{"提取码", record.SharePwd},
+			{"状态", shareStatusText(record.Status, record.Expiration, record.FirstFile == nil, time.Now())},
+			{"过期时间", et},
+			{"创建时间", record.CreatedAt},
+			{"网盘ID", driveId},
+			{"文件数", strconv.Itoa(len(record.FileIdList))},
+		})
+		for k, fileId := range record.FileIdList {
+			tb.Append([]string{fmt.Sprintf("文件ID[%d]", k+1), fileId})
+		}
+		tb.Render()
+		return
+	}
+
+	// 监控的比较基准使用过滤前的完整列表, 否则被 -limit 等选项过滤掉的旧分享会被当成新分享显示
+	var seen map[string]bool
+	if option.Watch {
+		seen = make(map[string]bool, len(records))
+		for _, record := range records {
+			seen[record.ShareId] = true
+		}
+	}
+	if option.ActiveOnly {
+		records = filterActiveShares(records, time.Now())
+	}
+	if option.Reverse {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+	if option.Limit > 0 && len(records) > option.Limit {
+		records = records[:option.Limit]
+	}
+
+	if option.CountOnly {
+		fmt.Println(len(records))
+		return
+	}
 
+	if option.JSON {
+		// 状态和过期时间和 share export 的JSON格式保持一致
+		items := make([]shareListJSONItem, 0, len(records))
+		now := time.Now()
+		for _, record := range records {
+			et := "永久有效"
+			if len(record.Expiration) > 0 {
+				et = record.Expiration
+			}
+			items = append(items, shareListJSONItem{
+				ShareId:    record.ShareId,
+				ShareUrl:   record.ShareUrl,
+				SharePwd:   record.SharePwd,
+				ShareName:  record.ShareName,
+				Expiration: et,
+				Status:     shareStatusText(record.Status, record.Expiration, record.FirstFile == nil, now),
+				CreatedAt:  record.CreatedAt,
+			})
+		}
+		data, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			fmt.Printf("生成JSON失败: %s\n", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	header := []string{"#", "ShARE_ID", "分享链接", "提取码", "文件名", "过期时间", "状态"}
+	if option.OutputWide {
+		header = append(header, "文件数", "总大小", "DRIVE_ID", "创建时间")
+	}
 	tb := cmdtable.NewTable(os.Stdout)
-	tb.SetHeader([]string{"#", "ShARE_ID", "分享链接", "提取码", "文件名", "过期时间", "状态"})
+	tb.SetHeader(header)
 	now := time.Now()
+	shareFileCache := map[string]*aliyunpan.FileEntity{}
 	for k, record := range records {
 		et := "永久有效"
 		if len(record.Expiration) > 0 {
 			et = record.Expiration
 		}
-		status := "有效"
-		if record.Status == "enabled" {
-			if record.FirstFile == nil {
-				status = "已删除"
-			} else {
-				cz := time.FixedZone("CST", 8*3600)
-				if len(record.Expiration) > 0 {
-					expiredTime, _ := time.ParseInLocation("2006-01-02 15:04:05", record.Expiration, cz)
-					if expiredTime.Unix() < now.Unix() {
-						status = "已过期"
-					}
-				}
-			}
-		} else if record.Status == "forbidden" {
-			status = "违规"
-		}
+		status := shareStatusText(record.Status, record.Expiration, record.FirstFile == nil, now)
 
-		tb.Append([]string{strconv.Itoa(k + 1), record.ShareId, record.ShareUrl, record.SharePwd,
+		line := []string{strconv.Itoa(k + 1), record.ShareId, record.ShareUrl, record.SharePwd,
 			record.ShareName,
 			//record.FileIdList[0],
 			et,
-			status})
+			status}
+		if option.OutputWide {
+			driveId, totalSize := "-", "-"
+			if record.FirstFile != nil {
+				driveId = record.FirstFile.DriveId
+				totalSize = getShareTotalSize(driveId, record.FileIdList, option.TimeoutPerShare)
+			}
+			line = append(line, strconv.Itoa(len(record.FileIdList)), totalSize, driveId, record.CreatedAt)
+		}
+		tb.Append(line)
+
+		if option.WithFiles && record.FirstFile != nil {
+			for _, fileLine := range getShareFileLines(record, shareFileCache) {
+				subLine := make([]string, len(header))
+				subLine[4] = "  " + fileLine
+				tb.Append(subLine)
+			}
+		}
 	}
 	tb.Render()
+
+	if option.Watch {
+		runShareListWatch(activeUser, option, seen)
+	}
+}
+
+// runShareListWatch 每隔 option.Interval 查询一次分享列表，与之前的结果按 ShareId 比较，只显示新出现的分享
+func runShareListWatch(activeUser *config.PanUser, option *ShareListOptions, seen map[string]bool) {
+	fmt.Printf("\n开始监控新分享，间隔: %s，按 Ctrl+C 退出\n", option.Interval)
+	for {
+		time.Sleep(option.Interval)
+		records, err := shareLinkListWithPageSize(activeUser, option.PageSize)
+		if err != nil {
+			// 网络波动等临时错误，下一轮继续
+			fmt.Printf("%s 获取分享列表失败: %s\n", utils.NowTimeStr(), err)
+			continue
+		}
+
+		current := make(map[string]bool, len(records))
+		now := time.Now()
+		for _, record := range records {
+			current[record.ShareId] = true
+			if seen[record.ShareId] {
+				continue
+			}
+			if option.ActiveOnly && shareStatusText(record.Status, record.Expiration, record.FirstFile == nil, now) != "有效" {
+				continue
+			}
+			et := "永久有效"
+			if len(record.Expiration) > 0 {
+				et = record.Expiration
+			}
+			fmt.Printf("%s 新分享: %s %s 提取码: %s 文件名: %s 过期时间: %s 状态: %s\n",
+				utils.NowTimeStr(), record.ShareId, record.ShareUrl, record.SharePwd, record.ShareName, et,
+				shareStatusText(record.Status, record.Expiration, record.FirstFile == nil, now))
+		}
+		// 使用本次的结果作为下一轮比较的基准，已取消的分享重新出现时也会显示
+		seen = current
+	}
+}
+
+// filterActiveShares 只保留状态为有效的分享
+func filterActiveShares(records []*aliyunpan_web.ShareEntity, now time.Time) []*aliyunpan_web.ShareEntity {
+	result := make([]*aliyunpan_web.ShareEntity, 0, len(records))
+	for _, record := range records {
+		if shareStatusText(record.Status, record.Expiration, record.FirstFile == nil, now) == "有效" {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+// shareStatusText 分享状态的显示文本
+func shareStatusText(recordStatus, expiration string, fileDeleted bool, now time.Time) string {
+	status := "有效"
+	if recordStatus == "enabled" {
+		if fileDeleted {
+			status = "已删除"
+		} else {
+			cz := time.FixedZone("CST", 8*3600)
+			if len(expiration) > 0 {
+				expiredTime, _ := time.ParseInLocation("2006-01-02 15:04:05", expiration, cz)
+				if expiredTime.Unix() < now.Unix() {
+					status = "已过期"
+				}
+			}
+		}
+	} else if recordStatus == "forbidden" {
+		status = "违规"
+	}
+	return status
+}
+
+// getShareTotalSize 获取分享包含的文件总大小，目录统计其中所有文件的大小，超过 timeout 则返回超时
+func getShareTotalSize(driveId string, fileIdList []string, timeout time.Duration) string {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	panClient := GetActivePanClient()
+	var totalSize int64
+	for _, fileId := range fileIdList {
+		if ctx.Err() != nil {
+			return "超时"
+		}
+		fe, err := panClient.OpenapiPanClient().FileInfoById(driveId, fileId)
+		if err != nil || fe == nil {
+			continue
+		}
+		size, ok := getShareFileSize(ctx, driveId, fe)
+		if !ok {
+			return "超时"
+		}
+		totalSize += size
+	}
+	return converter.ConvertFileSize(totalSize, 2)
+}
+
+// getShareFileSize 递归统计文件或目录的大小，每次请求前检查 ctx，超时返回 false
+func getShareFileSize(ctx context.Context, driveId string, fe *aliyunpan.FileEntity) (int64, bool) {
+	if !fe.IsFolder() {
+		return fe.FileSize, true
+	}
+	if ctx.Err() != nil {
+		return 0, false
+	}
+	fileList, apierr := GetActivePanClient().OpenapiPanClient().FileListGetAll(&aliyunpan.FileListParam{
+		DriveId:      driveId,
+		ParentFileId: fe.FileId,
+	}, 0)
+	if apierr != nil {
+		return 0, true
+	}
+	var totalSize int64
+	for _, f := range fileList {
+		size, ok := getShareFileSize(ctx, driveId, f)
+		if !ok {
+			return 0, false
+		}
+		totalSize += size
+	}
+	return totalSize, true
+}
+
+// getShareFileLines 获取分享包含的前 ShareListMaxFiles 个文件的路径和大小，超出的部分只显示剩余数量，文件已被删除的则显示文件ID.
+// 分享记录中已经包含第一个文件的信息，不再重复查询，查询过的文件保存在 cache 中
+func getShareFileLines(record *aliyunpan_web.ShareEntity, cache map[string]*aliyunpan.FileEntity) []string {
+	panClient := GetActivePanClient()
+	if record.FirstFile.FileId != "" {
+		cache[record.FirstFile.FileId] = record.FirstFile
+	}
+	fileIdList := record.FileIdList
+	if len(fileIdList) > ShareListMaxFiles {
+		fileIdList = fileIdList[:ShareListMaxFiles]
+	}
+	lines := make([]string, 0, len(fileIdList)+1)
+	for _, fileId := range fileIdList {
+		fe, ok := cache[fileId]
+		if !ok {
+			fe, _ = panClient.OpenapiPanClient().FileInfoById(record.FirstFile.DriveId, fileId)
+			cache[fileId] = fe
+		}
+		if fe == nil {
+			lines = append(lines, fileId+" (已删除)")
+			continue
+		}
+		name := fe.Path
+		if name == "" {
+			name = fe.FileName
+		}
+		if fe.IsFolder() {
+			lines = append(lines, name+"/")
+		} else {
+			lines = append(lines, name+"  "+converter.ConvertFileSize(fe.FileSize, 2))
+		}
+	}
+	if n := len(record.FileIdList) - len(fileIdList); n > 0 {
+		lines = append(lines, fmt.Sprintf("… 还有 %d 个文件", n))
+	}
+	return lines
 }
 
 // RunShareCancel 执行取消分享
-func RunShareCancel(shareIdList []string) {
+func RunShareCancel(shareIdList []string, option *ShareCancelOptions) {
 	if len(shareIdList) == 0 {
 		fmt.Printf("取消分享操作失败, 没有任何 shareid\n")
 		return
 	}
+	if option == nil {
+		option = &ShareCancelOptions{}
+	}
+
+	shareIdList, err := resolveShareCancelIds(shareIdList)
+	if err != nil {
+		fmt.Printf("取消分享操作失败: %s\n", err)
+		return
+	}
+	if len(shareIdList) == 0 {
+		fmt.Printf("没有匹配的分享\n")
+		return
+	}
 
 	activeUser := GetActiveUser()
+	if option.DryRun {
+		printShareCancelDryRun(shareIdList)
+		return
+	}
 	r, err := activeUser.PanClient().WebapiPanClient().ShareLinkCancel(shareIdList)
 	if err != nil {
 		fmt.Printf("取消分享操作失败: %s\n", err)
@@ -374,7 +1903,65 @@ func RunShareCancel(shareIdList []string) {
 	}
 }
 
-func RunShareExport(option, saveFilePath string) {
+// isShareIdArg 参数是否是分享ID, 分享ID只包含字母和数字
+func isShareIdArg(arg string) bool {
+	if arg == "" {
+		return false
+	}
+	for _, c := range arg {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveShareCancelIds 将参数中的通配符按照分享名称匹配为分享ID, 返回去重后的分享ID列表
+func resolveShareCancelIds(args []string) ([]string, error) {
+	shareIdList := []string{}
+	patterns := []string{}
+	seen := map[string]bool{}
+	for _, arg := range args {
+		if !isShareIdArg(arg) {
+			if _, err := path.Match(arg, ""); err != nil {
+				return nil, fmt.Errorf("通配符格式错误: %s", arg)
+			}
+			patterns = append(patterns, arg)
+			continue
+		}
+		if !seen[arg] {
+			seen[arg] = true
+			shareIdList = append(shareIdList, arg)
+		}
+	}
+	if len(patterns) == 0 {
+		return shareIdList, nil
+	}
+
+	activeUser := GetActiveUser()
+	records, err := activeUser.PanClient().WebapiPanClient().ShareLinkList(activeUser.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("获取分享列表失败: %s", err)
+	}
+	for _, pattern := range patterns {
+		count := 0
+		for _, record := range records {
+			if matched, _ := path.Match(pattern, record.ShareName); !matched {
+				continue
+			}
+			count++
+			if !seen[record.ShareId] {
+				seen[record.ShareId] = true
+				shareIdList = append(shareIdList, record.ShareId)
+			}
+		}
+		fmt.Printf("通配符 %s 匹配到 %d 个分享\n", pattern, count)
+	}
+	return shareIdList, nil
+}
+
+// printShareCancelDryRun 显示将要取消的分享, 不实际取消
+func printShareCancelDryRun(shareIdList []string) {
 	activeUser := GetActiveUser()
 	records, err := activeUser.PanClient().WebapiPanClient().ShareLinkList(activeUser.UserId)
 	if err != nil {
@@ -382,10 +1969,83 @@ func RunShareExport(option, saveFilePath string) {
 		return
 	}
 
-	columns := [][]string{{"序号", "分享ID", "分享链接", "提取码", "文件名", "过期时间", "状态"}}
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "ShARE_ID", "文件名", "状态", "过期时间"})
+	now := time.Now()
+	for k, shareId := range shareIdList {
+		line := []string{strconv.Itoa(k + 1), shareId, "-", "未找到", "-"}
+		for _, record := range records {
+			if record.ShareId != shareId {
+				continue
+			}
+			et := "永久有效"
+			if len(record.Expiration) > 0 {
+				et = record.Expiration
+			}
+			line = []string{strconv.Itoa(k + 1), record.ShareId, record.ShareName,
+				shareStatusText(record.Status, record.Expiration, record.FirstFile == nil, now), et}
+			break
+		}
+		tb.Append(line)
+	}
+	tb.Render()
+	fmt.Printf("dry-run: 以上 %d 个分享将被取消，未执行任何操作\n", len(shareIdList))
+}
+
+// RunShareExport 导出分享，splitSize 大于0时csv文件按每个文件最多 splitSize 条记录拆分保存
+func RunShareExport(option, saveFilePath, format string, splitSize int) {
+	runShareExport(option, saveFilePath, format, nil, splitSize)
+}
+
+// RunShareExportIncremental 增量导出分享，只导出上一次导出的csv文件中不存在的分享
+func RunShareExportIncremental(previousCsvPath, outputPath, format string, splitSize int) {
+	knownShareIds, err := loadExportedShareIds(previousCsvPath)
+	if err != nil {
+		fmt.Printf("读取上一次导出的分享文件失败: %s\n", err)
+		return
+	}
+	runShareExport("2", outputPath, format, knownShareIds, splitSize)
+}
+
+// loadExportedShareIds 读取 share export 导出的csv文件中的分享ID
+func loadExportedShareIds(csvPath string) (map[string]bool, error) {
+	fp, err := os.Open(csvPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	rows, err := csv.NewReader(fp).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	shareIds := map[string]bool{}
+	for k, row := range rows {
+		if k == 0 || len(row) < 2 {
+			// 跳过表头
+			continue
+		}
+		shareIds[row[1]] = true
+	}
+	return shareIds, nil
+}
+
+// runShareExport 导出分享，knownShareIds 中的分享会被忽略
+func runShareExport(option, saveFilePath, format string, knownShareIds map[string]bool, splitSize int) {
+	activeUser := GetActiveUser()
+	records, err := activeUser.PanClient().WebapiPanClient().ShareLinkList(activeUser.UserId)
+	if err != nil {
+		fmt.Printf("获取分享列表失败: %s\n", err)
+		return
+	}
+
+	columns := [][]string{shareExportCsvHeader}
+	items := make([]shareListJSONItem, 0, len(records))
 	now := time.Now()
 	idx := 1
 	for _, record := range records {
+		if knownShareIds[record.ShareId] {
+			continue
+		}
 		et := "永久有效"
 		if len(record.Expiration) > 0 {
 			et = record.Expiration
@@ -411,17 +2071,192 @@ func RunShareExport(option, saveFilePath string) {
 				continue
 			}
 		}
-		line := []string{strconv.Itoa(idx), record.ShareId, record.ShareUrl, record.SharePwd, record.ShareName, et, status}
+		line := []string{strconv.Itoa(idx), record.ShareId, record.ShareUrl, record.SharePwd, record.ShareName, et, status, record.CreatedAt}
 		idx += 1
 		columns = append(columns, line)
+		items = append(items, shareListJSONItem{
+			ShareId:    record.ShareId,
+			ShareUrl:   record.ShareUrl,
+			SharePwd:   record.SharePwd,
+			ShareName:  record.ShareName,
+			Expiration: et,
+			Status:     status,
+			CreatedAt:  record.CreatedAt,
+		})
+	}
+
+	if knownShareIds != nil {
+		fmt.Printf("新增分享 %d 个\n", idx-1)
 	}
 
 	// save to file
+	if format == ShareExportFormatJson {
+		if ExportJson(saveFilePath, items) {
+			fmt.Println("分享导出成功：", saveFilePath)
+		}
+		return
+	}
+	if splitSize > 0 {
+		for _, f := range ExportCsvSplit(saveFilePath, columns, splitSize) {
+			fmt.Println("分享导出成功：", f)
+		}
+		return
+	}
 	if ExportCsv(saveFilePath, columns) {
 		fmt.Println("分享导出成功：", saveFilePath)
 	}
 }
 
+// RunShareImport 读取 ExportCsv 导出的分享记录, 按文件名查找文件并重新创建分享
+func RunShareImport(filePath string) {
+	fp, err := os.Open(filePath)
+	if err != nil {
+		fmt.Printf("打开文件失败: %s\n", err)
+		return
+	}
+	defer fp.Close()
+	rows, err := csv.NewReader(fp).ReadAll()
+	if err != nil {
+		fmt.Printf("读取文件失败: %s\n", err)
+		return
+	}
+
+	activeUser := GetActiveUser()
+	panClient := activeUser.PanClient()
+	driveId := activeUser.ActiveDriveId
+	now := time.Now()
+	successCount := 0
+	for k, row := range rows {
+		if k == 0 {
+			// 跳过表头
+			continue
+		}
+		if len(row) < 7 {
+			fmt.Printf("第 %d 行格式错误，跳过\n", k+1)
+			continue
+		}
+		var (
+			shareUrl   = row[2]
+			sharePwd   = row[3]
+			fileName   = row[4]
+			expiration = row[5]
+		)
+		if expiration == "永久有效" {
+			expiration = ""
+		}
+		if expiration != "" {
+			cz := time.FixedZone("CST", 8*3600)
+			expiredTime, _ := time.ParseInLocation("2006-01-02 15:04:05", expiration, cz)
+			if expiredTime.Unix() < now.Unix() {
+				fmt.Printf("分享已过期，跳过: %s\n", fileName)
+				continue
+			}
+		}
+
+		absolutePath := path.Clean(activeUser.PathJoin(driveId, fileName))
+		fileInfo, apierr := panClient.OpenapiPanClient().FileInfoByPath(driveId, absolutePath)
+		if apierr != nil || fileInfo == nil {
+			fmt.Printf("文件不存在，跳过: %s\n", absolutePath)
+			continue
+		}
+		time.Sleep(200 * time.Millisecond)
+
+		if strings.Contains(shareUrl, "/t/") {
+			// 快传
+			r, err1 := panClient.WebapiPanClient().FastShareLinkCreate(aliyunpan_web.FastShareCreateParam{
+				DriveId:    driveId,
+				FileIdList: []string{fileInfo.FileId},
+			})
+			if err1 != nil || r == nil {
+				fmt.Printf("创建快传链接失败: %s, %s\n", absolutePath, err1)
+				continue
+			}
+			fmt.Printf("创建快传链接成功: %s 链接：%s\n", absolutePath, r.ShareUrl)
+		} else {
+			r, err1 := panClient.WebapiPanClient().ShareLinkCreate(aliyunpan_web.ShareCreateParam{
+				DriveId:    driveId,
+				SharePwd:   sharePwd,
+				Expiration: expiration,
+				FileIdList: []string{fileInfo.FileId},
+			})
+			if err1 != nil || r == nil {
+				fmt.Printf("创建分享链接失败: %s, %s\n", absolutePath, err1)
+				continue
+			}
+			if len(r.SharePwd) > 0 {
+				fmt.Printf("创建分享链接成功: %s 链接：%s 提取码：%s\n", absolutePath, r.ShareUrl, r.SharePwd)
+			} else {
+				fmt.Printf("创建分享链接成功: %s 链接：%s\n", absolutePath, r.ShareUrl)
+			}
+		}
+		successCount++
+	}
+	fmt.Printf("导入完成，共重新创建分享 %d 个\n", successCount)
+}
+
+// DeriveSharePassword 根据密钥和文件hash派生确定性的分享密码
+//
+// 派生算法: 取每个文件的 ContentHash(目录没有hash则使用 FileId), 排序后用换行符拼接成消息,
+// 计算 HMAC-SHA256(secret, 消息), 取结果的前4个字节, 每个字节对字符集长度取余映射为 [a-z0-9] 中的字符.
+// 相同的密钥和文件总是得到相同的密码.
+func DeriveSharePassword(secret string, files []*aliyunpan.FileEntity) string {
+	keys := make([]string, 0, len(files))
+	for _, f := range files {
+		if f.ContentHash != "" {
+			keys = append(keys, strings.ToUpper(f.ContentHash))
+		} else {
+			keys = append(keys, f.FileId)
+		}
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join(keys, "\n")))
+	sum := mac.Sum(nil)
+
+	pwd := strings.Builder{}
+	for i := 0; i < SharePasswordLength; i++ {
+		pwd.WriteByte(SharePasswordCharset[int(sum[i])%len(SharePasswordCharset)])
+	}
+	return pwd.String()
+}
+
+// ShareWatermark 计算接收者的水印，即 HMAC-SHA256(secret, user) 前8个字节的十六进制
+func ShareWatermark(user, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(user))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// WatermarkShareUrl 在分享链接后附加接收者的水印参数 w
+func WatermarkShareUrl(shareUrl, user, secret string) string {
+	sep := "?"
+	if strings.Contains(shareUrl, "?") {
+		sep = "&"
+	}
+	return shareUrl + sep + "w=" + ShareWatermark(user, secret)
+}
+
+// ExportJson 将数据以两个空格缩进的json格式保存到文件
+func ExportJson(savePath string, data interface{}) bool {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		fmt.Printf("生成JSON失败: %s\n", err)
+		return false
+	}
+	folder := filepath.Dir(savePath)
+	if _, err := os.Stat(folder); err != nil {
+		if !os.IsExist(err) {
+			os.MkdirAll(folder, os.ModePerm)
+		}
+	}
+	if err = os.WriteFile(savePath, content, 0644); err != nil {
+		fmt.Printf("创建文件[%s]失败, %s\n", savePath, err)
+		return false
+	}
+	return true
+}
+
 func ExportCsv(savePath string, data [][]string) bool {
 	folder := filepath.Dir(savePath)
 	if _, err := os.Stat(folder); err != nil {
@@ -441,3 +2276,36 @@ func ExportCsv(savePath string, data [][]string) bool {
 	w.Flush()
 	return true
 }
+
+// ExportCsvSplit 将数据拆分保存到多个csv文件，data 的第一行为表头，每个文件都包含表头和最多 splitSize 条记录。
+// 文件命名为 <base>_001.csv、<base>_002.csv ...，base 为 basePath 去掉 .csv 后缀。返回成功创建的文件路径
+func ExportCsvSplit(basePath string, data [][]string, splitSize int) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	if splitSize <= 0 {
+		if ExportCsv(basePath, data) {
+			return []string{basePath}
+		}
+		return nil
+	}
+	base := basePath
+	if strings.EqualFold(filepath.Ext(base), ".csv") {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	header, records := data[0], data[1:]
+	files := []string{}
+	for i := 0; i == 0 || i < len(records); i += splitSize {
+		end := i + splitSize
+		if end > len(records) {
+			end = len(records)
+		}
+		part := append([][]string{header}, records[i:end]...)
+		savePath := fmt.Sprintf("%s_%03d.csv", base, len(files)+1)
+		if !ExportCsv(savePath, part) {
+			break
+		}
+		files = append(files, savePath)
+	}
+	return files
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan_web"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/aliyunpan/internal/utils"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultShareAutoRefreshValidity 无法计算原分享有效期时，重建分享使用的有效期
+	DefaultShareAutoRefreshValidity = 7 * 24 * time.Hour
+)
+
+type (
+	// shareAutoRefreshItem 过期后需要自动重建的分享
+	shareAutoRefreshItem struct {
+		shareId    string
+		driveId    string
+		fileIdList []string
+		sharePwd   string
+		validity   time.Duration // 分享的有效期，重建时使用相同的有效期
+		expiredAt  time.Time
+	}
+
+	// ShareRefreshEvent 分享重建记录，保存旧分享和新分享的对应关系
+	ShareRefreshEvent struct {
+		Time        string `json:"time"`
+		OldShareId  string `json:"oldShareId"`
+		NewShareId  string `json:"newShareId"`
+		ShareUrl    string `json:"shareUrl"`
+		SharePwd    string `json:"sharePwd"`
+		ExpiredTime string `json:"expiredTime"`
+	}
+)
+
+// shareAutoRefreshLogPath 分享重建记录的保存路径
+func shareAutoRefreshLogPath() string {
+	os.MkdirAll(config.GetLogDir(), 0755)
+	return filepath.Join(config.GetLogDir(), "share_auto_refresh.log")
+}
+
+// RunShareAutoRefresh 每隔 pollInterval 检查一次指定的分享，过期后使用相同的文件、提取码和有效期重新创建分享。
+// 旧分享和新分享的对应关系追加到记录文件，分享过期监控列表中的旧分享也会替换为新分享。按 Ctrl+C 退出
+func RunShareAutoRefresh(shareIds []string, pollInterval time.Duration) {
+	if pollInterval <= 0 {
+		pollInterval = DefaultShareExpiryPollInterval
+	}
+	activeUser := GetActiveUser()
+	records, err := activeUser.PanClient().WebapiPanClient().ShareLinkList(activeUser.UserId)
+	if err != nil {
+		fmt.Printf("获取分享列表失败: %s\n", err)
+		return
+	}
+
+	cz := time.FixedZone("CST", 8*3600)
+	items := []*shareAutoRefreshItem{}
+	for _, shareId := range shareIds {
+		idx := -1
+		for k, record := range records {
+			if record.ShareId == shareId {
+				idx = k
+				break
+			}
+		}
+		if idx < 0 {
+			fmt.Printf("分享不存在，跳过: %s\n", shareId)
+			continue
+		}
+		record := records[idx]
+		if record.Expiration == "" {
+			fmt.Printf("永久有效的分享不会过期，跳过: %s\n", shareId)
+			continue
+		}
+		if record.FirstFile == nil {
+			fmt.Printf("分享的文件已删除，跳过: %s\n", shareId)
+			continue
+		}
+		expiredAt, er := time.ParseInLocation("2006-01-02 15:04:05", record.Expiration, cz)
+		if er != nil {
+			fmt.Printf("无法解析分享的过期时间，跳过: %s, %s\n", shareId, record.Expiration)
+			continue
+		}
+		validity := DefaultShareAutoRefreshValidity
+		if createdAt, er := time.ParseInLocation("2006-01-02 15:04:05", record.CreatedAt, cz); er == nil && expiredAt.After(createdAt) {
+			validity = expiredAt.Sub(createdAt)
+		}
+		items = append(items, &shareAutoRefreshItem{
+			shareId:    record.ShareId,
+			driveId:    record.FirstFile.DriveId,
+			fileIdList: record.FileIdList,
+			sharePwd:   record.SharePwd,
+			validity:   validity,
+			expiredAt:  expiredAt,
+		})
+	}
+	if len(items) == 0 {
+		fmt.Println("没有需要自动重建的分享")
+		return
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	logPath := shareAutoRefreshLogPath()
+	fmt.Printf("开始监控 %d 个分享，过期后自动重建，检查间隔: %s，记录文件: %s，按 Ctrl+C 退出\n", len(items), pollInterval, logPath)
+	refreshed := 0
+	for {
+		now := time.Now()
+		for _, item := range items {
+			if now.Before(item.expiredAt) {
+				continue
+			}
+			if refreshExpiredShare(item, logPath) {
+				refreshed++
+			}
+		}
+		select {
+		case <-sigChan:
+			fmt.Printf("\n停止自动重建分享，共重建 %d 次\n", refreshed)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshExpiredShare 重新创建已过期的分享，失败时下次检查重试
+func refreshExpiredShare(item *shareAutoRefreshItem, logPath string) bool {
+	now := time.Now()
+	expiredTime := now.Add(item.validity).Format("2006-01-02 15:04:05")
+	r, apierr := GetActivePanClient().WebapiPanClient().ShareLinkCreate(aliyunpan_web.ShareCreateParam{
+		DriveId:    item.driveId,
+		SharePwd:   item.sharePwd,
+		Expiration: expiredTime,
+		FileIdList: item.fileIdList,
+	})
+	if apierr != nil || r == nil {
+		fmt.Printf("%s 重建分享失败: %s, %s\n", utils.NowTimeStr(), item.shareId, apierr)
+		return false
+	}
+
+	if len(r.SharePwd) > 0 {
+		fmt.Printf("%s 分享已过期，重建成功: %s => %s 链接：%s 提取码：%s 过期时间：%s\n",
+			utils.NowTimeStr(), item.shareId, r.ShareId, r.ShareUrl, r.SharePwd, expiredTime)
+	} else {
+		fmt.Printf("%s 分享已过期，重建成功: %s => %s 链接：%s 过期时间：%s\n",
+			utils.NowTimeStr(), item.shareId, r.ShareId, r.ShareUrl, expiredTime)
+	}
+	err := appendShareAuditLog(logPath, &ShareRefreshEvent{
+		Time:        now.Format("2006-01-02 15:04:05"),
+		OldShareId:  item.shareId,
+		NewShareId:  r.ShareId,
+		ShareUrl:    r.ShareUrl,
+		SharePwd:    r.SharePwd,
+		ExpiredTime: expiredTime,
+	})
+	if err != nil {
+		fmt.Printf("写入重建记录失败: %s\n", err)
+	}
+	if err = ReplaceShareExpiryWatch(item.shareId, r.ShareId, r.ShareUrl, expiredTime); err != nil {
+		fmt.Printf("更新分享过期监控失败: %s\n", err)
+	}
+
+	item.shareId = r.ShareId
+	item.sharePwd = r.SharePwd
+	item.expiredAt = now.Add(item.validity)
+	return true
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/library-go/logger"
+	"github.com/tickstep/library-go/requester"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultShareExpiryPollInterval 默认的分享过期检查间隔
+	DefaultShareExpiryPollInterval = 60 * time.Second
+)
+
+type (
+	// ShareExpiryWatch 需要在过期时通知的分享
+	ShareExpiryWatch struct {
+		ShareId     string   `json:"shareId"`
+		ShareUrl    string   `json:"shareUrl"`
+		ExpiredTime string   `json:"expiredTime"`
+		Webhook     string   `json:"webhook"`
+		User        string   `json:"user"`
+		Files       []string `json:"files"`
+	}
+
+	// ShareExpiryNotification 分享过期时发送到 webhook 的通知内容
+	ShareExpiryNotification struct {
+		Event     string   `json:"event"`
+		ShareId   string   `json:"shareId"`
+		ShareUrl  string   `json:"shareUrl"`
+		ExpiredAt string   `json:"expiredAt"`
+		User      string   `json:"user"`
+		Files     []string `json:"files"`
+		Time      string   `json:"time"`
+	}
+)
+
+var (
+	shareExpiryMutex        = &sync.Mutex{}
+	shareExpiryWatcherOnce  = &sync.Once{}
+	shareExpiryPollInterval = DefaultShareExpiryPollInterval
+)
+
+// shareExpiryWatchFilePath 分享过期监控列表的保存路径
+func shareExpiryWatchFilePath() string {
+	return filepath.Join(config.GetConfigDir(), "share_expiry_watch.json")
+}
+
+// loadShareExpiryWatchList 读取分享过期监控列表，文件不存在返回空列表
+func loadShareExpiryWatchList() ([]*ShareExpiryWatch, error) {
+	data, err := ioutil.ReadFile(shareExpiryWatchFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*ShareExpiryWatch{}, nil
+		}
+		return nil, err
+	}
+	watchList := []*ShareExpiryWatch{}
+	if err = json.Unmarshal(data, &watchList); err != nil {
+		return nil, err
+	}
+	return watchList, nil
+}
+
+// saveShareExpiryWatchList 保存分享过期监控列表，先写入临时文件再替换，防止写入中断损坏文件
+func saveShareExpiryWatchList(watchList []*ShareExpiryWatch) error {
+	data, err := json.MarshalIndent(watchList, "", "  ")
+	if err != nil {
+		return err
+	}
+	savePath := shareExpiryWatchFilePath()
+	tmpPath := savePath + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, savePath)
+}
+
+// AddShareExpiryWatch 添加需要在过期时通知的分享，并持久化到本地文件
+func AddShareExpiryWatch(watch *ShareExpiryWatch) error {
+	shareExpiryMutex.Lock()
+	defer shareExpiryMutex.Unlock()
+	watchList, err := loadShareExpiryWatchList()
+	if err != nil {
+		return err
+	}
+	return saveShareExpiryWatchList(append(watchList, watch))
+}
+
+// ReplaceShareExpiryWatch 分享重建后，将监控列表中的旧分享替换为新分享，没有监控旧分享则不做修改
+func ReplaceShareExpiryWatch(oldShareId, newShareId, shareUrl, expiredTime string) error {
+	shareExpiryMutex.Lock()
+	defer shareExpiryMutex.Unlock()
+	watchList, err := loadShareExpiryWatchList()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, w := range watchList {
+		if w.ShareId == oldShareId {
+			w.ShareId = newShareId
+			w.ShareUrl = shareUrl
+			w.ExpiredTime = expiredTime
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return saveShareExpiryWatchList(watchList)
+}
+
+// StartShareExpiryWatcher 启动后台分享过期监控，每个进程只会启动一个监控协程。
+// pollInterval 大于0时更新检查间隔
+func StartShareExpiryWatcher(pollInterval time.Duration) {
+	shareExpiryMutex.Lock()
+	if pollInterval > 0 {
+		shareExpiryPollInterval = pollInterval
+	}
+	shareExpiryMutex.Unlock()
+
+	shareExpiryWatcherOnce.Do(func() {
+		go func() {
+			for {
+				checkShareExpiry(time.Now())
+				shareExpiryMutex.Lock()
+				interval := shareExpiryPollInterval
+				shareExpiryMutex.Unlock()
+				time.Sleep(interval)
+			}
+		}()
+	})
+}
+
+// checkShareExpiry 检查所有监控的分享，已过期的分享发送 webhook 通知并从列表移除。
+// 发送失败的分享保留在列表中，下次检查时重试。发送通知时不持有锁，避免 webhook 响应慢时阻塞添加监控
+func checkShareExpiry(now time.Time) {
+	shareExpiryMutex.Lock()
+	watchList, err := loadShareExpiryWatchList()
+	shareExpiryMutex.Unlock()
+	if err != nil {
+		logger.Verbosef("load share expiry watch list error: %s\n", err)
+		return
+	}
+
+	// 需要从列表移除的分享：过期时间无法解析或者已经发送通知
+	removed := map[string]bool{}
+	for _, w := range watchList {
+		expiredAt, er := time.ParseInLocation("2006-01-02 15:04:05", w.ExpiredTime, time.Local)
+		if er != nil {
+			// 无法解析的过期时间，直接丢弃
+			logger.Verbosef("invalid share expired time %s: %s\n", w.ShareId, w.ExpiredTime)
+			removed[w.ShareId] = true
+			continue
+		}
+		if now.Before(expiredAt) {
+			continue
+		}
+		if er = postShareExpiryWebhook(w, now); er != nil {
+			logger.Verbosef("post share expiry webhook error %s: %s\n", w.ShareId, er)
+			continue
+		}
+		logger.Verbosef("share expired notification sent: %s\n", w.ShareId)
+		removed[w.ShareId] = true
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	// 发送期间列表可能被修改，重新读取后再移除
+	shareExpiryMutex.Lock()
+	defer shareExpiryMutex.Unlock()
+	watchList, err = loadShareExpiryWatchList()
+	if err != nil {
+		logger.Verbosef("load share expiry watch list error: %s\n", err)
+		return
+	}
+	remain := []*ShareExpiryWatch{}
+	for _, w := range watchList {
+		if !removed[w.ShareId] {
+			remain = append(remain, w)
+		}
+	}
+	if err = saveShareExpiryWatchList(remain); err != nil {
+		logger.Verbosef("save share expiry watch list error: %s\n", err)
+	}
+}
+
+// postShareExpiryWebhook 将分享过期通知以JSON格式 POST 到 webhook 地址
+func postShareExpiryWebhook(w *ShareExpiryWatch, now time.Time) error {
+	data, err := json.Marshal(&ShareExpiryNotification{
+		Event:     "share.expired",
+		ShareId:   w.ShareId,
+		ShareUrl:  w.ShareUrl,
+		ExpiredAt: w.ExpiredTime,
+		User:      w.User,
+		Files:     w.Files,
+		Time:      now.Format("2006-01-02 15:04:05"),
+	})
+	if err != nil {
+		return err
+	}
+	client := requester.NewHTTPClient()
+	client.SetTimeout(30 * time.Second)
+	resp, err := client.Req(http.MethodPost, w.Webhook, bytes.NewReader(data), map[string]string{
+		"Content-Type": "application/json",
+	})
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook response status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+var (
+	// shareExportCsvHeader 分享导出csv文件的表头
+	shareExportCsvHeader = []string{"序号", "分享ID", "分享链接", "提取码", "文件名", "过期时间", "状态", "创建时间"}
+)
+
+// RunShareExportMerge 合并两个导出的分享文件，按分享ID去重，按创建时间排序后保存
+func RunShareExportMerge(file1, file2, outputFile, format string) {
+	items1, err := loadShareExportFile(file1)
+	if err != nil {
+		fmt.Printf("读取导出文件 %s 失败: %s\n", file1, err)
+		return
+	}
+	items2, err := loadShareExportFile(file2)
+	if err != nil {
+		fmt.Printf("读取导出文件 %s 失败: %s\n", file2, err)
+		return
+	}
+
+	// 同一个分享以后面文件的记录为准
+	merged := make([]shareListJSONItem, 0, len(items1)+len(items2))
+	indexes := map[string]int{}
+	for _, item := range append(items1, items2...) {
+		if item.ShareId == "" {
+			continue
+		}
+		if i, ok := indexes[item.ShareId]; ok {
+			merged[i] = item
+			continue
+		}
+		indexes[item.ShareId] = len(merged)
+		merged = append(merged, item)
+	}
+	sortShareExportItems(merged)
+
+	fmt.Printf("导出文件1共 %d 个分享，导出文件2共 %d 个分享，合并后共 %d 个分享\n", len(items1), len(items2), len(merged))
+	if format == ShareExportFormatJson {
+		if ExportJson(outputFile, merged) {
+			fmt.Println("分享合并成功：", outputFile)
+		}
+		return
+	}
+	columns := [][]string{shareExportCsvHeader}
+	for k, item := range merged {
+		columns = append(columns, []string{strconv.Itoa(k + 1), item.ShareId, item.ShareUrl, item.SharePwd, item.ShareName, item.Expiration, item.Status, item.CreatedAt})
+	}
+	if ExportCsv(outputFile, columns) {
+		fmt.Println("分享合并成功：", outputFile)
+	}
+}
+
+// sortShareExportItems 按创建时间从早到晚排序，没有创建时间的记录排在最后
+func sortShareExportItems(items []shareListJSONItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].CreatedAt == "" || items[j].CreatedAt == "" {
+			return items[j].CreatedAt == "" && items[i].CreatedAt != ""
+		}
+		// 创建时间格式为 2006-01-02 15:04:05，可以直接按字符串比较
+		return items[i].CreatedAt < items[j].CreatedAt
+	})
+}
+
+// loadShareExportFile 读取 share export 导出的csv或json文件
+func loadShareExportFile(filePath string) ([]shareListJSONItem, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		items := []shareListJSONItem{}
+		if err = json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []shareListJSONItem{}, nil
+	}
+	// 按表头查找各列, 兼容没有创建时间列的旧版本导出文件
+	columnIndex := map[string]int{}
+	for i, name := range rows[0] {
+		columnIndex[name] = i
+	}
+	column := func(row []string, name string) string {
+		if i, ok := columnIndex[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	items := make([]shareListJSONItem, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		items = append(items, shareListJSONItem{
+			ShareId:    column(row, "分享ID"),
+			ShareUrl:   column(row, "分享链接"),
+			SharePwd:   column(row, "提取码"),
+			ShareName:  column(row, "文件名"),
+			Expiration: column(row, "过期时间"),
+			Status:     column(row, "状态"),
+			CreatedAt:  column(row, "创建时间"),
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// ShareCreatedNotification 分享创建成功的通知内容
+	ShareCreatedNotification struct {
+		ShareUrl    string
+		SharePwd    string
+		ExpiredTime string // 空代表永久有效
+		Files       []string
+		Title       string
+	}
+
+	// SlackMessage Slack incoming webhook 消息
+	SlackMessage struct {
+		Text        string            `json:"text"`
+		Attachments []SlackAttachment `json:"attachments"`
+	}
+
+	// SlackAttachment Slack 消息附件
+	SlackAttachment struct {
+		Color     string       `json:"color"`
+		Title     string       `json:"title"`
+		TitleLink string       `json:"title_link"`
+		Fields    []SlackField `json:"fields"`
+		Ts        int64        `json:"ts"`
+	}
+
+	// SlackField Slack 消息附件的字段
+	SlackField struct {
+		Title string `json:"title"`
+		Value string `json:"value"`
+		Short bool   `json:"short"`
+	}
+
+	// DingTalkMessage 钉钉自定义机器人 markdown 消息
+	DingTalkMessage struct {
+		MsgType  string           `json:"msgtype"`
+		Markdown DingTalkMarkdown `json:"markdown"`
+	}
+
+	// DingTalkMarkdown 钉钉 markdown 消息内容
+	DingTalkMarkdown struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	}
+
+	// dingTalkResponse 钉钉机器人接口的返回值, 出错时HTTP状态码仍然为200
+	dingTalkResponse struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+)
+
+// NewSlackShareMessage 构造分享创建成功的 Slack 消息
+func NewSlackShareMessage(n *ShareCreatedNotification, now time.Time) *SlackMessage {
+	title := n.Title
+	if title == "" {
+		title = n.ShareUrl
+	}
+	pwd := n.SharePwd
+	if pwd == "" {
+		pwd = "无"
+	}
+	et := n.ExpiredTime
+	if et == "" {
+		et = "永久有效"
+	}
+	return &SlackMessage{
+		Text: "aliyunpan 创建分享成功",
+		Attachments: []SlackAttachment{
+			{
+				Color:     "good",
+				Title:     title,
+				TitleLink: n.ShareUrl,
+				Fields: []SlackField{
+					{Title: "文件", Value: strings.Join(n.Files, "\n"), Short: false},
+					{Title: "链接", Value: n.ShareUrl, Short: false},
+					{Title: "提取码", Value: pwd, Short: true},
+					{Title: "过期时间", Value: et, Short: true},
+				},
+				Ts: now.Unix(),
+			},
+		},
+	}
+}
+
+// postSlackShareNotification 发送分享创建成功的通知到 Slack incoming webhook
+func postSlackShareNotification(webhook string, n *ShareCreatedNotification) error {
+	data, err := json.Marshal(NewSlackShareMessage(n, time.Now()))
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Slack 出错时在响应体中返回错误码, 例如 invalid_payload
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("slack response status: %s, %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// NewDingTalkShareMessage 构造分享创建成功的钉钉 markdown 消息
+func NewDingTalkShareMessage(n *ShareCreatedNotification) *DingTalkMessage {
+	title := n.Title
+	if title == "" {
+		title = "aliyunpan 创建分享成功"
+	}
+	pwd := n.SharePwd
+	if pwd == "" {
+		pwd = "无"
+	}
+	et := n.ExpiredTime
+	if et == "" {
+		et = "永久有效"
+	}
+	text := &strings.Builder{}
+	fmt.Fprintf(text, "### %s\n\n", title)
+	fmt.Fprintf(text, "**文件**\n\n")
+	for _, f := range n.Files {
+		fmt.Fprintf(text, "- %s\n", f)
+	}
+	fmt.Fprintf(text, "\n**链接**: [%s](%s)\n\n", n.ShareUrl, n.ShareUrl)
+	fmt.Fprintf(text, "**提取码**: %s\n\n", pwd)
+	fmt.Fprintf(text, "**过期时间**: %s\n", et)
+	return &DingTalkMessage{
+		MsgType: "markdown",
+		Markdown: DingTalkMarkdown{
+			Title: title,
+			Text:  text.String(),
+		},
+	}
+}
+
+// DingTalkSignedWebhook 钉钉机器人开启加签后, 在 webhook 地址上附加毫秒时间戳和 HMAC-SHA256 签名
+func DingTalkSignedWebhook(webhook, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// postDingTalkShareNotification 发送分享创建成功的通知到钉钉自定义机器人, secret 为空代表机器人没有开启加签
+func postDingTalkShareNotification(webhook, secret string, n *ShareCreatedNotification) error {
+	if secret != "" {
+		signed, err := DingTalkSignedWebhook(webhook, secret, time.Now())
+		if err != nil {
+			return err
+		}
+		webhook = signed
+	}
+	data, err := json.Marshal(NewDingTalkShareMessage(n))
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("dingtalk response status: %s, %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	r := &dingTalkResponse{}
+	if err = json.Unmarshal(body, r); err != nil {
+		return fmt.Errorf("dingtalk response error: %s", strings.TrimSpace(string(body)))
+	}
+	if r.ErrCode != 0 {
+		return fmt.Errorf("dingtalk response error: %d, %s", r.ErrCode, r.ErrMsg)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package command
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan_web"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeriveSharePassword(t *testing.T) {
+	files := []*aliyunpan.FileEntity{
+		{FileId: "1", ContentHash: "3C2D09AB9E2D9D33E2A2C6D6D8C8D3BC0F1C2A31"},
+		{FileId: "2", ContentHash: "A9993E364706816ABA3E25717850C26C9CD0D89D"},
+	}
+	pwd := DeriveSharePassword("secret", files)
+	fmt.Println(pwd)
+	if len(pwd) != SharePasswordLength {
+		t.Fatalf("unexpected password length: %s", pwd)
+	}
+
+	// 文件顺序不影响结果
+	reversed := []*aliyunpan.FileEntity{files[1], files[0]}
+	if DeriveSharePassword("secret", reversed) != pwd {
+		t.Fatalf("password should not depend on file order")
+	}
+	if DeriveSharePassword("other", files) == pwd {
+		fmt.Println("warning: different secret produce same password")
+	}
+}
+
+func TestWatermarkShareUrl(t *testing.T) {
+	w := ShareWatermark("alice@example.com", "secret")
+	fmt.Println(w)
+	if len(w) != 16 {
+		t.Fatalf("unexpected watermark length: %s", w)
+	}
+	fmt.Println(WatermarkShareUrl("https://www.aliyundrive.com/s/abc", "alice@example.com", "secret"))
+	fmt.Println(WatermarkShareUrl("https://www.aliyundrive.com/s/abc?pwd=1234", "alice@example.com", "secret"))
+}
+
+func TestNewSlackShareMessage(t *testing.T) {
+	msg := NewSlackShareMessage(&ShareCreatedNotification{
+		ShareUrl: "https://www.aliyundrive.com/s/abc",
+		SharePwd: "1234",
+		Files:    []string{"/1.mp4", "/2.mp4"},
+	}, time.Unix(1672545600, 0))
+	data, _ := json.Marshal(msg)
+	fmt.Println(string(data))
+	if len(msg.Attachments) != 1 || msg.Attachments[0].TitleLink != "https://www.aliyundrive.com/s/abc" {
+		t.Fatalf("unexpected slack message: %s", data)
+	}
+}
+
+func TestNewDingTalkShareMessage(t *testing.T) {
+	msg := NewDingTalkShareMessage(&ShareCreatedNotification{
+		ShareUrl: "https://www.aliyundrive.com/s/abc",
+		SharePwd: "1234",
+		Files:    []string{"/1.mp4", "/2.mp4"},
+	})
+	data, _ := json.Marshal(msg)
+	fmt.Println(string(data))
+	if msg.MsgType != "markdown" || !strings.Contains(msg.Markdown.Text, "/2.mp4") || !strings.Contains(msg.Markdown.Text, "1234") {
+		t.Fatalf("unexpected dingtalk message: %s", data)
+	}
+}
+
+func TestDingTalkSignedWebhook(t *testing.T) {
+	u, err := DingTalkSignedWebhook("https://oapi.dingtalk.com/robot/send?access_token=abc", "SECxxx", time.Unix(1672545600, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(u)
+	if !strings.Contains(u, "access_token=abc") || !strings.Contains(u, "timestamp=1672545600000") || !strings.Contains(u, "sign=") {
+		t.Fatalf("unexpected signed webhook: %s", u)
+	}
+}
+
+func TestIsShareIdArg(t *testing.T) {
+	for arg, expected := range map[string]bool{
+		"5kXgbsbpr3N": true,
+		"*.mp4":       false,
+		"我的文档":        false,
+		"report-2023": false,
+		"":            false,
+	} {
+		if isShareIdArg(arg) != expected {
+			t.Fatalf("isShareIdArg(%q) should be %v", arg, expected)
+		}
+	}
+}
+
+func TestGroupShareFilesByDir(t *testing.T) {
+	dirs, groups := groupShareFilesByDir([]*aliyunpan.FileEntity{
+		{FileId: "1", Path: "/视频/a/1.mp4"},
+		{FileId: "2", Path: "/文档/1.pdf"},
+		{FileId: "3", Path: "/视频/a/2.mp4"},
+	})
+	fmt.Println(dirs)
+	if len(dirs) != 2 || dirs[0] != "/视频/a" || dirs[1] != "/文档" {
+		t.Fatalf("unexpected dirs: %v", dirs)
+	}
+	if len(groups["/视频/a"]) != 2 || len(groups["/文档"]) != 1 {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+}
+
+func TestParseShareLimitIp(t *testing.T) {
+	nets, err := parseShareLimitIp("10.0.0.0/8, 192.168.1.0/24")
+	fmt.Println(nets, err)
+	if err != nil || len(nets) != 2 {
+		t.Fatalf("unexpected result: %v, %v", nets, err)
+	}
+	if _, err = parseShareLimitIp("10.0.0.1"); err == nil {
+		t.Fatalf("ip without mask should be invalid")
+	}
+}
+
+func TestCheckShareFolderCover(t *testing.T) {
+	ok := []*aliyunpan.FileEntity{
+		{FileName: "cover.jpg", FileSize: 1024, FileType: "file"},
+		{FileName: "cover.PNG", FileSize: ShareFolderCoverMaxSize, FileType: "file"},
+	}
+	for _, f := range ok {
+		if err := checkShareFolderCover(f); err != nil {
+			t.Fatalf("%s: %s", f.FileName, err)
+		}
+	}
+	bad := []*aliyunpan.FileEntity{
+		{FileName: "cover.gif", FileSize: 1024, FileType: "file"},
+		{FileName: "cover.jpg", FileSize: ShareFolderCoverMaxSize + 1, FileType: "file"},
+		{FileName: "images", FileType: "folder"},
+	}
+	for _, f := range bad {
+		if err := checkShareFolderCover(f); err == nil {
+			t.Fatalf("%s should be rejected", f.FileName)
+		}
+	}
+}
+
+func TestExportCsvSplit(t *testing.T) {
+	dir := t.TempDir()
+	data := [][]string{{"序号", "分享ID"}}
+	for i := 1; i <= 5; i++ {
+		data = append(data, []string{strconv.Itoa(i), "share" + strconv.Itoa(i)})
+	}
+	files := ExportCsvSplit(filepath.Join(dir, "share_list.csv"), data, 2)
+	if len(files) != 3 {
+		t.Fatalf("want 3 files, got %v", files)
+	}
+	if filepath.Base(files[0]) != "share_list_001.csv" || filepath.Base(files[2]) != "share_list_003.csv" {
+		t.Fatalf("unexpected file names: %v", files)
+	}
+	for k, want := range []int{2, 2, 1} {
+		fp, err := os.Open(files[k])
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(fp).ReadAll()
+		fp.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != want+1 || rows[0][1] != "分享ID" {
+			t.Fatalf("%s: unexpected rows %v", files[k], rows)
+		}
+	}
+
+	// 没有记录时仍然导出只有表头的文件
+	files = ExportCsvSplit(filepath.Join(dir, "empty"), data[:1], 2)
+	if len(files) != 1 || filepath.Base(files[0]) != "empty_001.csv" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
+
+func TestFilterActiveShares(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	file := &aliyunpan.FileEntity{FileName: "1.mp4"}
+	records := []*aliyunpan_web.ShareEntity{
+		{ShareId: "active", Status: "enabled", FirstFile: file},
+		{ShareId: "future", Status: "enabled", Expiration: "2024-07-01 00:00:00", FirstFile: file},
+		{ShareId: "expired", Status: "enabled", Expiration: "2024-05-01 00:00:00", FirstFile: file},
+		{ShareId: "deleted", Status: "enabled"},
+		{ShareId: "forbidden", Status: "forbidden", FirstFile: file},
+	}
+	result := filterActiveShares(records, now)
+	if len(result) != 2 || result[0].ShareId != "active" || result[1].ShareId != "future" {
+		t.Fatalf("unexpected active shares: %v", result)
+	}
+}
+
+func TestFilterShareIncludeFiles(t *testing.T) {
+	fileList := aliyunpan.FileList{
+		{FileName: "movies", Path: "/movies", FileType: "folder"},
+		{FileName: "a.mp4", Path: "/movies/a.mp4", FileType: "file"},
+		{FileName: "b.MKV", Path: "/movies/sub/b.MKV", FileType: "file"},
+		{FileName: "c.mkv", Path: "/movies/sub/c.mkv", FileType: "file"},
+		{FileName: "a.srt", Path: "/movies/a.srt", FileType: "file"},
+	}
+	result := filterShareIncludeFiles(fileList, []string{"*.mp4", "*.mkv"})
+	if len(result) != 2 || result[0].FileName != "a.mp4" || result[1].FileName != "c.mkv" {
+		t.Fatalf("unexpected files: %v", result)
+	}
+}
@@ -45,6 +45,8 @@ func CmdSync() cli.Command {
        备份本地文件，即上传本地文件到网盘，始终保持本地文件有一个完整的备份在网盘
 	2. download 
        备份云盘文件，即下载网盘文件到本地，始终保持网盘的文件有一个完整的备份在本地
+	3. sync
+       双向同步，本地新增的文件上传到网盘，网盘新增的文件下载到本地，两边都有但是不一致的文件按照优先级处理。双向同步不会删除任何文件
 
 	请输入以下命令查看如何配置和启动：
     aliyunpan sync start -h
@@ -81,8 +83,9 @@ func CmdSync() cli.Command {
 name - 任务名称
 localFolderPath - 本地目录
 panFolderPath - 网盘目录
-mode - 备份模式，支持两种: upload(备份本地文件到云盘),download(备份云盘文件到本地)
+mode - 备份模式，支持三种: upload(备份本地文件到云盘),download(备份云盘文件到本地),sync(双向同步)
 policy - 备份策略, 支持两种: exclusive(排他备份文件，目标目录多余的文件会被删除),increment(增量备份文件，目标目录多余的文件不会被删除)
+priority - 同步优先级，只对sync模式有效，支持三种: time(修改时间较新的文件优先),local(本地文件优先),pan(网盘文件优先)
 driveName - 网盘名称，backup(备份盘)，resource(资源盘)
     
 	例子:
@@ -99,10 +102,13 @@ driveName - 网盘名称，backup(备份盘)，resource(资源盘)
        同时配置下载并发为2，上传并发为1，下载分片大小为256KB，上传分片大小为1MB
 	aliyunpan sync start -ldir "D:\tickstep\Documents\设计文档" -pdir "/sync_drive/我的文档" -mode "upload" -dp 2 -up 1 -dbs 256 -ubs 1024
     
-	5. 使用配置文件启动同步备份服务，使用配置文件可以支持同时启动多个备份任务。配置文件必须存在，否则启动失败。
+	5. 使用命令行配置启动双向同步服务，本地目录和云盘目录都有但是内容不一致的文件，使用本地的文件覆盖云盘的文件
+	aliyunpan sync start -ldir "D:\tickstep\Documents\设计文档" -pdir "/sync_drive/我的文档" -mode "sync" -pri "local"
+
+	6. 使用配置文件启动同步备份服务，使用配置文件可以支持同时启动多个备份任务。配置文件必须存在，否则启动失败。
 	aliyunpan sync start
 
-	6. 使用配置文件启动同步备份服务，并配置下载并发为2，上传并发为1，下载分片大小为256KB，上传分片大小为1MB
+	7. 使用配置文件启动同步备份服务，并配置下载并发为2，上传并发为1，下载分片大小为256KB，上传分片大小为1MB
 	aliyunpan sync start -dp 2 -up 1 -dbs 256 -ubs 1024
 
 `,
@@ -149,14 +155,14 @@ driveName - 网盘名称，backup(备份盘)，resource(资源盘)
 					}
 
 					var syncOpt syncdrive.SyncPriorityOption = syncdrive.SyncPriorityTimestampFirst
-					//opt := c.String("pri")
-					//if opt == "local" {
-					//	syncOpt = syncdrive.SyncPriorityLocalFirst
-					//} else if opt == "pan" {
-					//	syncOpt = syncdrive.SyncPriorityPanFirst
-					//} else {
-					//	syncOpt = syncdrive.SyncPriorityTimestampFirst
-					//}
+					opt := c.String("pri")
+					if opt == "local" {
+						syncOpt = syncdrive.SyncPriorityLocalFirst
+					} else if opt == "pan" {
+						syncOpt = syncdrive.SyncPriorityPanFirst
+					} else {
+						syncOpt = syncdrive.SyncPriorityTimestampFirst
+					}
 
 					var task *syncdrive.SyncTask
 					localDir := c.String("ldir")
@@ -252,7 +258,7 @@ driveName - 网盘名称，backup(备份盘)，resource(资源盘)
 					},
 					cli.StringFlag{
 						Name:  "mode",
-						Usage: "备份模式, 支持两种: upload(备份本地文件到云盘),download(备份云盘文件到本地)",
+						Usage: "备份模式, 支持三种: upload(备份本地文件到云盘),download(备份云盘文件到本地),sync(双向同步)",
 						Value: "upload",
 					},
 					cli.StringFlag{
@@ -260,11 +266,11 @@ driveName - 网盘名称，backup(备份盘)，resource(资源盘)
 						Usage: "备份策略, 支持两种: exclusive(排他备份文件，目标目录多余的文件会被删除),increment(增量备份文件，目标目录多余的文件不会被删除)",
 						Value: "increment",
 					},
-					//cli.StringFlag{
-					//	Name:  "pri",
-					//	Usage: "同步优先级，只对sync模式有效。当网盘和本地存在同名文件，优先使用哪个，选项支持三种: time-时间优先，local-本地优先，pan-网盘优先",
-					//	Value: "time",
-					//},
+					cli.StringFlag{
+						Name:  "pri",
+						Usage: "同步优先级，只对sync模式有效。当网盘和本地存在同名文件，优先使用哪个，选项支持三种: time-时间优先，local-本地优先，pan-网盘优先",
+						Value: "time",
+					},
 					cli.StringFlag{
 						Name:  "cycle",
 						Usage: "备份周期, 支持两种: infinity(永久循环备份),onetime(只运行一次备份)",
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan/cmder"
+	"github.com/tickstep/aliyunpan/cmder/cmdtable"
+	"github.com/tickstep/aliyunpan/internal/functions/pantag"
+	"github.com/tickstep/aliyunpan/internal/localfile"
+	"github.com/urfave/cli"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func CmdTagSync() cli.Command {
+	return cli.Command{
+		Name:      "tag-sync",
+		Usage:     "同步本地文件的扩展属性(标签)到本地标签数据库",
+		UsageText: cmder.App().Name + " tag-sync <本地文件/目录1> <文件/目录2> ...",
+		Description: `
+	读取本地文件的扩展属性(xattr)，例如 macOS Finder 的标签 com.apple.metadata:kMDItemUserTags，
+	以文件的SHA1(即网盘文件的 ContentHash)为key保存到本地标签数据库。
+	之后使用 download 命令下载内容相同的网盘文件时，会自动将保存的扩展属性写回到下载的本地文件。
+	--namespace 指定需要同步的扩展属性名称或者名称前缀，支持多个。只支持macOS和Linux系统
+
+	示例:
+
+	同步 ~/Documents 目录下所有文件的 Finder 标签
+	aliyunpan tag-sync ~/Documents
+
+	同步所有 com.apple.metadata: 开头的扩展属性，只输出结果不保存
+	aliyunpan tag-sync --namespace "com.apple.metadata:" --dry-run ~/Documents
+`,
+		Category: "阿里云盘",
+		Before:   ReloadConfigFunc,
+		Action: func(c *cli.Context) error {
+			if c.NArg() == 0 {
+				cli.ShowCommandHelp(c, c.Command.Name)
+				return nil
+			}
+			namespaces := c.StringSlice("namespace")
+			if len(namespaces) == 0 {
+				namespaces = pantag.DefaultXattrNamespaces
+			}
+			RunTagSync(c.Args(), namespaces, c.Bool("dry-run"))
+			return nil
+		},
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "namespace",
+				Usage: "需要同步的扩展属性名称或者名称前缀，可以指定多个，默认为 com.apple.metadata:kMDItemUserTags",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "只输出将要同步的扩展属性，不写入标签数据库",
+			},
+		},
+	}
+}
+
+// RunTagSync 读取本地文件的扩展属性，保存到本地标签数据库
+func RunTagSync(localPaths []string, namespaces []string, dryRun bool) {
+	tagDatabase, err := pantag.NewTagDatabase()
+	if err != nil {
+		fmt.Printf("打开本地标签数据库错误: %s\n", err)
+		return
+	}
+	defer tagDatabase.Close()
+
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "文件", "SHA1", "扩展属性"})
+	count := 0
+	for _, localPath := range localPaths {
+		err = filepath.Walk(filepath.Clean(localPath), func(filePath string, fi os.FileInfo, err error) error {
+			if err != nil {
+				fmt.Printf("读取文件出错: %s, %s\n", filePath, err)
+				return nil
+			}
+			if fi.IsDir() {
+				return nil
+			}
+			attrs, err := pantag.ReadXattrs(filePath, namespaces)
+			if err != nil {
+				if err == pantag.ErrXattrNotSupported {
+					return err
+				}
+				fmt.Printf("读取文件扩展属性出错: %s, %s\n", filePath, err)
+				return nil
+			}
+			if len(attrs) == 0 {
+				return nil
+			}
+			sum, err := localfile.GetFileSum(filePath, localfile.CHECKSUM_SHA1)
+			if err != nil {
+				fmt.Printf("计算文件SHA1出错: %s, %s\n", filePath, err)
+				return nil
+			}
+			names := make([]string, 0, len(attrs))
+			for name := range attrs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			count++
+			tb.Append([]string{strconv.Itoa(count), filePath, strings.ToUpper(sum.SHA1), strings.Join(names, ", ")})
+			if !dryRun {
+				tagDatabase.Set(sum.SHA1, attrs)
+			}
+			return nil
+		})
+		if err == pantag.ErrXattrNotSupported {
+			fmt.Println(err)
+			return
+		}
+	}
+	tb.Render()
+
+	if dryRun {
+		fmt.Printf("共 %d 个文件包含扩展属性，dry-run 模式不写入标签数据库\n", count)
+		return
+	}
+	if err = tagDatabase.Save(); err != nil {
+		fmt.Printf("保存本地标签数据库错误: %s\n", err)
+		return
+	}
+	fmt.Printf("共同步 %d 个文件的扩展属性到: %s\n", count, pantag.TagDatabasePath())
+}
@@ -24,6 +24,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -63,8 +64,14 @@ type (
 		IsOverwrite    bool // 覆盖已存在的文件，如果同名文件已存在则移到回收站里
 		IsSkipSameName bool // 跳过已存在的文件，即使文件内容不一致(不检查SHA1)
 		DriveId        string
-		ExcludeNames   []string // 排除的文件名，包括文件夹和文件。即这些文件/文件夹不进行上传，支持正则表达式
-		BlockSize      int64    // 分片大小
+		ExcludeNames   []string        // 排除的文件名，包括文件夹和文件。即这些文件/文件夹不进行上传，支持正则表达式
+		BlockSize      int64           // 分片大小
+		Encrypt        string          // 加密密码，不为空则使用 AES-256-GCM 加密文件内容后再上传
+		ReadAhead      int             // 预读分片数量，上传当前分片时预先读取后续分片到内存
+		VerifySpace    bool            // 上传前检查网盘剩余空间是否足够
+		SkipHidden     bool            // 跳过隐藏文件和隐藏目录
+		PartSizeAuto   bool            // 上传前测量上传带宽，根据带宽和文件大小自动计算分片大小
+		User           *config.PanUser // 上传到的账号，为空则使用当前登录的账号
 	}
 )
 
@@ -111,9 +118,30 @@ var UploadFlags = []cli.Flag{
 	},
 	cli.IntFlag{
 		Name:  "bs",
-		Usage: "block size，上传分片大小，单位KB。推荐值：1024 ~ 10240。当上传极大单文件时候请适当调高该值",
+		Usage: "block size，上传分片大小，单位KB。推荐值：1024 ~ 10240。当上传极大单文件时候请适当调高该值。未指定时使用 config set -chunk_size_mb 配置的值",
 		Value: 10240,
 	},
+	cli.StringFlag{
+		Name:  "encrypt",
+		Usage: "加密密码，上传前使用 AES-256-GCM 加密文件内容，加密参数保存在上传文件的头部。下载时使用 download -decrypt 解密",
+	},
+	cli.IntFlag{
+		Name:  "read-ahead",
+		Usage: "上传当前分片时预先读取后续n个分片到内存，可以减少机械硬盘的读取等待。0代表不预读，会额外占用 n*分片大小 的内存",
+		Value: 0,
+	},
+	cli.BoolFlag{
+		Name:  "skip-hidden",
+		Usage: "跳过隐藏文件和隐藏目录，例如 .DS_Store、.git、.env。文件名以 . 开头或者设置了Windows隐藏属性的文件视为隐藏文件",
+	},
+	cli.BoolFlag{
+		Name:  "verify-space",
+		Usage: "上传前检查网盘剩余空间，所有文件的总大小超过剩余空间时列出放不下的文件并取消上传。不考虑秒传和跳过的文件",
+	},
+	cli.BoolFlag{
+		Name:  "part-size-auto",
+		Usage: "上传前先上传1MB测试数据测量上传带宽，按每个分片约30秒上传完成自动计算分片大小，忽略 -bs 参数。测速失败时使用 -bs 指定的分片大小",
+	},
 }
 
 func CmdUpload() cli.Command {
@@ -157,6 +185,15 @@ func CmdUpload() cli.Command {
     10. 跳过已存在的同名文件，即使文件内容不一致(不检查SHA1)
     aliyunpan upload -skip 1.mp4 /视频
 
+    11. 使用密码 mypass 加密文件内容后再上传
+    aliyunpan upload -encrypt mypass 1.mp4 /视频
+
+    12. 上传前检查网盘剩余空间是否足够，不够则取消上传
+    aliyunpan upload -verify-space C:/Users/Administrator/Video /视频
+
+    13. 根据测得的上传带宽自动选择分片大小
+    aliyunpan upload -part-size-auto C:/Users/Administrator/Desktop/1.mp4 /视频
+
   参考：
     以下是典型的排除特定文件或者文件夹的例子，注意：参数值必须是正则表达式。在正则表达式中，^表示匹配开头，$表示匹配结尾。
     1)排除@eadir文件或者文件夹：-exn "^@eadir$"
@@ -202,7 +239,12 @@ func CmdUpload() cli.Command {
 				IsSkipSameName: c.Bool("skip"),
 				DriveId:        parseDriveId(c),
 				ExcludeNames:   c.StringSlice("exn"),
-				BlockSize:      int64(c.Int("bs") * 1024),
+				BlockSize:      uploadBlockSize(c),
+				Encrypt:        c.String("encrypt"),
+				ReadAhead:      c.Int("read-ahead"),
+				VerifySpace:    c.Bool("verify-space"),
+				SkipHidden:     c.Bool("skip-hidden"),
+				PartSizeAuto:   c.Bool("part-size-auto"),
 			})
 
 			// 释放文件锁
@@ -218,6 +260,9 @@ func CmdUpload() cli.Command {
 // RunUpload 执行文件上传
 func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 	activeUser := GetActiveUser()
+	if opt != nil && opt.User != nil {
+		activeUser = opt.User
+	}
 	activeUser.PanClient().OpenapiPanClient().EnableCache()
 	activeUser.PanClient().OpenapiPanClient().ClearCache()
 	defer activeUser.PanClient().OpenapiPanClient().DisableCache()
@@ -264,7 +309,23 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 		activeUser.PanClient().OpenapiPanClient().SetTimeout(time.Duration(opt.MaxTimeoutSec) * time.Second)
 	}
 
-	fmt.Printf("\n[0] 当前文件上传最大并发量为: %d, 上传分片大小为: %s\n", opt.AllParallel, converter.ConvertFileSize(opt.BlockSize, 2))
+	// 测量上传带宽，用于自动计算分片大小
+	var uploadBandwidth int64
+	if opt.PartSizeAuto {
+		bandwidth, err := panupload.MeasureUploadBandwidth(activeUser.PanClient(), opt.DriveId)
+		if err != nil {
+			fmt.Printf("测量上传带宽失败, 使用默认分片大小: %s\n", err)
+		} else {
+			uploadBandwidth = bandwidth
+			fmt.Printf("\n[0] 当前上传带宽约为: %s/s, 将按每个分片约 %d 秒自动计算分片大小\n", converter.ConvertFileSize(bandwidth, 2), int(panupload.AutoBlockDuration.Seconds()))
+		}
+	}
+
+	if uploadBandwidth > 0 {
+		fmt.Printf("\n[0] 当前文件上传最大并发量为: %d, 上传分片大小为: 自动\n", opt.AllParallel)
+	} else {
+		fmt.Printf("\n[0] 当前文件上传最大并发量为: %d, 上传分片大小为: %s\n", opt.AllParallel, converter.ConvertFileSize(opt.BlockSize, 2))
+	}
 
 	savePath = activeUser.PathJoin(opt.DriveId, savePath)
 	_, err1 := activeUser.PanClient().OpenapiPanClient().FileInfoByPath(opt.DriveId, savePath)
@@ -278,6 +339,11 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 		return
 	}
 
+	// 检查网盘剩余空间
+	if opt.VerifySpace && !verifyUploadSpace(localPaths, opt.ExcludeNames, opt.SkipHidden) {
+		return
+	}
+
 	// 打开上传状态数据库
 	uploadDatabase, err := panupload.NewUploadingDatabase()
 	if err != nil {
@@ -344,6 +410,12 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 				return filepath.SkipDir
 			}
 
+			// 跳过隐藏文件, 命令行直接指定的路径除外
+			if opt.SkipHidden && file.LogicPath != curPath && localfile.IsHiddenFile(file.RealPath) {
+				fmt.Printf("跳过隐藏文件: %s\n", file.LogicPath)
+				return filepath.SkipDir
+			}
+
 			subSavePath := strings.TrimPrefix(file.LogicPath, localPathDir)
 
 			// 针对 windows 的目录处理
@@ -398,6 +470,9 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 					IsSkipSameName:    opt.IsSkipSameName,
 					GlobalSpeedsStat:  globalSpeedsStat,
 					FileRecorder:      fileRecorder,
+					EncryptPassphrase: opt.Encrypt,
+					ReadAhead:         opt.ReadAhead,
+					UploadBandwidth:   uploadBandwidth,
 				}, opt.MaxRetry)
 				fmt.Printf("[%s] 加入上传队列: %s\n", taskinfo.Id(), file.LogicPath)
 			} else {
@@ -455,3 +530,92 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 	}
 	activeUser.DeleteCache(GetAllPathFolderByPath(savePath))
 }
+
+type (
+	// uploadSpaceItem 上传前空间检查的文件
+	uploadSpaceItem struct {
+		LocalPath string
+		Size      int64
+		Fit       bool // 按顺序上传时剩余空间是否足够
+	}
+)
+
+// fitUploadSpace 按照上传顺序累计文件大小, 标记剩余空间 available 能放下的文件, 返回所有文件的总大小
+func fitUploadSpace(items []*uploadSpaceItem, available int64) (totalSize int64) {
+	for _, item := range items {
+		totalSize += item.Size
+		item.Fit = totalSize <= available
+	}
+	return
+}
+
+// verifyUploadSpace 统计本地文件的总大小并和网盘剩余空间比较, 空间不足时列出放不下的文件, 返回是否可以继续上传
+func verifyUploadSpace(localPaths []string, excludeNames []string, skipHidden bool) bool {
+	items := make([]*uploadSpaceItem, 0)
+	for _, curPath := range localPaths {
+		curPath = filepath.Clean(curPath)
+		if utils.IsExcludeFile(curPath, &excludeNames) {
+			continue
+		}
+		walkFunc := func(file localfile.SymlinkFile, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			excluded := utils.IsExcludeFile(file.LogicPath, &excludeNames) ||
+				(skipHidden && file.LogicPath != curPath && localfile.IsHiddenFile(file.RealPath))
+			if fi.IsDir() {
+				if excluded {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// 文件返回 SkipDir 会跳过同一目录下剩余的文件, 这里只忽略当前文件
+			if !excluded {
+				items = append(items, &uploadSpaceItem{LocalPath: file.LogicPath, Size: fi.Size()})
+			}
+			return nil
+		}
+		if err := localfile.WalkAllFile(localfile.NewSymlinkFile(curPath), walkFunc); err != nil && err != filepath.SkipDir {
+			fmt.Printf("警告: 遍历错误: %s\n", err)
+		}
+	}
+
+	q, err := RunGetQuotaInfo()
+	if err != nil {
+		fmt.Printf("获取网盘空间信息失败: %s\n", err)
+		return false
+	}
+	available := q.Quota - q.UsedSize
+	if available < 0 {
+		available = 0
+	}
+	totalSize := fitUploadSpace(items, available)
+	if totalSize <= available {
+		fmt.Printf("网盘剩余空间: %s, 待上传文件总大小: %s, 空间充足\n",
+			converter.ConvertFileSize(available, 2), converter.ConvertFileSize(totalSize, 2))
+		return true
+	}
+
+	fmt.Printf("网盘剩余空间不足, 剩余空间: %s, 待上传文件总大小: %s\n",
+		converter.ConvertFileSize(available, 2), converter.ConvertFileSize(totalSize, 2))
+	tb := cmdtable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "文件", "大小", "空间检查"})
+	for k, item := range items {
+		result := "可以上传"
+		if !item.Fit {
+			result = "空间不足"
+		}
+		tb.Append([]string{strconv.Itoa(k + 1), item.LocalPath, converter.ConvertFileSize(item.Size, 2), result})
+	}
+	tb.Render()
+	fmt.Printf("已取消上传\n")
+	return false
+}
+
+// uploadBlockSize 上传分片大小，未指定 -bs 参数时使用配置的默认分片大小
+func uploadBlockSize(c *cli.Context) int64 {
+	if !c.IsSet("bs") && config.Config.ChunkSizeMB > 0 {
+		return int64(config.Config.ChunkSizeMB) * converter.MB
+	}
+	return int64(c.Int("bs") * 1024)
+}
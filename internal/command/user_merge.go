@@ -0,0 +1,449 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package command
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan_web"
+	"github.com/tickstep/aliyunpan/cmder"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/library-go/requester"
+	"github.com/urfave/cli"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// userMergeSaveWaitTimeout 等待服务端异步保存分享文件完成的最长时间
+	userMergeSaveWaitTimeout = 2 * time.Minute
+)
+
+type (
+	// userMergeFile 源账号中需要合并的文件
+	userMergeFile struct {
+		file    *aliyunpan.FileEntity
+		relPath string // 相对源目录的路径
+	}
+)
+
+func CmdUser() cli.Command {
+	return cli.Command{
+		Name:      "user",
+		Usage:     "账号管理",
+		UsageText: cmder.App().Name + " user",
+		Category:  "阿里云盘账号",
+		Before:    ReloadConfigFunc,
+		Action: func(c *cli.Context) error {
+			cli.ShowCommandHelp(c, c.Command.Name)
+			return nil
+		},
+		Subcommands: []cli.Command{
+			{
+				Name:      "merge",
+				Usage:     "将另一个已登录账号资源库的文件合并到当前账号或者指定账号",
+				UsageText: cmder.App().Name + " user merge [arguments...] <源账号uid> <源账号资源库目录> <目标账号目录>",
+				Description: `
+	阿里云盘不支持跨账号直接复制文件，合并通过以下步骤完成：
+	1. 在源账号资源库中为指定目录下的所有文件创建一个临时的私密分享
+	2. 目标账号保存该分享到目标目录(服务端复制)，不会覆盖目标目录中已经存在的同名文件
+	3. 取消源账号的临时分享
+	4. 逐个文件(包括子目录中的文件)比较源文件和目标文件的SHA1，服务端复制失败的文件先下载到本地再上传到目标账号
+	5. 指定 -delete-source 时，校验通过后将源文件移到回收站
+	源账号和目标账号必须已经登录（通过 loglist 查看），并且两个账号都需要登录WEB客户端。目标账号默认为当前账号。
+
+	示例:
+
+	将账号 1234 资源库中 /我的资源 目录下的文件合并到当前账号的 /合并 目录
+	aliyunpan user merge 1234 /我的资源 /合并
+
+	将账号 1234 资源库中 /我的资源 目录下的文件合并到账号 5678 的 /合并 目录，校验通过后删除源文件
+	aliyunpan user merge -dst-user 5678 -delete-source 1234 /我的资源 /合并
+`,
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 3 {
+						cli.ShowCommandHelp(c, c.Command.Name)
+						return nil
+					}
+					if config.Config.ActiveUser() == nil {
+						fmt.Println("未登录账号")
+						return nil
+					}
+					RunUserMerge(c.Args().Get(0), c.String("dst-user"), c.Args().Get(1), c.Args().Get(2), c.String("driveId"), c.Bool("delete-source"))
+					return nil
+				},
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "dst-user",
+						Usage: "目标账号的UID，默认为当前账号",
+					},
+					cli.StringFlag{
+						Name:  "driveId",
+						Usage: "目标账号的网盘ID，默认为目标账号当前使用的网盘",
+						Value: "",
+					},
+					cli.BoolFlag{
+						Name:  "delete-source",
+						Usage: "文件校验通过后将源账号中的文件移到回收站",
+					},
+				},
+			},
+		},
+	}
+}
+
+// loadMergeUser 获取已登录的账号并初始化客户端, uid为空代表当前账号
+func loadMergeUser(uid string) (*config.PanUser, error) {
+	activeUser := GetActiveUser()
+	if uid == "" || uid == activeUser.UserId {
+		return activeUser, nil
+	}
+	var user *config.PanUser
+	for _, u := range config.Config.UserList {
+		if u.UserId == uid {
+			user = u
+			break
+		}
+	}
+	if user == nil {
+		return nil, fmt.Errorf("账号未登录: %s", uid)
+	}
+	user, err := config.SetupUserByCookie(user.OpenapiToken, user.WebapiToken,
+		user.TicketId, user.UserId,
+		config.Config.DeviceId, config.Config.DeviceName,
+		config.Config.ClientId, config.Config.ClientSecret)
+	if user == nil {
+		return nil, fmt.Errorf("账号登录失败: %s, %s", uid, err)
+	}
+	return user, nil
+}
+
+// RunUserMerge 将源账号资源库 srcPanPath 下的文件(包括子目录)合并到目标账号的 dstPanPath 目录.
+// 优先通过临时分享在服务端复制, 复制失败的文件下载后再上传. dstUserId 为空代表当前账号,
+// deleteSource 为true时将校验通过的源文件移到回收站
+func RunUserMerge(srcUserId, dstUserId, srcPanPath, dstPanPath, dstDriveId string, deleteSource bool) {
+	srcUser, err := loadMergeUser(srcUserId)
+	if err != nil {
+		fmt.Printf("源%s\n", err)
+		return
+	}
+	dstUser, err := loadMergeUser(dstUserId)
+	if err != nil {
+		fmt.Printf("目标%s\n", err)
+		return
+	}
+	if srcUser.UserId == dstUser.UserId {
+		fmt.Println("源账号和目标账号不能是同一个账号")
+		return
+	}
+	if srcUser.PanClient().WebapiPanClient() == nil {
+		fmt.Println("源账号WEB客户端未登录，请登录后再使用该命令")
+		return
+	}
+	if dstUser.PanClient().WebapiPanClient() == nil {
+		fmt.Println("目标账号WEB客户端未登录，请登录后再使用该命令")
+		return
+	}
+	if dstDriveId == "" {
+		dstDriveId = dstUser.ActiveDriveId
+	}
+
+	// 只有资源库支持创建分享链接
+	srcDriveId := srcUser.DriveList.GetResourceDriveId()
+	srcPanPath = path.Clean(srcUser.PathJoin(srcDriveId, srcPanPath))
+	srcDir, apierr := srcUser.PanClient().OpenapiPanClient().FileInfoByPath(srcDriveId, srcPanPath)
+	if apierr != nil || srcDir == nil {
+		fmt.Printf("源账号资源库目录不存在: %s\n", srcPanPath)
+		return
+	}
+	dstPanPath = path.Clean(dstUser.PathJoin(dstDriveId, dstPanPath))
+	dstDir, apierr := dstUser.PanClient().OpenapiPanClient().FileInfoByPath(dstDriveId, dstPanPath)
+	if apierr != nil || dstDir == nil || !dstDir.IsFolder() {
+		fmt.Printf("目标账号目录不存在: %s\n", dstPanPath)
+		return
+	}
+
+	// 递归获取源文件, 分享只需要顶层的文件和目录
+	mergeFiles, topFidList, err := listUserMergeFiles(srcUser, srcDriveId, srcDir)
+	if err != nil {
+		fmt.Printf("获取源账号文件列表失败: %s\n", err)
+		return
+	}
+	if len(mergeFiles) == 0 {
+		fmt.Println("源账号目录为空，没有需要合并的文件")
+		return
+	}
+	fmt.Printf("源账号 %s 共 %d 个文件需要合并\n", srcUser.Nickname, len(mergeFiles))
+
+	// 服务端复制
+	hasAsyncTask := false
+	sharePwd := RandomStr(SharePasswordLength)
+	share, apierr := srcUser.PanClient().WebapiPanClient().ShareLinkCreate(aliyunpan_web.ShareCreateParam{
+		DriveId:    srcDriveId,
+		SharePwd:   sharePwd,
+		Expiration: time.Now().Add(24 * time.Hour).Format("2006-01-02 15:04:05"),
+		FileIdList: topFidList,
+	})
+	if apierr != nil || share == nil {
+		fmt.Printf("源账号创建临时分享失败, 全部文件将下载后再上传: %s\n", apierr)
+	} else {
+		fmt.Printf("源账号创建临时分享成功, 共 %d 项, 正在保存到目标账号\n", len(topFidList))
+		hasAsyncTask, err = saveUserMergeShare(dstUser, dstDriveId, dstDir.FileId, share.ShareId, sharePwd)
+		if err != nil {
+			fmt.Printf("目标账号保存分享失败, 全部文件将下载后再上传: %s\n", err)
+		}
+		if _, apierr = srcUser.PanClient().WebapiPanClient().ShareLinkCancel([]string{share.ShareId}); apierr != nil {
+			fmt.Printf("取消源账号临时分享失败, 请手动取消: %s, %s\n", share.ShareId, apierr)
+		}
+	}
+
+	// 逐个文件校验, 服务端复制失败的文件下载后再上传
+	dstFiles := listUserMergeDstFiles(dstUser, dstDriveId, dstPanPath, mergeFiles, hasAsyncTask)
+	verified := []*aliyunpan.FileEntity{}
+	failedCount := 0
+	for k, mf := range mergeFiles {
+		prefix := fmt.Sprintf("[%d/%d]", k+1, len(mergeFiles))
+		df := dstFiles[mf.relPath]
+		if df == nil {
+			fmt.Printf("%s 服务端复制失败, 下载后再上传: %s\n", prefix, mf.relPath)
+			if err = transferUserMergeFile(srcUser, dstUser, dstDriveId, path.Dir(path.Join(dstPanPath, mf.relPath)), mf.file); err != nil {
+				fmt.Printf("%s 传输失败: %s, %s\n", prefix, mf.relPath, err)
+				failedCount++
+				continue
+			}
+			df, _ = dstUser.PanClient().OpenapiPanClient().FileInfoByPath(dstDriveId, path.Join(dstPanPath, mf.relPath))
+		}
+		if df == nil {
+			fmt.Printf("%s 目标文件不存在: %s\n", prefix, mf.relPath)
+			failedCount++
+			continue
+		}
+		if df.FileSize != mf.file.FileSize || !strings.EqualFold(df.ContentHash, mf.file.ContentHash) {
+			fmt.Printf("%s 校验失败, 目标目录中已存在内容不同的同名文件: %s\n", prefix, mf.relPath)
+			failedCount++
+			continue
+		}
+		fmt.Printf("%s 校验通过: %s\n", prefix, mf.relPath)
+		verified = append(verified, mf.file)
+	}
+	dstUser.DeleteCache(GetAllPathFolderByPath(dstPanPath))
+	fmt.Printf("\n合并完成, 校验通过 %d 个文件, 失败 %d 个文件\n", len(verified), failedCount)
+
+	if !deleteSource || len(verified) == 0 {
+		return
+	}
+	deleteUserMergeSource(srcUser, srcDriveId, srcDir, topFidList, verified, failedCount == 0)
+}
+
+// listUserMergeFiles 递归获取源目录下的所有文件, 返回文件列表和顶层文件/目录的ID列表
+func listUserMergeFiles(user *config.PanUser, driveId string, srcDir *aliyunpan.FileEntity) ([]*userMergeFile, []string, error) {
+	if !srcDir.IsFolder() {
+		return []*userMergeFile{{file: srcDir, relPath: srcDir.FileName}}, []string{srcDir.FileId}, nil
+	}
+	var listErr error
+	fileList := user.PanClient().OpenapiPanClient().FilesDirectoriesRecurseList(driveId, srcDir.Path, func(depth int, _ string, fd *aliyunpan.FileEntity, apierr *apierror.ApiError) bool {
+		if apierr != nil {
+			listErr = apierr
+			return false
+		}
+		return true
+	})
+	if listErr != nil {
+		return nil, nil, listErr
+	}
+	mergeFiles := []*userMergeFile{}
+	topFidList := []string{}
+	for _, f := range fileList {
+		if path.Dir(f.Path) == srcDir.Path {
+			topFidList = append(topFidList, f.FileId)
+		}
+		if f.IsFolder() {
+			continue
+		}
+		mergeFiles = append(mergeFiles, &userMergeFile{
+			file:    f,
+			relPath: strings.TrimPrefix(f.Path, srcDir.Path+"/"),
+		})
+	}
+	return mergeFiles, topFidList, nil
+}
+
+// saveUserMergeShare 目标账号保存分享中的全部文件到目标目录, 同名文件不会被覆盖. 返回是否有异步保存的任务
+func saveUserMergeShare(user *config.PanUser, driveId, toParentFileId, shareId, sharePwd string) (bool, error) {
+	webClient := user.PanClient().WebapiPanClient()
+	token, apierr := webClient.GetShareToken(shareId, sharePwd)
+	if apierr != nil {
+		return false, apierr
+	}
+	list, apierr := webClient.GetListByShare(token.ShareToken, shareId, "")
+	if apierr != nil {
+		return false, apierr
+	}
+	items := list.Items
+	for list.NextMarker != "" {
+		list, apierr = webClient.GetListByShare(token.ShareToken, shareId, list.NextMarker)
+		if apierr != nil {
+			return false, apierr
+		}
+		items = append(items, list.Items...)
+	}
+
+	params := []*aliyunpan_web.FileSaveParam{}
+	for _, item := range items {
+		params = append(params, &aliyunpan_web.FileSaveParam{
+			ShareID:        shareId,
+			FileId:         item.FileID,
+			AutoRename:     false,
+			ToDriveId:      driveId,
+			ToParentFileId: toParentFileId,
+		})
+	}
+	result, apierr := webClient.FileCopy(token.ShareToken, params)
+	if apierr != nil {
+		return false, apierr
+	}
+	hasAsyncTask := false
+	for _, item := range result {
+		if item.AsyncTaskId != "" {
+			hasAsyncTask = true
+		}
+	}
+	return hasAsyncTask, nil
+}
+
+// listUserMergeDstFiles 获取目标目录下的文件, key为相对目标目录的路径.
+// 目录是服务端异步复制的, 有文件缺失时等待一段时间后重新获取
+func listUserMergeDstFiles(user *config.PanUser, driveId, dstPanPath string, mergeFiles []*userMergeFile, hasAsyncTask bool) map[string]*aliyunpan.FileEntity {
+	deadline := time.Now().Add(userMergeSaveWaitTimeout)
+	for {
+		dstFiles := map[string]*aliyunpan.FileEntity{}
+		fileList := user.PanClient().OpenapiPanClient().FilesDirectoriesRecurseList(driveId, dstPanPath, nil)
+		for _, f := range fileList {
+			if !f.IsFolder() {
+				dstFiles[strings.TrimPrefix(f.Path, dstPanPath+"/")] = f
+			}
+		}
+		missing := 0
+		for _, mf := range mergeFiles {
+			if dstFiles[mf.relPath] == nil {
+				missing++
+			}
+		}
+		if missing == 0 || !hasAsyncTask || time.Now().After(deadline) {
+			return dstFiles
+		}
+		fmt.Printf("等待服务端复制完成, 还有 %d 个文件\n", missing)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// transferUserMergeFile 从源账号下载文件, 校验SHA1后上传到目标账号的 dstDirPath 目录
+func transferUserMergeFile(srcUser, dstUser *config.PanUser, dstDriveId, dstDirPath string, file *aliyunpan.FileEntity) error {
+	durl, apierr := srcUser.PanClient().OpenapiPanClient().GetFileDownloadUrl(&aliyunpan.GetFileDownloadUrlParam{
+		DriveId: file.DriveId,
+		FileId:  file.FileId,
+	})
+	if apierr != nil {
+		return apierr
+	}
+
+	tmpDir, err := os.MkdirTemp("", "aliyunpan_user_merge")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	localPath := filepath.Join(tmpDir, file.FileName)
+	if err = downloadUserMergeFile(durl.Url, localPath, file.ContentHash); err != nil {
+		return err
+	}
+
+	RunUpload([]string{localPath}, dstDirPath, &UploadOptions{
+		User:         dstUser,
+		DriveId:      dstDriveId,
+		MaxRetry:     DefaultUploadMaxRetry,
+		ShowProgress: true,
+		BlockSize:    10240 * 1024,
+	})
+	return nil
+}
+
+// downloadUserMergeFile 下载文件到本地并校验SHA1, contentHash 为空则不校验
+func downloadUserMergeFile(url, localPath, contentHash string) error {
+	client := requester.NewHTTPClient()
+	client.SetTimeout(0)
+	resp, err := client.Req("GET", url, nil, map[string]string{
+		"Referer": aliyunpanReferer,
+	})
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("下载失败, 状态码: %d", resp.StatusCode)
+	}
+
+	localFile, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+	h := sha1.New()
+	if _, err = io.Copy(io.MultiWriter(localFile, h), resp.Body); err != nil {
+		return err
+	}
+	if contentHash != "" && !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), contentHash) {
+		return fmt.Errorf("下载的文件SHA1和源文件不一致")
+	}
+	return nil
+}
+
+// deleteUserMergeSource 将校验通过的源文件移到回收站. 全部文件校验通过时直接删除顶层的文件和目录
+func deleteUserMergeSource(srcUser *config.PanUser, driveId string, srcDir *aliyunpan.FileEntity, topFidList []string, verified []*aliyunpan.FileEntity, allVerified bool) {
+	fidList := topFidList
+	if !allVerified {
+		fidList = []string{}
+		for _, f := range verified {
+			fidList = append(fidList, f.FileId)
+		}
+	}
+	failed := 0
+	for _, fid := range fidList {
+		r, apierr := srcUser.PanClient().OpenapiPanClient().FileDelete(&aliyunpan.FileBatchActionParam{
+			DriveId: driveId,
+			FileId:  fid,
+		})
+		if apierr != nil || !r.Success {
+			failed++
+		}
+	}
+	srcUser.DeleteCache(GetAllPathFolderByPath(srcDir.Path))
+	if failed > 0 {
+		fmt.Printf("删除源文件完成, %d 项删除失败\n", failed)
+		return
+	}
+	if allVerified {
+		fmt.Printf("已将源目录 %s 中的文件移到回收站\n", srcDir.Path)
+	} else {
+		fmt.Printf("已将校验通过的 %d 个源文件移到回收站, 校验失败的文件保留\n", len(fidList))
+	}
+}
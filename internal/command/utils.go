@@ -42,6 +42,21 @@ var (
 		if err != nil {
 			fmt.Printf("重载配置错误: %s\n", err)
 		}
+		if c == nil {
+			// 定时刷新Token等内部调用不检查配置
+			return nil
+		}
+		// 先刷新即将过期的Token再检查配置, 避免对可以自动刷新的Token误报过期
+		if activeUser := config.Config.ActiveUser(); activeUser != nil && activeUser.PanClient() != nil {
+			webTokenRefreshed := RefreshWebTokenInNeed(activeUser, config.Config.DeviceName)
+			openTokenRefreshed := RefreshOpenTokenInNeed(activeUser)
+			if webTokenRefreshed || openTokenRefreshed {
+				SaveConfigFunc(nil)
+			}
+		}
+		for _, ve := range config.ValidateConfig(config.Config) {
+			fmt.Printf("配置警告: %s\n", ve.Error())
+		}
 		return nil
 	}
 
@@ -0,0 +1,246 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// backupIncludeFiles 备份配置目录时包含的文件, 其他文件(程序本身, 下载目录, 同步数据库等)不会备份
+	backupIncludeFiles = []string{ConfigName, "share_expiry_watch.json", "downloads.json", "tag_database.json"}
+
+	// backupIncludeDirs 备份配置目录时包含的子目录
+	backupIncludeDirs = []string{"plugin"}
+
+	// backupTokenFields 不包含Token备份时, 从每个账号中移除的字段
+	backupTokenFields = []string{"ticketId", "webapiToken", "openapiToken"}
+)
+
+// isBackupEntry 判断相对配置目录的路径是否属于备份的范围
+func isBackupEntry(relPath string) bool {
+	relPath = strings.TrimSuffix(relPath, "/")
+	for _, f := range backupIncludeFiles {
+		if relPath == f {
+			return true
+		}
+	}
+	for _, d := range backupIncludeDirs {
+		if relPath == d || strings.HasPrefix(relPath, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateConfigBackup 将配置目录中的配置文件打包成zip文件, 返回打包的文件数量.
+// includeTokens 为false时, 配置文件中所有账号的授权Token不会写入备份
+func CreateConfigBackup(zipPath, configDir string, includeTokens bool) (int, error) {
+	fp, err := os.Create(zipPath)
+	if err != nil {
+		return 0, err
+	}
+	defer fp.Close()
+	zw := zip.NewWriter(fp)
+
+	count := 0
+	walkFunc := func(filePath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(configDir, filePath)
+		if err != nil {
+			return err
+		}
+		if err = addBackupFile(zw, filePath, filepath.ToSlash(relPath), fi, includeTokens); err != nil {
+			return err
+		}
+		count++
+		return nil
+	}
+	for _, name := range append(append([]string{}, backupIncludeFiles...), backupIncludeDirs...) {
+		p := filepath.Join(configDir, name)
+		if _, e := os.Stat(p); os.IsNotExist(e) {
+			continue
+		}
+		if err = filepath.Walk(p, walkFunc); err != nil {
+			zw.Close()
+			return count, err
+		}
+	}
+	return count, zw.Close()
+}
+
+// addBackupFile 将文件写入zip, 除需要移除Token的配置文件外, 文件内容直接从磁盘复制, 不整个读入内存
+func addBackupFile(zw *zip.Writer, filePath, relPath string, fi os.FileInfo, includeTokens bool) error {
+	header, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return err
+	}
+	header.Name = relPath
+	header.Method = zip.Deflate
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if relPath == ConfigName && !includeTokens {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		if data, err = stripConfigTokens(data); err != nil {
+			return fmt.Errorf("处理配置文件失败: %s", err)
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	in, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = io.Copy(w, in)
+	return err
+}
+
+// stripConfigTokens 移除配置文件内容中所有账号的授权Token
+func stripConfigTokens(data []byte) ([]byte, error) {
+	content := map[string]interface{}{}
+	if len(data) == 0 {
+		return data, nil
+	}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	if userList, ok := content["userList"].([]interface{}); ok {
+		for _, item := range userList {
+			if user, ok := item.(map[string]interface{}); ok {
+				for _, field := range backupTokenFields {
+					delete(user, field)
+				}
+			}
+		}
+	}
+	return json.MarshalIndent(content, "", " ")
+}
+
+// RestoreConfigBackup 将 CreateConfigBackup 创建的zip文件解压到配置目录, 覆盖已存在的文件, 返回恢复的文件列表.
+// 不属于备份范围的文件会被跳过, 避免覆盖正在使用的同步数据库等文件
+func RestoreConfigBackup(zipPath, configDir string) ([]string, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	// 先检查所有的路径, 避免解压到一半才发现非法的文件
+	root := filepath.Clean(configDir)
+	for _, f := range zr.File {
+		target := filepath.Join(root, filepath.FromSlash(f.Name))
+		if target != root && !strings.HasPrefix(target, root+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("备份文件包含非法的路径: %s", f.Name)
+		}
+	}
+
+	restored := []string{}
+	for _, f := range zr.File {
+		if !isBackupEntry(f.Name) {
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(f.Name))
+		if f.FileInfo().IsDir() {
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return restored, err
+			}
+			continue
+		}
+		if err = restoreZipFile(f, target); err != nil {
+			return restored, err
+		}
+		restored = append(restored, f.Name)
+	}
+	return restored, nil
+}
+
+func restoreZipFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// 不删除原文件, 配置文件可能正在被当前进程打开
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, rc)
+	return err
+}
+
+// UserTokenSnapshot 复制所有账号的授权Token, 用于恢复不包含Token的备份后补全账号的Token
+func (c *PanConfig) UserTokenSnapshot() PanUserList {
+	snapshot := PanUserList{}
+	for _, u := range c.UserList {
+		item := &PanUser{
+			UserId:   u.UserId,
+			TicketId: u.TicketId,
+		}
+		if u.WebapiToken != nil {
+			t := *u.WebapiToken
+			item.WebapiToken = &t
+		}
+		if u.OpenapiToken != nil {
+			t := *u.OpenapiToken
+			item.OpenapiToken = &t
+		}
+		snapshot = append(snapshot, item)
+	}
+	return snapshot
+}
+
+// FillMissingTokens 没有授权Token的账号使用 snapshot 中同一账号的Token, 返回补全的账号数量
+func (c *PanConfig) FillMissingTokens(snapshot PanUserList) int {
+	count := 0
+	for _, u := range c.UserList {
+		if u.OpenapiToken != nil && u.OpenapiToken.AccessToken != "" {
+			continue
+		}
+		for _, s := range snapshot {
+			if s.UserId != u.UserId {
+				continue
+			}
+			u.TicketId = s.TicketId
+			u.WebapiToken = s.WebapiToken
+			u.OpenapiToken = s.OpenapiToken
+			count++
+			break
+		}
+	}
+	return count
+}
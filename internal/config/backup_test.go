@@ -0,0 +1,113 @@
+package config
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigBackupAndRestore(t *testing.T) {
+	configDir := t.TempDir()
+	content := `{"activeUID":"1001","userList":[{"userId":"1001","nickname":"小A","ticketId":"t1","openapiToken":{"accessToken":"secret-token","expired":1}}]}`
+	os.WriteFile(filepath.Join(configDir, ConfigName), []byte(content), 0644)
+	os.MkdirAll(filepath.Join(configDir, "plugin"), 0755)
+	os.WriteFile(filepath.Join(configDir, "plugin", "js.js"), []byte("plugin"), 0644)
+	os.MkdirAll(filepath.Join(configDir, "logs"), 0755)
+	os.WriteFile(filepath.Join(configDir, "logs", "1.log"), []byte("log"), 0644)
+	os.MkdirAll(filepath.Join(configDir, "sync_drive"), 0755)
+	os.WriteFile(filepath.Join(configDir, "sync_drive", "sync.bolt"), []byte("db"), 0644)
+	os.WriteFile(filepath.Join(configDir, "aliyunpan"), []byte("binary"), 0755)
+
+	zipPath := filepath.Join(t.TempDir(), "backup.zip")
+	count, err := CreateConfigBackup(zipPath, configDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("want 2 files, got %d", count)
+	}
+
+	restoreDir := t.TempDir()
+	files, err := RestoreConfigBackup(zipPath, restoreDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("unexpected restored files: %v", files)
+	}
+	data, err := os.ReadFile(filepath.Join(restoreDir, ConfigName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-token") || strings.Contains(string(data), "ticketId") {
+		t.Fatalf("token should be stripped: %s", data)
+	}
+	if !strings.Contains(string(data), "小A") {
+		t.Fatalf("user info should be kept: %s", data)
+	}
+	if _, err = os.Stat(filepath.Join(restoreDir, "plugin", "js.js")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 包含Token
+	count, err = CreateConfigBackup(zipPath, configDir, true)
+	if err != nil || count != 2 {
+		t.Fatalf("backup with tokens: %d, %v", count, err)
+	}
+	RestoreConfigBackup(zipPath, restoreDir)
+	data, _ = os.ReadFile(filepath.Join(restoreDir, ConfigName))
+	if !strings.Contains(string(data), "secret-token") {
+		t.Fatalf("token should be kept: %s", data)
+	}
+}
+
+func TestRestoreConfigBackupIllegalPath(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "evil.zip")
+	fp, _ := os.Create(zipPath)
+	zw := zip.NewWriter(fp)
+	w, _ := zw.Create("../evil.txt")
+	w.Write([]byte("evil"))
+	zw.Close()
+	fp.Close()
+
+	restoreDir := t.TempDir()
+	if _, err := RestoreConfigBackup(zipPath, restoreDir); err == nil {
+		t.Fatal("illegal path should return error")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(restoreDir), "evil.txt")); err == nil {
+		t.Fatal("file should not be written outside config dir")
+	}
+}
+
+func TestFillMissingTokens(t *testing.T) {
+	previous := &PanConfig{
+		UserList: PanUserList{
+			{UserId: "1001", TicketId: "t1", OpenapiToken: &PanClientToken{AccessToken: "a1"}},
+			{UserId: "1002", TicketId: "t2", OpenapiToken: &PanClientToken{AccessToken: "a2"}},
+		},
+	}
+	snapshot := previous.UserTokenSnapshot()
+	previous.UserList[0].OpenapiToken.AccessToken = "changed"
+
+	c := &PanConfig{
+		UserList: PanUserList{
+			{UserId: "1001"},
+			{UserId: "1002", OpenapiToken: &PanClientToken{AccessToken: "restored"}},
+			{UserId: "1003"},
+		},
+	}
+	if n := c.FillMissingTokens(snapshot); n != 1 {
+		t.Fatalf("want 1 filled user, got %d", n)
+	}
+	if c.UserList[0].OpenapiToken == nil || c.UserList[0].OpenapiToken.AccessToken != "a1" || c.UserList[0].TicketId != "t1" {
+		t.Fatalf("token of 1001 should be filled from snapshot: %+v", c.UserList[0])
+	}
+	if c.UserList[1].OpenapiToken.AccessToken != "restored" {
+		t.Fatal("existing token should not be overwritten")
+	}
+	if c.UserList[2].OpenapiToken != nil {
+		t.Fatal("unknown user should not be filled")
+	}
+}
@@ -5,9 +5,28 @@ import (
 	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
 	"github.com/tickstep/library-go/expires"
 	"path"
+	"sync/atomic"
 	"time"
 )
 
+type (
+	// CacheStats 目录列表缓存统计
+	CacheStats struct {
+		Hits    int64 // 命中次数
+		Misses  int64 // 未命中次数
+		Entries int   // 有效的缓存项数量
+	}
+)
+
+// HitRatio 缓存命中率, 没有查询记录时返回0
+func (cs CacheStats) HitRatio() float64 {
+	total := cs.Hits + cs.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(cs.Hits) / float64(total)
+}
+
 // DeleteCache 删除含有 dirs 的缓存
 func (pu *PanUser) DeleteCache(dirs []string) {
 	cache := pu.cacheOpMap.LazyInitCachePoolOp(pu.ActiveDriveId)
@@ -26,9 +45,61 @@ func (pu *PanUser) DeleteOneCache(dirPath string) {
 	pu.DeleteCache(ps)
 }
 
+// cacheDriveIds 可能存在缓存的网盘ID
+func (pu *PanUser) cacheDriveIds() []string {
+	driveIds := []string{}
+	if pu.ActiveDriveId != "" {
+		driveIds = append(driveIds, pu.ActiveDriveId)
+	}
+	for _, drive := range pu.DriveList {
+		if drive.DriveId != "" && drive.DriveId != pu.ActiveDriveId {
+			driveIds = append(driveIds, drive.DriveId)
+		}
+	}
+	return driveIds
+}
+
+// ClearCache 清除所有网盘的目录列表缓存, 并重置命中统计, 返回清除的缓存项数量
+func (pu *PanUser) ClearCache() (cleared int) {
+	for _, driveId := range pu.cacheDriveIds() {
+		pu.cacheOpMap.LazyInitCachePoolOp(driveId).Range(func(_ interface{}, _ expires.DataExpires) bool {
+			cleared++
+			return true
+		})
+		pu.cacheOpMap.RemoveCachePoolOp(driveId)
+	}
+	atomic.StoreInt64(&pu.cacheHits, 0)
+	atomic.StoreInt64(&pu.cacheMisses, 0)
+	return
+}
+
+// CacheStats 目录列表缓存统计, 已过期的缓存项不计入
+func (pu *PanUser) CacheStats() CacheStats {
+	stats := CacheStats{
+		Hits:   atomic.LoadInt64(&pu.cacheHits),
+		Misses: atomic.LoadInt64(&pu.cacheMisses),
+	}
+	for _, driveId := range pu.cacheDriveIds() {
+		pu.cacheOpMap.LazyInitCachePoolOp(driveId).Range(func(_ interface{}, _ expires.DataExpires) bool {
+			stats.Entries++
+			return true
+		})
+	}
+	return stats
+}
+
 // CacheFilesDirectoriesList 缓存获取
 func (pu *PanUser) CacheFilesDirectoriesList(pathStr string) (fdl aliyunpan.FileList, apiError *apierror.ApiError) {
+	missed := false
+	defer func() {
+		if missed {
+			atomic.AddInt64(&pu.cacheMisses, 1)
+		} else {
+			atomic.AddInt64(&pu.cacheHits, 1)
+		}
+	}()
 	data := pu.cacheOpMap.CacheOperation(pu.ActiveDriveId, pathStr+"_OrderByName", func() expires.DataExpires {
+		missed = true
 		var fi *aliyunpan.FileEntity
 		fi, apiError = pu.panClient.OpenapiPanClient().FileInfoByPath(pu.ActiveDriveId, pathStr)
 		if apiError != nil {
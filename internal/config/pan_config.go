@@ -52,6 +52,9 @@ const (
 	// MaxFileDownloadParallelNum 最大文件下载并发数量。过大会被阿里云盘风控，导致无法下载
 	MaxFileDownloadParallelNum = 20
 
+	// MaxChunkSizeMB 默认上传分片大小的最大值，单位MB
+	MaxChunkSizeMB = 100
+
 	// DefaultTokenServiceWebHost 默认的token服务
 	DefaultTokenServiceWebHost = "https://api.tickstep.com"
 	//DefaultTokenServiceWebHost = "http://localhost:8977"
@@ -90,10 +93,13 @@ type PanConfig struct {
 	CacheSize           int `json:"cacheSize"`           // 下载缓存
 	MaxDownloadParallel int `json:"maxDownloadParallel"` // 最大下载并发量，即同时下载文件最大数量
 	MaxUploadParallel   int `json:"maxUploadParallel"`   // 最大上传并发量，即同时上传文件最大数量
+	ChunkSizeMB         int `json:"chunkSizeMB"`         // 默认上传分片大小，单位MB，0代表使用upload命令的默认值
 
 	MaxDownloadRate int64 `json:"maxDownloadRate"` // 限制最大下载速度，单位 B/s, 即字节/每秒
 	MaxUploadRate   int64 `json:"maxUploadRate"`   // 限制最大上传速度，单位 B/s, 即字节/每秒
 
+	BandwidthProfiles map[string]int64 `json:"bandwidthProfiles"` // 下载限速方案，方案名称 -> 最大下载速度(B/s)，0代表不限制
+
 	SaveDir string `json:"saveDir"` // 下载储存路径
 
 	Proxy           string          `json:"proxy"`        // 代理
@@ -511,6 +517,29 @@ func (c *PanConfig) SwitchUser(uid string) (*PanUser, error) {
 	return nil, fmt.Errorf("未找到指定的账号")
 }
 
+// FindUser 根据UID、用户名或者昵称查找已登录的用户，优先匹配UID。
+// 用户名或者昵称对应多个账号时返回错误，需要使用UID指定
+func (c *PanConfig) FindUser(name string) (*PanUser, error) {
+	for _, u := range c.UserList {
+		if u.UserId == name {
+			return u, nil
+		}
+	}
+	var found *PanUser
+	for _, u := range c.UserList {
+		if u.AccountName == name || u.Nickname == name {
+			if found != nil {
+				return nil, fmt.Errorf("有多个账号的名称为 %s，请使用UID指定账号", name)
+			}
+			found = u
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("未找到指定的账号")
+	}
+	return found, nil
+}
+
 // DeleteUser 删除用户，并自动切换登录用户为用户列表第一个
 func (c *PanConfig) DeleteUser(uid string) (*PanUser, error) {
 	for idx, u := range c.UserList {
@@ -14,7 +14,9 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -80,6 +82,56 @@ func (c *PanConfig) SetMaxUploadRateByStr(sizeStr string) error {
 	return nil
 }
 
+// SetBandwidthProfileByStr 设置 bandwidth_profile, 格式为 名称=速度, 速度为空则删除该方案
+func (c *PanConfig) SetBandwidthProfileByStr(s string) error {
+	idx := strings.Index(s, "=")
+	if idx <= 0 {
+		return fmt.Errorf("格式错误, 应为 名称=速度, 例如 day=2MB")
+	}
+	name := strings.TrimSpace(s[:idx])
+	rateStr := strings.TrimSpace(s[idx+1:])
+	if name == "" {
+		return fmt.Errorf("方案名称不能为空")
+	}
+	if rateStr == "" {
+		delete(c.BandwidthProfiles, name)
+		return nil
+	}
+	rate, err := converter.ParseFileSizeStr(stripPerSecond(rateStr))
+	if err != nil {
+		return err
+	}
+	if c.BandwidthProfiles == nil {
+		c.BandwidthProfiles = map[string]int64{}
+	}
+	c.BandwidthProfiles[name] = rate
+	return nil
+}
+
+// BandwidthProfileNames 返回所有下载限速方案的名称, 按名称排序
+func (c *PanConfig) BandwidthProfileNames() []string {
+	names := make([]string, 0, len(c.BandwidthProfiles))
+	for name := range c.BandwidthProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetBandwidthProfile 获取下载限速方案的最大下载速度
+func (c *PanConfig) GetBandwidthProfile(name string) (int64, bool) {
+	rate, ok := c.BandwidthProfiles[name]
+	return rate, ok
+}
+
+func (c *PanConfig) bandwidthProfilesString() string {
+	items := make([]string, 0, len(c.BandwidthProfiles))
+	for _, name := range c.BandwidthProfileNames() {
+		items = append(items, name+"="+showMaxRate(c.BandwidthProfiles[name]))
+	}
+	return strings.Join(items, ", ")
+}
+
 // SetFileRecorderConfig 设置文件记录器
 func (c *PanConfig) SetFileRecorderConfig(config string) error {
 	if config == "1" || config == "2" {
@@ -111,8 +163,10 @@ func (c *PanConfig) PrintTable() {
 		[]string{"cache_size", converter.ConvertFileSize(int64(c.CacheSize), 2), "1KB ~ 256KB", "下载缓存, 如果硬盘占用高或下载速度慢, 请尝试调大此值"},
 		[]string{"max_download_parallel", strconv.Itoa(c.MaxDownloadParallel), "1 ~ 20", "最大下载并发量，即同时下载文件最大数量"},
 		[]string{"max_upload_parallel", strconv.Itoa(c.MaxUploadParallel), "1 ~ 20", "最大上传并发量，即同时上传文件最大数量"},
+		[]string{"chunk_size_mb", strconv.Itoa(c.ChunkSizeMB), "0 ~ 100", "默认上传分片大小，单位MB，0代表使用默认值。upload 命令指定 -bs 时以 -bs 为准"},
 		[]string{"max_download_rate", showMaxRate(c.MaxDownloadRate), "", "限制单个文件最大下载速度, 0代表不限制"},
 		[]string{"max_upload_rate", showMaxRate(c.MaxUploadRate), "", "限制单个文件最大上传速度, 0代表不限制"},
+		[]string{"bandwidth_profile", c.bandwidthProfilesString(), "名称=速度", "下载限速方案, 下载时使用 --bandwidth-profile 选择。速度为空则删除该方案"},
 		[]string{"savedir", c.SaveDir, "", "下载文件的储存目录"},
 		[]string{"proxy", c.Proxy, "", "设置代理, 支持 http/socks5 代理，例如: http://127.0.0.1:8888 或者 socks5://127.0.0.1:8889"},
 		[]string{"local_addrs", c.LocalAddrs, "", "绑定本地网卡地址, 多个地址用逗号隔开，支持网口名称，例如: 127.0.0.1,192.168.100.126,en0,eth0"},
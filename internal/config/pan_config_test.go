@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestFindUser(t *testing.T) {
+	c := &PanConfig{
+		UserList: PanUserList{
+			{UserId: "1001", AccountName: "alice", Nickname: "小A"},
+			{UserId: "1002", AccountName: "bob", Nickname: "小B"},
+			{UserId: "1003", AccountName: "bob", Nickname: "小C"},
+		},
+	}
+	if u, err := c.FindUser("1002"); err != nil || u.UserId != "1002" {
+		t.Fatalf("find by uid: %v, %v", u, err)
+	}
+	if u, err := c.FindUser("alice"); err != nil || u.UserId != "1001" {
+		t.Fatalf("find by account name: %v, %v", u, err)
+	}
+	if u, err := c.FindUser("小C"); err != nil || u.UserId != "1003" {
+		t.Fatalf("find by nickname: %v, %v", u, err)
+	}
+	if _, err := c.FindUser("bob"); err == nil {
+		t.Fatal("duplicated account name should return error")
+	}
+	if _, err := c.FindUser("nobody"); err == nil {
+		t.Fatal("unknown account should return error")
+	}
+}
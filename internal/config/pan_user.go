@@ -107,6 +107,10 @@ type PanUser struct {
 	// API客户端
 	panClient  *PanClient          `json:"-"`
 	cacheOpMap cachemap.CacheOpMap `json:"-"`
+
+	// 目录列表缓存的命中统计
+	cacheHits   int64 `json:"-"`
+	cacheMisses int64 `json:"-"`
 }
 
 type PanUserList []*PanUser
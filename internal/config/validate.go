@@ -0,0 +1,133 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// MinCacheSize 最小的下载缓存，小于该值会严重影响下载速度
+	MinCacheSize = 1024
+)
+
+type (
+	// ValidationError 配置检查错误
+	ValidationError struct {
+		Field   string // 配置项
+		Message string // 错误描述
+	}
+)
+
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", ve.Field, ve.Message)
+}
+
+// ValidateConfig 检查常见的配置错误，返回所有检查到的错误
+func ValidateConfig(c *PanConfig) []ValidationError {
+	if c == nil {
+		return []ValidationError{{Field: "config", Message: "配置未初始化"}}
+	}
+	errs := []ValidationError{}
+
+	if c.SaveDir != "" {
+		if fi, err := os.Stat(c.SaveDir); err != nil || !fi.IsDir() {
+			errs = append(errs, ValidationError{Field: "savedir", Message: "下载目录不存在: " + c.SaveDir})
+		}
+	}
+	if c.MaxDownloadRate < 0 {
+		errs = append(errs, ValidationError{Field: "max_download_rate", Message: fmt.Sprintf("无效的限速值: %d", c.MaxDownloadRate)})
+	}
+	if c.MaxUploadRate < 0 {
+		errs = append(errs, ValidationError{Field: "max_upload_rate", Message: fmt.Sprintf("无效的限速值: %d", c.MaxUploadRate)})
+	}
+	for name, rate := range c.BandwidthProfiles {
+		if rate < 0 {
+			errs = append(errs, ValidationError{Field: "bandwidth_profile", Message: fmt.Sprintf("无效的限速值: %s=%d", name, rate)})
+		}
+	}
+	if c.CacheSize != 0 && c.CacheSize < MinCacheSize {
+		errs = append(errs, ValidationError{Field: "cache_size", Message: fmt.Sprintf("下载缓存过小: %d, 最小为 %d", c.CacheSize, MinCacheSize)})
+	}
+	if c.MaxDownloadParallel < 0 {
+		errs = append(errs, ValidationError{Field: "max_download_parallel", Message: fmt.Sprintf("无效的并发数: %d", c.MaxDownloadParallel)})
+	}
+	if c.MaxUploadParallel < 0 {
+		errs = append(errs, ValidationError{Field: "max_upload_parallel", Message: fmt.Sprintf("无效的并发数: %d", c.MaxUploadParallel)})
+	}
+	if c.ChunkSizeMB < 0 || c.ChunkSizeMB > MaxChunkSizeMB {
+		errs = append(errs, ValidationError{Field: "chunk_size_mb", Message: fmt.Sprintf("无效的分片大小: %d", c.ChunkSizeMB)})
+	}
+	if c.DeviceId == "" {
+		errs = append(errs, ValidationError{Field: "deviceId", Message: "客户端ID为空"})
+	}
+
+	if c.ActiveUID != "" {
+		var user *PanUser
+		for _, u := range c.UserList {
+			if u.UserId == c.ActiveUID {
+				user = u
+				break
+			}
+		}
+		if user == nil {
+			errs = append(errs, ValidationError{Field: "activeUID", Message: "当前登录的账号不存在: " + c.ActiveUID})
+		} else {
+			now := time.Now()
+			if isTokenExpired(user.OpenapiToken, now) {
+				errs = append(errs, ValidationError{Field: "openapiToken", Message: "账号 " + user.Nickname + " 的Openapi Token已过期，请重新登录"})
+			}
+			if user.WebapiToken != nil && isTokenExpired(user.WebapiToken, now) {
+				errs = append(errs, ValidationError{Field: "webapiToken", Message: "账号 " + user.Nickname + " 的Webapi Token已过期，请重新登录"})
+			}
+		}
+	}
+	return errs
+}
+
+// isTokenExpired token是否已过期，优先使用 AccessToken 中 JWT 的 exp 声明
+func isTokenExpired(token *PanClientToken, now time.Time) bool {
+	if token == nil || token.AccessToken == "" {
+		return true
+	}
+	expired := token.Expired
+	if exp, ok := parseJwtExp(token.AccessToken); ok {
+		expired = exp
+	}
+	return expired > 0 && expired < now.Unix()
+}
+
+// parseJwtExp 解析 JWT 的 exp 声明，不是 JWT 则返回 false
+func parseJwtExp(accessToken string) (int64, bool) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return 0, false
+	}
+	claims := struct {
+		Exp int64 `json:"exp"`
+	}{}
+	if err = json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return 0, false
+	}
+	return claims.Exp, true
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestValidateConfig(t *testing.T) {
+	c := &PanConfig{
+		SaveDir:         "/not/exist/dir",
+		CacheSize:       100,
+		MaxDownloadRate: -1,
+		ChunkSizeMB:     MaxChunkSizeMB + 1,
+	}
+	fields := map[string]bool{}
+	for _, ve := range ValidateConfig(c) {
+		fields[ve.Field] = true
+	}
+	for _, field := range []string{"savedir", "cache_size", "max_download_rate", "chunk_size_mb"} {
+		if !fields[field] {
+			t.Errorf("want validation error for %s, got %v", field, fields)
+		}
+	}
+}
+
+func TestParseJwtExp(t *testing.T) {
+	exp := time.Now().Add(-time.Hour).Unix()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp)))
+	token := &PanClientToken{AccessToken: "eyJhbGciOiJSUzI1NiJ9." + payload + ".sig"}
+	if got, ok := parseJwtExp(token.AccessToken); !ok || got != exp {
+		t.Fatalf("want exp %d, got %d, %v", exp, got, ok)
+	}
+	if !isTokenExpired(token, time.Now()) {
+		t.Fatal("token should be expired")
+	}
+}
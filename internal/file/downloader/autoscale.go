@@ -0,0 +1,269 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan/library/requester/transfer"
+	"github.com/tickstep/library-go/logger"
+	"time"
+)
+
+const (
+	// DefaultAutoScaleInterval 默认的速度采样间隔
+	DefaultAutoScaleInterval = 10 * time.Second
+	// DefaultAutoScaleSpeedRatio 默认的扩容阈值, 速度低于峰值的该比例时增加worker
+	DefaultAutoScaleSpeedRatio = 0.5
+	// DefaultAutoScaleErrorRate 默认的缩容阈值, 出错的worker占比超过该值时减少worker
+	DefaultAutoScaleErrorRate = 0.5
+)
+
+type (
+	// AutoScaleConfig 根据实时速度动态调整worker数量的配置
+	AutoScaleConfig struct {
+		Interval   time.Duration // 速度采样间隔
+		SpeedRatio float64       // 速度低于峰值的该比例时增加worker
+		ErrorRate  float64       // 出错的worker占比超过该值时减少worker
+		MaxWorkers int           // worker数量上限
+	}
+
+	// WorkerFactory 创建新的worker, 除range以外的参数都需要设置好
+	WorkerFactory func(id int) *Worker
+
+	// autoScaler 动态调整worker数量
+	autoScaler struct {
+		config    AutoScaleConfig
+		newWorker WorkerFactory
+		peakSpeed int64
+	}
+)
+
+func (cfg *AutoScaleConfig) fix() {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultAutoScaleInterval
+	}
+	if cfg.SpeedRatio <= 0 || cfg.SpeedRatio >= 1 {
+		cfg.SpeedRatio = DefaultAutoScaleSpeedRatio
+	}
+	if cfg.ErrorRate <= 0 || cfg.ErrorRate > 1 {
+		cfg.ErrorRate = DefaultAutoScaleErrorRate
+	}
+	if cfg.MaxWorkers <= 0 {
+		cfg.MaxWorkers = MaxParallelWorkerCount
+	}
+}
+
+// SetAutoScale 开启worker数量的动态调整
+func (mt *Monitor) SetAutoScale(cfg AutoScaleConfig, newWorker WorkerFactory) {
+	if newWorker == nil {
+		return
+	}
+	cfg.fix()
+	mt.autoScaler = &autoScaler{
+		config:    cfg,
+		newWorker: newWorker,
+	}
+}
+
+// startAutoScale 定时采样下载速度, 采样结果发送给monitor的监控循环处理.
+// worker列表只在监控循环中替换, 替换时持有workersMu, 未开启时返回nil
+func (mt *Monitor) startAutoScale(cancelCtx context.Context) <-chan int64 {
+	if mt.autoScaler == nil {
+		return nil
+	}
+	sampleChan := make(chan int64, 1)
+	go func() {
+		ticker := time.NewTicker(mt.autoScaler.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-cancelCtx.Done():
+				return
+			case <-mt.completed:
+				return
+			case <-ticker.C:
+				select {
+				case sampleChan <- mt.status.SpeedsPerSecond():
+				default: // 上一次的采样还没有处理
+				}
+			}
+		}
+	}()
+	return sampleChan
+}
+
+// autoScale 出错比例过高时移除一个worker, 速度低于峰值的一定比例时增加worker
+func (mt *Monitor) autoScale(cancelCtx context.Context, speed int64) {
+	as := mt.autoScaler
+	workers := mt.getWorkers()
+	if len(workers) == 0 {
+		return
+	}
+
+	if speed > as.peakSpeed {
+		as.peakSpeed = speed
+	}
+
+	failedNum := 0
+	for _, worker := range workers {
+		if worker.Failed() {
+			failedNum++
+		}
+	}
+	if float64(failedNum)/float64(len(workers)) > as.config.ErrorRate {
+		mt.removeWorker()
+		return
+	}
+
+	if speed < int64(float64(as.peakSpeed)*as.config.SpeedRatio) && len(workers) < as.config.MaxWorkers {
+		mt.addWorker(cancelCtx)
+	}
+}
+
+// removeWorker 移除一个worker, 减少同时下载的连接数.
+// 优先移除空闲的worker, 其次是失败的worker, 最后是剩余最少的正在下载的worker.
+// 被取消的worker剩余的range留给空闲的worker继续下载
+func (mt *Monitor) removeWorker() {
+	workers := mt.getWorkers()
+	if len(workers) <= 1 {
+		return
+	}
+
+	index := -1
+	for k, worker := range workers {
+		if worker.Completed() && worker.GetRange().Len() <= 0 {
+			index = k
+			break
+		}
+	}
+	if index < 0 {
+		for k, worker := range workers {
+			if worker.Failed() && worker.status.statusCode != StatusCodeInternalError {
+				index = k
+				break
+			}
+		}
+	}
+	if index < 0 {
+		for k, worker := range workers {
+			if worker.status.statusCode != StatusCodeDownloading {
+				continue
+			}
+			if index < 0 || worker.GetRange().Len() < workers[index].GetRange().Len() {
+				index = k
+			}
+		}
+	}
+	if index < 0 {
+		return
+	}
+
+	worker := workers[index]
+	var left *transfer.Range
+	if !worker.Completed() {
+		// 停止该worker, 剩余的range不再由它下载
+		if err := worker.Cancel(); err != nil {
+			logger.Verbosef("DEBUG: cancel failed, worker id: %d, err: %s\n", worker.ID(), err)
+		}
+		workerRange := worker.GetRange()
+		if workerRange.Len() > 0 {
+			left = &transfer.Range{
+				Begin: workerRange.LoadBegin(),
+				End:   workerRange.LoadEnd(),
+			}
+		}
+		workerRange.StoreEnd(workerRange.LoadBegin())
+		worker.status.SetStatusCode(StatusCodeCanceled)
+	}
+
+	// 复制新的列表, 正在遍历旧列表的地方不受影响
+	mt.workersMu.Lock()
+	newWorkers := make(WorkerList, 0, len(workers)-1)
+	newWorkers = append(newWorkers, workers[:index]...)
+	newWorkers = append(newWorkers, workers[index+1:]...)
+	mt.workers = newWorkers
+	if left != nil {
+		mt.pendingRanges = append(mt.pendingRanges, left)
+	}
+	mt.workersMu.Unlock()
+	mt.lastAvaliableIndex = 0
+
+	if left != nil {
+		logger.Verbosef("MONITOR: auto scale, cancel worker[%d], left range: %s, workers: %d\n", worker.ID(), left.ShowDetails(), len(newWorkers))
+		return
+	}
+	logger.Verbosef("MONITOR: auto scale, remove idle worker[%d], workers: %d\n", worker.ID(), len(newWorkers))
+}
+
+// addWorker 增加一个worker, 优先分配新的range, 没有新的range时分担剩余最多的worker
+func (mt *Monitor) addWorker(cancelCtx context.Context) {
+	if !mt.resetController.CanReset() {
+		return
+	}
+
+	workers := mt.getWorkers()
+	r := mt.popPendingRange()
+	if r == nil {
+		if gen := mt.status.RangeListGen(); gen != nil && !gen.IsDone() {
+			_, r = gen.GenRange()
+		}
+	}
+	var splitWorker *Worker
+	if r == nil {
+		for _, worker := range workers {
+			if worker.status.statusCode != StatusCodeDownloading {
+				continue
+			}
+			if splitWorker == nil || worker.GetRange().Len() > splitWorker.GetRange().Len() {
+				splitWorker = worker
+			}
+		}
+		if splitWorker == nil {
+			return
+		}
+		workerRange := splitWorker.GetRange()
+		end := workerRange.LoadEnd()
+		middle := (workerRange.LoadBegin() + end) / 2
+		if end-middle < MinParallelSize/5 { // 剩余的下载量太少, 不增加worker
+			return
+		}
+		r = &transfer.Range{
+			Begin: middle,
+			End:   end,
+		}
+		workerRange.StoreEnd(middle)
+	}
+
+	maxId := 0
+	for _, worker := range workers {
+		if worker.ID() > maxId {
+			maxId = worker.ID()
+		}
+	}
+	worker := mt.autoScaler.newWorker(maxId + 1)
+	worker.SetRange(r)
+	worker.SetDownloadStatus(mt.status)
+	worker.SetParentContext(cancelCtx)
+
+	mt.workersMu.Lock()
+	newWorkers := make(WorkerList, 0, len(mt.workers)+1)
+	newWorkers = append(newWorkers, mt.workers...)
+	newWorkers = append(newWorkers, worker)
+	mt.workers = newWorkers
+	mt.workersMu.Unlock()
+
+	mt.resetController.AddResetNum()
+	logger.Verbosef("MONITOR: auto scale, add worker[%d]: %s, workers: %d\n", worker.ID(), r.ShowDetails(), len(newWorkers))
+	go worker.Execute()
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/tickstep/library-go/logger"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+type (
+	// Namer 获取文件名接口
+	Namer interface {
+		Name() string
+	}
+
+	// ChecksumMismatchError 下载文件的校验值与网盘记录的不一致, 需要重新下载
+	ChecksumMismatchError struct {
+		Algorithm string
+		Expected  string
+		Actual    string
+	}
+)
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("文件%s校验失败, 网盘记录: %s, 本地文件: %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// NewContentHash 根据网盘记录的校验值长度选择哈希算法, 32位为MD5, 40位为SHA1
+func NewContentHash(contentHash string) (h hash.Hash, algorithm string, ok bool) {
+	switch len(contentHash) {
+	case md5.Size * 2:
+		return md5.New(), "MD5", true
+	case sha1.Size * 2:
+		return sha1.New(), "SHA1", true
+	}
+	return nil, "", false
+}
+
+// ChecksumReaderAt 计算 readerAt 中 [0, size) 数据的校验值, 返回大写的十六进制字符串
+func ChecksumReaderAt(readerAt io.ReaderAt, size int64, h hash.Hash) (string, error) {
+	if _, err := io.Copy(h, io.NewSectionReader(readerAt, 0, size)); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// verifyChecksum 下载完成后重新读取本地文件计算校验值, 与网盘记录的 ContentHash 比较.
+// 各个worker通过 io.WriterAt 写入不同的Range, 所以这里在全部写入完成后再按顺序读取整个文件
+func (der *Downloader) verifyChecksum() error {
+	contentHash := der.fileInfo.ContentHash
+	h, algorithm, ok := NewContentHash(contentHash)
+	if !ok {
+		logger.Verbosef("DEBUG: file %s has no supported content hash, skip verify\n", der.fileInfo.FileId)
+		return nil
+	}
+
+	// 下载文件一般以只写方式打开, 优先按文件名重新打开读取
+	var readerAt io.ReaderAt
+	if namer, ok := der.writer.(Namer); ok {
+		file, err := os.Open(namer.Name())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		readerAt = file
+	} else if ra, ok := der.writer.(io.ReaderAt); ok {
+		readerAt = ra
+	} else {
+		logger.Verbosef("DEBUG: writer can not be read back, skip verify\n")
+		return nil
+	}
+
+	actual, err := ChecksumReaderAt(readerAt, der.fileInfo.FileSize, h)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, contentHash) {
+		return &ChecksumMismatchError{
+			Algorithm: algorithm,
+			Expected:  strings.ToUpper(contentHash),
+			Actual:    actual,
+		}
+	}
+	return nil
+}
+
+// removeFile 删除校验失败的本地文件, 无法删除时(例如windows下文件仍被打开)至少清空文件内容
+func (der *Downloader) removeFile() {
+	if remover, ok := der.writer.(interface{ Remove() error }); ok {
+		// 分块输出, 删除所有分块文件
+		if err := remover.Remove(); err != nil {
+			logger.Verbosef("DEBUG: remove file error: %s\n", err)
+		}
+		return
+	}
+	if truncater, ok := der.writer.(interface{ Truncate(int64) error }); ok {
+		if err := truncater.Truncate(0); err != nil {
+			logger.Verbosef("DEBUG: truncate file error: %s\n", err)
+		}
+	}
+	if namer, ok := der.writer.(Namer); ok {
+		if err := os.Remove(namer.Name()); err != nil {
+			logger.Verbosef("DEBUG: remove file error: %s\n", err)
+		}
+	}
+}
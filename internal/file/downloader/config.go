@@ -15,11 +15,20 @@ package downloader
 
 import (
 	"github.com/tickstep/aliyunpan/library/requester/transfer"
+	"time"
 )
 
 const (
 	//CacheSize 默认的下载缓存
 	CacheSize = 8192
+
+	// ETAFormatDuration 剩余时间显示为时长, 例如 3m42s
+	ETAFormatDuration = "duration"
+	// ETAFormatDatetime 剩余时间显示为预计完成的本地时间, 例如 14:27:35
+	ETAFormatDatetime = "datetime"
+
+	// DefaultSpeedReportInterval 默认的下载状态(速度)回调间隔
+	DefaultSpeedReportInterval = 1 * time.Second
 )
 
 var (
@@ -43,13 +52,48 @@ type Config struct {
 	TryHTTP                    bool                       // 是否尝试使用 http 连接
 	ShowProgress               bool                       // 是否展示下载进度条
 	ExcludeNames               []string                   // 排除的文件名，包括文件夹和文件。即这些文件/文件夹不进行下载，支持正则表达式
+	ExcludeGlobs               []string                   // 排除的文件名通配符，例如 *.tmp，匹配的文件不进行下载
+	ConnectionPoolSize         int                        // 单个文件所有worker共享的连接池大小, 0表示每个worker使用独立的连接
+	ETAFormat                  string                     // 剩余时间显示格式, duration 或者 datetime
+	IPBind                     string                     // 出站连接绑定的本地IP地址, 为空则由系统选择
+	ProxyURL                   string                     // 下载请求使用的代理地址, 例如 http://127.0.0.1:8888, 为空则使用全局代理设置
+	MaxRedirects               int                        // 最多跟随的HTTP重定向次数, 0表示使用默认策略
+	MonitorPort                int                        // Prometheus 指标服务端口, 0表示不启动
+	MonitorHost                string                     // Prometheus 指标服务监听地址, 为空则只监听 127.0.0.1
+	SplitSize                  int64                      // 将文件直接下载为不超过该大小的分块文件, 0表示不分割
+	WorkerTimeout              time.Duration              // 单个worker超过该时间没有收到数据则停止, 剩余数据分配给新的worker, 0表示不限制
+	RequestTimeout             time.Duration              // Range请求建立连接或者单次读取的超时时间, 超时后剩余数据分配给新的worker, 0表示不限制
+	RetryPolicy                *RetryPolicy               // worker遇到临时性网络错误时的重试策略, 为nil则不重试, 直接交给monitor处理
+	ConnectionReuseTTL         time.Duration              // 单个TCP连接最多复用的时长, 超过后建立新的连接, 0表示不限制
+	SpeedReportInterval        time.Duration              // 下载状态(速度)回调的间隔, 为0时使用默认值
+	SlowSpeedKillAfter         SlowSpeedConfig            // 平均速度持续低于阈值时取消下载, 为零值则不限制
+	MaxMemoryBytes             int64                      // 下载缓存占用的内存上限, 超过时调低缓存大小或者并发线程数, 0表示不限制
+	VerifyChecksum             bool                       // 下载完成后计算本地文件的SHA1/MD5, 与网盘记录的校验值比较
+	SaveHeadersFile            string                     // 每个分段请求成功后将响应头以JSON格式追加到该文件, 为空则不记录
+	SegmentLogFile             string                     // 每个分段请求结束后将下载数据以CSV格式追加到该文件, 为空则不记录
+	TrackIPChangesFile         string                     // 每个TCP连接的CDN IP地址以JSON格式追加到该文件, 标记同一主机的IP变化, 为空则不记录
+	DisablePreAlloc            bool                       // 不预分配文件空间, 文件随着下载逐渐增大
+	SingleThread               bool                       // 使用单线程下载整个文件
+	FallbackSingleThread       bool                       // 多线程下载失败后使用单线程重新下载整个文件
+	PerWorkerRateLimit         bool                       // 将 MaxRate 平均分配给每个worker单独限速
+	SegmentOverlap             int64                      // 每个分段向前多请求的字节数, 重叠部分丢弃, 0表示不重叠
+	NotifyDoneSound            bool                       // 每个文件下载成功或者失败后播放提示音
+	NotifySound                string                     // 提示音文件路径, 为空则使用系统默认的提示音
+	StartAt                    int64                      // 从指定的字节位置开始下载, 之前的数据不下载, 0表示下载整个文件
+
+	// 根据实时速度动态调整worker数量
+	AutoScale           bool          // 是否开启
+	AutoScaleInterval   time.Duration // 速度采样间隔, 0表示使用默认值10秒
+	AutoScaleSpeedRatio float64       // 速度低于峰值的该比例时增加worker, 0表示使用默认值0.5
+	AutoScaleErrorRate  float64       // 出错的worker占比超过该值时减少worker, 0表示使用默认值0.5
 }
 
 // NewConfig 返回默认配置
 func NewConfig() *Config {
 	return &Config{
-		MaxParallel: 5,
-		CacheSize:   CacheSize,
+		MaxParallel:         5,
+		CacheSize:           CacheSize,
+		SpeedReportInterval: DefaultSpeedReportInterval,
 	}
 }
 
@@ -59,6 +103,9 @@ func (cfg *Config) Fix() {
 	if cfg.MaxParallel < 1 {
 		cfg.MaxParallel = 1
 	}
+	if cfg.SpeedReportInterval <= 0 {
+		cfg.SpeedReportInterval = DefaultSpeedReportInterval
+	}
 }
 
 // Copy 拷贝新的配置
@@ -0,0 +1,74 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrConnectionExpired 连接的复用时间已超过TTL
+	ErrConnectionExpired = errors.New("connection reuse ttl expired")
+)
+
+type (
+	// ttlConn 超过TTL后不再发送新的请求的连接.
+	// 连接过期后写入请求时直接关闭连接并返回错误, 由于没有写入任何数据, http.Transport 会使用新的连接重试该请求,
+	// 已经在读取中的响应不受影响
+	ttlConn struct {
+		net.Conn
+		expireAt time.Time
+	}
+)
+
+func (c *ttlConn) Write(b []byte) (int, error) {
+	if time.Now().After(c.expireAt) {
+		c.Conn.Close()
+		return 0, ErrConnectionExpired
+	}
+	return c.Conn.Write(b)
+}
+
+// NewConnTTLTransport 基于 transport 复制一份新的 Transport, 每个TCP连接最多复用 ttl 时长, 超过后建立新的连接
+func NewConnTTLTransport(transport http.RoundTripper, ttl time.Duration) *http.Transport {
+	t, ok := transport.(*http.Transport)
+	if !ok || t == nil {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	dial := t.DialContext
+	if dial == nil {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		dial = dialer.DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &ttlConn{
+			Conn:     conn,
+			expireAt: time.Now().Add(ttl),
+		}, nil
+	}
+	t.DialTLSContext = nil
+	return t
+}
@@ -16,6 +16,7 @@ package downloader
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/tickstep/aliyunpan-api/aliyunpan"
 	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
 	"github.com/tickstep/aliyunpan/cmder/cmdutil"
@@ -29,8 +30,11 @@ import (
 	"github.com/tickstep/library-go/requester/rio/speeds"
 	"io"
 	"net/http"
+	"net/url"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -67,6 +71,10 @@ type (
 		config                  *Config
 		monitor                 *Monitor
 		instanceState           *InstanceState
+		workerMaxRate           int64        // 平均分配给每个worker的总限速, 0表示不限制单个worker
+		retryPolicy             *RetryPolicy // worker临时性网络错误的重试策略, 为nil则不重试
+		progressChan            chan ProgressEvent
+		tooSlow                 int32 // 下载速度持续过慢被取消, 原子操作
 	}
 
 	// DURLCheckFunc 下载URL检测函数
@@ -95,6 +103,24 @@ func (der *Downloader) SetDriveId(driveId string) {
 	der.driveId = driveId
 }
 
+// SetWorkerRateLimit 将总限速 maxRate 平均分配给每个worker, 每个worker使用独立的令牌桶限速,
+// 使得各个并发连接的带宽更加均匀
+func (der *Downloader) SetWorkerRateLimit(maxRate int64) {
+	der.workerMaxRate = maxRate
+}
+
+// SetRetryPolicy 设置worker遇到临时性网络错误(连接断开, 连接被重置, 超时)时的重试策略,
+// 重试次数用完后才将错误交给monitor处理
+func (der *Downloader) SetRetryPolicy(rp *RetryPolicy) {
+	der.retryPolicy = rp
+}
+
+// SetProgressChannel 订阅下载进度, 每个worker读取响应数据时都会发送 ProgressEvent 到 ch.
+// BytesRead 包含断点续传之前已经下载的数据, 发送不会阻塞下载, ch 已满时丢弃事件
+func (der *Downloader) SetProgressChannel(ch chan ProgressEvent) {
+	der.progressChan = ch
+}
+
 // SetClient 设置http客户端
 func (der *Downloader) SetClient(client *requester.HTTPClient) {
 	der.client = client
@@ -116,7 +142,18 @@ func (der *Downloader) lazyInit() {
 	}
 	if der.client == nil {
 		der.client = requester.NewHTTPClient()
+		// 先初始化 requester 的 Transport, 否则发起请求时会覆盖下面设置的 Transport
+		der.client.SetKeepAlive(true)
 		der.client.SetTimeout(20 * time.Minute)
+		if proxyURL := der.proxyURL(); proxyURL != nil {
+			der.client.Transport = NewProxyTransport(der.client.Transport, proxyURL)
+		}
+		if der.config.IPBind != "" {
+			der.client.Transport = NewBindIPTransport(der.client.Transport, der.config.IPBind)
+		}
+		if der.config.MaxRedirects > 0 {
+			der.client.CheckRedirect = NewCheckRedirectFunc(der.config.MaxRedirects)
+		}
 	}
 	if der.monitor == nil {
 		der.monitor = NewMonitor()
@@ -159,19 +196,21 @@ func (der *Downloader) SelectBlockSizeAndInitRangeGen(single bool, status *trans
 	}
 	gen := status.RangeListGen()
 	if gen == nil {
+		// 指定了开始位置时, 之前的数据不分配给worker
+		begin := der.config.StartAt
 		switch der.config.Mode {
 		case transfer.RangeGenMode_Default:
-			gen = transfer.NewRangeListGenDefault(status.TotalSize(), 0, 0, parallel)
+			gen = transfer.NewRangeListGenDefault(status.TotalSize(), begin, 0, parallel)
 			blockSize = gen.LoadBlockSize()
 		case transfer.RangeGenMode_BlockSize:
-			b2 := status.TotalSize()/int64(parallel) + 1
+			b2 := (status.TotalSize()-begin)/int64(parallel) + 1
 			if b2 > der.config.BlockSize { // 选小的BlockSize, 以更高并发
 				blockSize = der.config.BlockSize
 			} else {
 				blockSize = b2
 			}
 
-			gen = transfer.NewRangeListGenBlockSize(status.TotalSize(), 0, blockSize)
+			gen = transfer.NewRangeListGenBlockSize(status.TotalSize(), begin, blockSize)
 		default:
 			initErr = transfer.ErrUnknownRangeGenMode
 			return
@@ -309,11 +348,20 @@ func (der *Downloader) Execute() error {
 	var (
 		isInstance = bii != nil // 是否存在断点信息
 		status     *transfer.DownloadStatus
-		single     = false // 默认开启多线程下载，所以当前single值都为false代表不是单线程下载
+		single     = der.config.SingleThread // 默认开启多线程下载，只有指定单线程模式时才使用单线程下载
 	)
+	if single && isInstance && len(bii.Ranges) > 1 {
+		// 多线程下载的断点信息不适用于单线程下载, 重新下载整个文件
+		logger.Verbosef("DEBUG: single thread mode, discard multi-thread instance state\n")
+		isInstance = false
+	}
 	if !isInstance {
 		bii = &transfer.DownloadInstanceInfo{}
 	}
+	if der.config.StartAt >= der.fileInfo.FileSize {
+		cmdutil.Trigger(der.onFailedEvent)
+		return ErrStartAtOutOfRange
+	}
 
 	if bii.DownloadStatus != nil {
 		// 使用断点信息的状态
@@ -322,6 +370,8 @@ func (der *Downloader) Execute() error {
 		// 新建状态
 		status = transfer.NewDownloadStatus()
 		status.SetTotalSize(der.fileInfo.FileSize)
+		// 跳过的数据计入已下载, 进度按照整个文件显示
+		status.AddDownloaded(der.config.StartAt)
 	}
 
 	// 设置限速
@@ -333,24 +383,47 @@ func (der *Downloader) Execute() error {
 
 	// 计算文件下载的并发线程数，计单个文件下载的并发数
 	parallel := der.SelectParallel(single, MaxParallelWorkerCount, status.TotalSize(), bii.Ranges) // 实际的下载并行量
-	blockSize, err := der.SelectBlockSizeAndInitRangeGen(single, status, parallel)                 // 实际的BlockSize
+
+	// 限制下载缓存占用的内存, 创建worker之前根据当前已使用的内存调低缓存大小或者并发线程数
+	confCacheSize := der.config.CacheSize
+	if der.config.MaxMemoryBytes > 0 {
+		minParallel := 1
+		if len(bii.Ranges) > 0 {
+			// 断点续传的range不能丢弃, 只能调低缓存大小
+			minParallel = len(bii.Ranges)
+		}
+		var memStats runtime.MemStats
+		runtime.ReadMemStats(&memStats)
+		newCacheSize, newParallel := FitMemoryLimit(der.config.MaxMemoryBytes, int64(memStats.Alloc), confCacheSize, parallel, minParallel)
+		if newCacheSize != confCacheSize || newParallel != parallel {
+			logger.Verbosef("DEBUG: memory limit %d, in use %d, cache size %d -> %d, parallel %d -> %d\n",
+				der.config.MaxMemoryBytes, memStats.Alloc, confCacheSize, newCacheSize, parallel, newParallel)
+		}
+		confCacheSize, parallel = newCacheSize, newParallel
+	}
+
+	blockSize, err := der.SelectBlockSizeAndInitRangeGen(single, status, parallel) // 实际的BlockSize
 	if err != nil {
 		return err
 	}
 
-	cacheSize := der.SelectCacheSize(der.config.CacheSize, blockSize) // 实际下载缓存
-	cachepool.SetSyncPoolSize(cacheSize)                              // 调整pool大小
+	cacheSize := der.SelectCacheSize(confCacheSize, blockSize) // 实际下载缓存
+	cachepool.SetSyncPoolSize(cacheSize)                       // 调整pool大小
 
 	logger.Verbosef("DEBUG: download task CREATED: parallel: %d, cache size: %d\n", parallel, cacheSize)
 
 	der.monitor.InitMonitorCapacity(parallel)
 
 	var writer Writer
-	// 尝试修剪文件
-	if fder, ok := der.writer.(Fder); ok {
+	// 尝试修剪文件, 网络文件系统(SMB, NFS)上预分配可能失败, 此时改为写入稀疏文件
+	if fder, ok := der.writer.(Fder); ok && !der.config.DisablePreAlloc {
 		err = prealloc.PreAlloc(fder.Fd(), status.TotalSize())
 		if err != nil {
-			logger.Verbosef("DEBUG: truncate file error: %s\n", err)
+			fmt.Printf("警告: 预分配文件空间失败, 改为写入稀疏文件: %s\n", err)
+			err = AllocSparseFile(der.writer, status.TotalSize())
+			if err != nil {
+				fmt.Printf("警告: 写入稀疏文件失败: %s\n", err)
+			}
 		}
 	}
 	writer = der.writer
@@ -362,7 +435,7 @@ func (der *Downloader) Execute() error {
 		// 分配线程
 		bii.Ranges = make(transfer.RangeList, 0, parallel)
 		if single { // 单线程
-			bii.Ranges = append(bii.Ranges, &transfer.Range{Begin: 0, End: der.fileInfo.FileSize})
+			bii.Ranges = append(bii.Ranges, &transfer.Range{Begin: der.config.StartAt, End: der.fileInfo.FileSize})
 		} else {
 			gen := status.RangeListGen()
 			for i := 0; i < cap(bii.Ranges); i++ {
@@ -399,17 +472,92 @@ func (der *Downloader) Execute() error {
 		return ErrFileDownloadForbidden
 	}
 
-	// 初始化下载worker
-	for k, r := range bii.Ranges {
-		loadBalancer := loadBalancerResponseList.SequentialGet()
-		if loadBalancer == nil {
-			continue
+	// 下载请求使用的代理
+	proxyURL := der.proxyURL()
+
+	// 记录每个TCP连接的CDN IP地址
+	var ipTracker *IPChangeTracker
+	if der.config.TrackIPChangesFile != "" {
+		ipTracker, err = NewIPChangeTracker(der.config.TrackIPChangesFile, der.fileInfo.FileId, status.Downloaded)
+		if err != nil {
+			logger.Verbosef("ERROR: open track ip changes file error: %s\n", err)
+			return err
+		}
+		defer ipTracker.Close()
+	}
+
+	// 所有worker共享同一个连接池, 减少同一CDN主机的TCP握手开销
+	var sharedTransport *http.Transport
+	if der.config.ConnectionPoolSize > 0 {
+		sharedTransport = NewSharedTransport(der.config.ConnectionPoolSize)
+		if proxyURL != nil {
+			sharedTransport = NewProxyTransport(sharedTransport, proxyURL)
+		}
+		if der.config.IPBind != "" {
+			sharedTransport = NewBindIPTransport(sharedTransport, der.config.IPBind)
+		}
+		if der.config.ConnectionReuseTTL > 0 {
+			sharedTransport = NewConnTTLTransport(sharedTransport, der.config.ConnectionReuseTTL)
 		}
+		if ipTracker != nil {
+			sharedTransport = NewIPTrackTransport(sharedTransport, ipTracker)
+		}
+	}
+
+	// 记录每个分段的响应头
+	var headerRecorder *HeaderRecorder
+	if der.config.SaveHeadersFile != "" {
+		headerRecorder, err = NewHeaderRecorder(der.config.SaveHeadersFile)
+		if err != nil {
+			logger.Verbosef("ERROR: open save headers file error: %s\n", err)
+			return err
+		}
+		defer headerRecorder.Close()
+	}
+
+	// 记录每个分段的下载数据
+	var segmentLogger *SegmentLogger
+	if der.config.SegmentLogFile != "" {
+		segmentLogger, err = NewSegmentLogger(der.config.SegmentLogFile)
+		if err != nil {
+			logger.Verbosef("ERROR: open segment log file error: %s\n", err)
+			return err
+		}
+		defer segmentLogger.Close()
+	}
 
+	// 下载进度, 所有worker共享同一个计数器
+	var progressReader *ProgressReader
+	if der.progressChan != nil {
+		progressReader = NewProgressReader(nil, status.TotalSize(), der.progressChan)
+		atomic.StoreInt64(progressReader.bytesRead, status.Downloaded())
+	}
+
+	// 创建worker, 动态增加worker时也使用相同的配置
+	newWorker := func(k int) *Worker {
 		logger.Verbosef("work id: %d, download url: %v\n", k, durl)
 		client := requester.NewHTTPClient()
 		client.SetKeepAlive(true)
 		client.SetTimeout(10 * time.Minute)
+		if sharedTransport != nil {
+			client.Transport = sharedTransport
+		} else {
+			if proxyURL != nil {
+				client.Transport = NewProxyTransport(client.Transport, proxyURL)
+			}
+			if der.config.IPBind != "" {
+				client.Transport = NewBindIPTransport(client.Transport, der.config.IPBind)
+			}
+			if der.config.ConnectionReuseTTL > 0 {
+				client.Transport = NewConnTTLTransport(client.Transport, der.config.ConnectionReuseTTL)
+			}
+			if ipTracker != nil {
+				client.Transport = NewIPTrackTransport(client.Transport, ipTracker)
+			}
+		}
+		if der.config.MaxRedirects > 0 {
+			client.CheckRedirect = NewCheckRedirectFunc(der.config.MaxRedirects)
+		}
 
 		realUrl := durl.Url
 		worker := NewWorker(k, der.driveId, der.fileInfo.FileId, realUrl, writer, der.globalSpeedsStat)
@@ -418,12 +566,55 @@ func (der *Downloader) Execute() error {
 		worker.SetWriteMutex(writeMu)
 		worker.SetTotalSize(der.fileInfo.FileSize)
 
+		worker.SetTimeout(der.config.WorkerTimeout)
+		worker.SetRequestTimeout(der.config.RequestTimeout)
+		worker.SetHeaderRecorder(headerRecorder)
+		worker.SetSegmentLogger(segmentLogger)
+		worker.SetSegmentOverlap(der.config.SegmentOverlap)
+		worker.SetRetryPolicy(der.retryPolicy)
+		worker.SetProgressReader(progressReader)
+		if der.workerMaxRate > 0 {
+			workerRate := der.workerMaxRate / int64(parallel)
+			worker.SetRateLimit(NewTokenBucket(workerRate, workerRate/10))
+		}
+
 		worker.SetAcceptRange("bytes")
+		return worker
+	}
+
+	// 初始化下载worker
+	for k, r := range bii.Ranges {
+		loadBalancer := loadBalancerResponseList.SequentialGet()
+		if loadBalancer == nil {
+			continue
+		}
+
+		worker := newWorker(k)
 		worker.SetRange(r) // 分配Range
 		der.monitor.Append(worker)
 	}
 
 	der.monitor.SetStatus(status)
+	der.monitor.SetLoadBalancer(loadBalancerResponseList)
+	if der.config.AutoScale && !single {
+		der.monitor.SetAutoScale(AutoScaleConfig{
+			Interval:   der.config.AutoScaleInterval,
+			SpeedRatio: der.config.AutoScaleSpeedRatio,
+			ErrorRate:  der.config.AutoScaleErrorRate,
+			MaxWorkers: MaxParallelWorkerCount,
+		}, newWorker)
+	}
+
+	// 启动 Prometheus 指标服务
+	if der.config.MonitorPort > 0 {
+		addr := MetricsAddr(der.config.MonitorHost, der.config.MonitorPort)
+		metricsServer, er := acquireMetricsServer(addr, der)
+		if er != nil {
+			logger.Verbosef("start metrics server on %s error: %s\n", addr, er)
+		} else {
+			defer releaseMetricsServer(metricsServer, der)
+		}
+	}
 
 	// 阿里云盘支持断点续传，开启重载worker
 	der.monitor.SetReloadWorker(true)
@@ -441,6 +632,19 @@ func (der *Downloader) Execute() error {
 
 	// 检查错误
 	err = der.monitor.Err()
+	if err == nil && atomic.LoadInt32(&der.tooSlow) == 1 {
+		err = ErrDownloadTooSlow
+	}
+	if err == nil && der.config.VerifyChecksum {
+		// 校验下载文件的完整性
+		err = der.verifyChecksum()
+		if _, ok := err.(*ChecksumMismatchError); ok {
+			logger.Verbosef("DEBUG: %s\n", err)
+			der.removeInstanceState() // 移除断点续传文件, 重新下载
+			der.removeFile()
+			cmdutil.Trigger(der.onFailedEvent)
+		}
+	}
 	if err == nil { // 成功
 		cmdutil.Trigger(der.onSuccessEvent)
 		der.removeInstanceState() // 移除断点续传文件
@@ -456,15 +660,37 @@ func (der *Downloader) Execute() error {
 	return err
 }
 
+// proxyURL 解析配置的代理地址, 没有配置或者地址无效时返回nil, 使用全局代理设置
+func (der *Downloader) proxyURL() *url.URL {
+	if der.config.ProxyURL == "" {
+		return nil
+	}
+	u, err := ParseProxyURL(der.config.ProxyURL)
+	if err != nil {
+		logger.Verbosef("ERROR: parse proxy url error: %s\n", err)
+		return nil
+	}
+	return u
+}
+
 // downloadStatusEvent 执行状态处理事件
 func (der *Downloader) downloadStatusEvent() {
-	if der.onDownloadStatusEvent == nil {
+	var detector *slowSpeedDetector
+	if der.config.SlowSpeedKillAfter.Enabled() {
+		detector = newSlowSpeedDetector(der.config.SlowSpeedKillAfter)
+	}
+	if der.onDownloadStatusEvent == nil && detector == nil {
 		return
 	}
 
+	interval := der.config.SpeedReportInterval
+	if interval <= 0 {
+		// 没有经过 Fix 的配置, 避免 time.NewTicker panic
+		interval = DefaultSpeedReportInterval
+	}
 	status := der.monitor.Status()
 	go func() {
-		ticker := time.NewTicker(1 * time.Second)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			select {
@@ -472,7 +698,15 @@ func (der *Downloader) downloadStatusEvent() {
 				return
 			case <-ticker.C:
 				time.Sleep(500 * time.Millisecond)
-				der.onDownloadStatusEvent(status, der.monitor.RangeWorker)
+				if der.onDownloadStatusEvent != nil {
+					der.onDownloadStatusEvent(status, der.monitor.RangeWorker)
+				}
+				if detector != nil && detector.observe(time.Now(), status.Downloaded()) {
+					logger.Verbosef("DOWNLOAD: speed below %d B/s for %s, cancel\n", der.config.SlowSpeedKillAfter.Rate, der.config.SlowSpeedKillAfter.Duration)
+					atomic.StoreInt32(&der.tooSlow, 1)
+					der.Cancel()
+					return
+				}
 			}
 		}
 	}()
@@ -0,0 +1,97 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan/library/requester/transfer"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type (
+	// HeaderRecorder 记录每个分段下载请求的HTTP响应头, 用于分析CDN节点的情况
+	HeaderRecorder struct {
+		mu   sync.Mutex
+		file *os.File
+	}
+
+	// HeaderRecord 一条响应头记录, 以JSON格式按行追加到文件
+	HeaderRecord struct {
+		Time       string              `json:"time"`
+		FileId     string              `json:"fileId"`
+		WorkerId   int                 `json:"workerId"`
+		Url        string              `json:"url"`
+		Range      string              `json:"range"`
+		StatusCode int                 `json:"statusCode"`
+		Headers    map[string][]string `json:"headers"`
+	}
+)
+
+// NewHeaderRecorder 以追加方式打开记录文件, 文件不存在则创建
+func NewHeaderRecorder(filePath string) (*HeaderRecorder, error) {
+	// 记录中包含带签名的下载链接, 只允许当前用户读写, 已存在的文件也修改权限
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err = file.Chmod(0600); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &HeaderRecorder{
+		file: file,
+	}, nil
+}
+
+// Record 追加一条响应头记录
+func (hr *HeaderRecorder) Record(fileId string, workerId int, url string, r *transfer.Range, resp *http.Response) error {
+	if hr == nil || resp == nil {
+		return nil
+	}
+	record := &HeaderRecord{
+		Time:       time.Now().Format("2006-01-02 15:04:05"),
+		FileId:     fileId,
+		WorkerId:   workerId,
+		Url:        url,
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header,
+	}
+	if r != nil {
+		record.Range = fmt.Sprintf("bytes=%d-%d", r.LoadBegin(), r.LoadEnd()-1)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	_, err = hr.file.Write(data)
+	return err
+}
+
+// Close 关闭记录文件
+func (hr *HeaderRecorder) Close() error {
+	if hr == nil {
+		return nil
+	}
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	return hr.file.Close()
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import "errors"
+
+const (
+	// IOPriorityBackground 后台IO优先级, 只在磁盘空闲时写入
+	IOPriorityBackground = "background"
+	// IOPriorityNormal 普通IO优先级
+	IOPriorityNormal = "normal"
+	// IOPriorityHigh 较高IO优先级
+	IOPriorityHigh = "high"
+)
+
+var (
+	// ErrIOPriorityPermission 没有设置实时IO优先级的权限
+	ErrIOPriorityPermission = errors.New("high 使用实时IO调度, 需要root权限")
+	// ErrIOPriorityUnknown 未知的IO优先级
+	ErrIOPriorityUnknown = errors.New("未知的IO优先级, 可选值: background, normal, high")
+)
@@ -0,0 +1,69 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package downloader
+
+import (
+	"io/ioutil"
+	"strconv"
+	"syscall"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassRT    = 1
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+// SetIOPriority 通过 ioprio_set 设置当前进程所有线程的IO调度优先级
+func SetIOPriority(level string) error {
+	var prio int
+	switch level {
+	case IOPriorityBackground:
+		prio = ioprioClassIdle << ioprioClassShift
+	case IOPriorityNormal:
+		prio = ioprioClassBE<<ioprioClassShift | 4
+	case IOPriorityHigh:
+		// 实时调度类需要 CAP_SYS_ADMIN 权限
+		prio = ioprioClassRT<<ioprioClassShift | 4
+	default:
+		return ErrIOPriorityUnknown
+	}
+
+	// ioprio_set 作用于单个线程, 需要对进程的所有线程进行设置, 新建的线程会继承优先级
+	tids := []int{0}
+	if tasks, err := ioutil.ReadDir("/proc/self/task"); err == nil {
+		tids = tids[:0]
+		for _, t := range tasks {
+			if tid, e := strconv.Atoi(t.Name()); e == nil {
+				tids = append(tids, tid)
+			}
+		}
+	}
+	for _, tid := range tids {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio))
+		if errno == syscall.EPERM && level == IOPriorityHigh {
+			return ErrIOPriorityPermission
+		}
+		if errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/tickstep/library-go/logger"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type (
+	// IPChangeTracker 记录下载过程中每个TCP连接的CDN IP地址, 同一个主机的IP发生变化时标记出来, 用于排查CDN切换的问题
+	IPChangeTracker struct {
+		mu         sync.Mutex
+		file       *os.File
+		fileId     string
+		lastIP     map[string]string // 每个主机最近一次连接的IP
+		offsetFunc func() int64      // 当前已下载的字节数
+	}
+
+	// IPChangeRecord 一条连接记录, 以JSON格式按行追加到文件
+	IPChangeRecord struct {
+		Time    string `json:"time"`
+		FileId  string `json:"fileId"`
+		Host    string `json:"host"`
+		Ip      string `json:"ip"`
+		PrevIp  string `json:"prevIp,omitempty"`
+		Changed bool   `json:"changed"`
+		Offset  int64  `json:"offset"`
+	}
+)
+
+// NewIPChangeTracker 以追加方式打开记录文件, 文件不存在则创建. offsetFunc 用于获取记录时已下载的字节数
+func NewIPChangeTracker(filePath, fileId string, offsetFunc func() int64) (*IPChangeTracker, error) {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &IPChangeTracker{
+		file:       file,
+		fileId:     fileId,
+		lastIP:     map[string]string{},
+		offsetFunc: offsetFunc,
+	}, nil
+}
+
+// Record 记录一个新建立的TCP连接, 返回该主机的IP是否发生了变化
+func (it *IPChangeTracker) Record(host, ip string) (changed bool, err error) {
+	if it == nil {
+		return false, nil
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	record := &IPChangeRecord{
+		Time:   time.Now().Format("2006-01-02 15:04:05.000"),
+		FileId: it.fileId,
+		Host:   host,
+		Ip:     ip,
+	}
+	if it.offsetFunc != nil {
+		record.Offset = it.offsetFunc()
+	}
+	if prev, ok := it.lastIP[host]; ok && prev != ip {
+		record.PrevIp = prev
+		record.Changed = true
+	}
+	it.lastIP[host] = ip
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return record.Changed, err
+	}
+	data = append(data, '\n')
+	_, err = it.file.Write(data)
+	return record.Changed, err
+}
+
+// Close 关闭记录文件
+func (it *IPChangeTracker) Close() error {
+	if it == nil {
+		return nil
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.file.Close()
+}
+
+// NewIPTrackTransport 基于 transport 复制一份新的 Transport, 每建立一个TCP连接都将远端IP记录到 tracker
+func NewIPTrackTransport(transport http.RoundTripper, tracker *IPChangeTracker) *http.Transport {
+	t, ok := transport.(*http.Transport)
+	if !ok || t == nil {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	dial := t.DialContext
+	if dial == nil {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		dial = dialer.DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		host, _, e := net.SplitHostPort(address)
+		if e != nil {
+			host = address
+		}
+		ip := conn.RemoteAddr().String()
+		if h, _, e := net.SplitHostPort(ip); e == nil {
+			ip = h
+		}
+		if changed, e := tracker.Record(host, ip); e != nil {
+			logger.Verbosef("ERROR: record ip change error: %s\n", e)
+		} else if changed {
+			logger.Verbosef("DEBUG: cdn ip changed, host: %s, ip: %s\n", host, ip)
+		}
+		return conn, nil
+	}
+	t.DialTLSContext = nil
+	return t
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"github.com/tickstep/library-go/logger"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// MetricsServer Prometheus 指标服务, 同一个端口的所有下载共享一个服务
+	MetricsServer struct {
+		addr          string
+		server        *http.Server
+		refCount      int
+		downloaders   map[*Downloader]struct{}
+		finishedBytes int64 // 已经结束的下载累计的下载量
+		reportedBytes int64 // 上一次输出的累计下载量, 保证 counter 类型的指标不会减少
+		mu            sync.Mutex
+	}
+)
+
+const (
+	// DefaultMetricsHost 指标服务默认的监听地址, 只允许本机访问
+	DefaultMetricsHost = "127.0.0.1"
+)
+
+var (
+	metricsServers   = map[string]*MetricsServer{}
+	metricsServersMu sync.Mutex
+)
+
+// MetricsAddr 指标服务的监听地址, host 为空则使用 DefaultMetricsHost
+func MetricsAddr(host string, port int) string {
+	if host == "" {
+		host = DefaultMetricsHost
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// acquireMetricsServer 获取指定地址的指标服务, 服务不存在则启动, 并登记下载器
+func acquireMetricsServer(addr string, der *Downloader) (*MetricsServer, error) {
+	metricsServersMu.Lock()
+	defer metricsServersMu.Unlock()
+
+	ms, ok := metricsServers[addr]
+	if !ok {
+		ms = &MetricsServer{
+			addr:        addr,
+			downloaders: map[*Downloader]struct{}{},
+		}
+		if err := ms.start(); err != nil {
+			return nil, err
+		}
+		metricsServers[addr] = ms
+	}
+	ms.mu.Lock()
+	ms.refCount++
+	if der != nil {
+		ms.downloaders[der] = struct{}{}
+	}
+	ms.mu.Unlock()
+	return ms, nil
+}
+
+// StartMetricsServer 启动指标服务并保持运行, 直到调用 Close. 用于在多个文件的下载过程中保持同一个服务
+func StartMetricsServer(addr string) (*MetricsServer, error) {
+	return acquireMetricsServer(addr, nil)
+}
+
+// Close 释放 StartMetricsServer 获取的指标服务
+func (ms *MetricsServer) Close() {
+	releaseMetricsServer(ms, nil)
+}
+
+// releaseMetricsServer 注销下载器, 没有下载器使用时关闭指标服务
+func releaseMetricsServer(ms *MetricsServer, der *Downloader) {
+	metricsServersMu.Lock()
+	defer metricsServersMu.Unlock()
+
+	ms.mu.Lock()
+	if _, ok := ms.downloaders[der]; der != nil && ok {
+		if status := der.monitor.Status(); status != nil {
+			ms.finishedBytes += status.Downloaded()
+		}
+		delete(ms.downloaders, der)
+	}
+	ms.refCount--
+	refCount := ms.refCount
+	ms.mu.Unlock()
+
+	if refCount <= 0 {
+		delete(metricsServers, ms.addr)
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		ms.server.Shutdown(ctx)
+	}
+}
+
+func (ms *MetricsServer) start() error {
+	listener, err := net.Listen("tcp", ms.addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", ms.handleMetrics)
+	ms.server = &http.Server{Handler: mux}
+	go func() {
+		if err := ms.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Verbosef("metrics server error: %s\n", err)
+		}
+	}()
+	return nil
+}
+
+// handleMetrics 输出 Prometheus 文本格式的指标
+func (ms *MetricsServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var (
+		bytesTotal    int64
+		speed         int64
+		workersActive int
+		eta           time.Duration
+	)
+
+	ms.mu.Lock()
+	bytesTotal = ms.finishedBytes
+	for der := range ms.downloaders {
+		status := der.monitor.Status()
+		if status == nil {
+			continue
+		}
+		bytesTotal += status.Downloaded()
+		speed += status.SpeedsPerSecond()
+		if left := status.TimeLeft(); left > eta {
+			eta = left
+		}
+		der.monitor.RangeWorker(func(key int, worker *Worker) bool {
+			if worker.GetStatus().StatusCode() == StatusCodeDownloading {
+				workersActive++
+			}
+			return true
+		})
+	}
+	// worker 重新下载失败的分段时已下载量可能回退, counter 只输出最大值
+	if bytesTotal < ms.reportedBytes {
+		bytesTotal = ms.reportedBytes
+	}
+	ms.reportedBytes = bytesTotal
+	ms.mu.Unlock()
+
+	builder := &strings.Builder{}
+	writeCounter(builder, "aliyunpan_download_bytes_total", "Total bytes downloaded.", strconv.FormatInt(bytesTotal, 10))
+	writeGauge(builder, "aliyunpan_download_speed_bps", "Current download speed in bytes per second.", strconv.FormatInt(speed, 10))
+	writeGauge(builder, "aliyunpan_download_workers_active", "Number of download workers currently downloading.", strconv.Itoa(workersActive))
+	writeGauge(builder, "aliyunpan_download_eta_seconds", "Estimated seconds left for the slowest download.", strconv.FormatFloat(eta.Seconds(), 'f', 0, 64))
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(builder.String()))
+}
+
+func writeGauge(builder *strings.Builder, name, help, value string) {
+	writeMetric(builder, name, "gauge", help, value)
+}
+
+func writeCounter(builder *strings.Builder, name, help, value string) {
+	writeMetric(builder, name, "counter", help, value)
+}
+
+func writeMetric(builder *strings.Builder, name, metricType, help, value string) {
+	fmt.Fprintf(builder, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, metricType, name, value)
+}
@@ -16,27 +16,49 @@ package downloader
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/tickstep/aliyunpan/library/requester/transfer"
 	"github.com/tickstep/library-go/logger"
+	"os"
+	"os/signal"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 var (
 	//ErrNoWokers no workers
 	ErrNoWokers = errors.New("no workers")
+	// ErrDownloadInterrupted 收到中断信号, 下载已暂停
+	ErrDownloadInterrupted = errors.New("下载已暂停, 再次执行相同的下载命令可以继续下载")
+
+	// interrupted 是否收到过中断信号, 收到后不再开始新的下载
+	interrupted int32
 )
 
+// ResetInterrupted 清除收到中断信号的标记, 在开始新一轮下载前调用
+func ResetInterrupted() {
+	atomic.StoreInt32(&interrupted, 0)
+}
+
 type (
 	//Monitor 线程监控器
 	Monitor struct {
 		workers         WorkerList
+		workersMu       sync.RWMutex       // worker数量会被动态调整, 修改workers和pendingRanges时加锁
+		pendingRanges   transfer.RangeList // 缩容时被取消的worker剩余的range, 等待空闲的worker下载
 		status          *transfer.DownloadStatus
 		instanceState   *InstanceState
 		completed       chan struct{}
 		err             error
+		errMu           sync.Mutex // err 会在 registerAllCompleted 的goroutine中设置
 		resetController *ResetController
-		isReloadWorker  bool //是否重载worker
+		isReloadWorker  bool                      //是否重载worker
+		loadBalancer    *LoadBalancerResponseList // 超时worker重新分配时使用的负载均衡列表
+		autoScaler      *autoScaler               // 动态调整worker数量, 为nil则不调整
 
 		// 临时变量
 		lastAvaliableIndex int
@@ -66,6 +88,8 @@ func (mt *Monitor) lazyInit() {
 
 // InitMonitorCapacity 初始化workers, 用于Append
 func (mt *Monitor) InitMonitorCapacity(capacity int) {
+	mt.workersMu.Lock()
+	defer mt.workersMu.Unlock()
 	mt.workers = make(WorkerList, 0, capacity)
 }
 
@@ -74,19 +98,55 @@ func (mt *Monitor) Append(worker *Worker) {
 	if worker == nil {
 		return
 	}
+	mt.workersMu.Lock()
+	defer mt.workersMu.Unlock()
 	mt.workers = append(mt.workers, worker)
 }
 
 // SetWorkers 设置workers, 此操作会覆盖原有的workers
 func (mt *Monitor) SetWorkers(workers WorkerList) {
+	mt.workersMu.Lock()
+	defer mt.workersMu.Unlock()
 	mt.workers = workers
 }
 
+// getWorkers 获取当前的worker列表.
+// 动态调整时会整体替换列表, 返回的列表可以在锁外遍历
+func (mt *Monitor) getWorkers() WorkerList {
+	mt.workersMu.RLock()
+	defer mt.workersMu.RUnlock()
+	return mt.workers
+}
+
+// numPendingRanges 等待分配的range数量
+func (mt *Monitor) numPendingRanges() int {
+	mt.workersMu.RLock()
+	defer mt.workersMu.RUnlock()
+	return len(mt.pendingRanges)
+}
+
+// popPendingRange 取出一个等待分配的range, 没有则返回nil
+func (mt *Monitor) popPendingRange() *transfer.Range {
+	mt.workersMu.Lock()
+	defer mt.workersMu.Unlock()
+	if len(mt.pendingRanges) == 0 {
+		return nil
+	}
+	r := mt.pendingRanges[0]
+	mt.pendingRanges = mt.pendingRanges[1:]
+	return r
+}
+
 // SetStatus 设置DownloadStatus
 func (mt *Monitor) SetStatus(status *transfer.DownloadStatus) {
 	mt.status = status
 }
 
+// SetLoadBalancer 设置负载均衡列表
+func (mt *Monitor) SetLoadBalancer(loadBalancer *LoadBalancerResponseList) {
+	mt.loadBalancer = loadBalancer
+}
+
 // SetInstanceState 设置状态
 func (mt *Monitor) SetInstanceState(instanceState *InstanceState) {
 	mt.instanceState = instanceState
@@ -99,9 +159,18 @@ func (mt *Monitor) Status() *transfer.DownloadStatus {
 
 // Err 返回遇到的错误
 func (mt *Monitor) Err() error {
+	mt.errMu.Lock()
+	defer mt.errMu.Unlock()
 	return mt.err
 }
 
+// setErr 设置遇到的错误
+func (mt *Monitor) setErr(err error) {
+	mt.errMu.Lock()
+	defer mt.errMu.Unlock()
+	mt.err = err
+}
+
 // CompletedChan 获取completed chan
 func (mt *Monitor) CompletedChan() <-chan struct{} {
 	return mt.completed
@@ -109,10 +178,11 @@ func (mt *Monitor) CompletedChan() <-chan struct{} {
 
 // GetAvailableWorker 获取空闲的worker
 func (mt *Monitor) GetAvailableWorker() *Worker {
-	workerCount := len(mt.workers)
+	workers := mt.getWorkers()
+	workerCount := len(workers)
 	for i := mt.lastAvaliableIndex; i < mt.lastAvaliableIndex+workerCount; i++ {
 		index := i % workerCount
-		worker := mt.workers[index]
+		worker := workers[index]
 		if worker.Completed() {
 			mt.lastAvaliableIndex = index
 			return worker
@@ -123,16 +193,20 @@ func (mt *Monitor) GetAvailableWorker() *Worker {
 
 // GetAllWorkersRange 获取所有worker的范围
 func (mt *Monitor) GetAllWorkersRange() transfer.RangeList {
-	allWorkerRanges := make(transfer.RangeList, 0, len(mt.workers))
+	mt.workersMu.RLock()
+	defer mt.workersMu.RUnlock()
+	allWorkerRanges := make(transfer.RangeList, 0, len(mt.workers)+len(mt.pendingRanges))
 	for _, worker := range mt.workers {
 		allWorkerRanges = append(allWorkerRanges, worker.GetRange())
 	}
+	// 等待分配的range也需要保存到断点信息
+	allWorkerRanges = append(allWorkerRanges, mt.pendingRanges...)
 	return allWorkerRanges
 }
 
 // NumLeftWorkers 剩余的worker数量
 func (mt *Monitor) NumLeftWorkers() (num int) {
-	for _, worker := range mt.workers {
+	for _, worker := range mt.getWorkers() {
 		if !worker.Completed() {
 			num++
 		}
@@ -148,7 +222,7 @@ func (mt *Monitor) SetReloadWorker(b bool) {
 // IsLeftWorkersAllFailed 剩下的线程是否全部失败
 func (mt *Monitor) IsLeftWorkersAllFailed() bool {
 	failedNum := 0
-	for _, worker := range mt.workers {
+	for _, worker := range mt.getWorkers() {
 		if worker.Completed() {
 			continue
 		}
@@ -165,7 +239,7 @@ func (mt *Monitor) IsLeftWorkersAllFailed() bool {
 func (mt *Monitor) registerAllCompleted() {
 	mt.completed = make(chan struct{}, 0)
 	var (
-		workerNum   = len(mt.workers)
+		workerNum   int
 		completeNum = 0
 	)
 
@@ -173,13 +247,16 @@ func (mt *Monitor) registerAllCompleted() {
 		for {
 			time.Sleep(1 * time.Second)
 
+			// worker数量可能被动态调整, 每次重新获取
+			workers := mt.getWorkers()
+			workerNum = len(workers)
 			completeNum = 0
-			for _, worker := range mt.workers {
+			for _, worker := range workers {
 				switch worker.GetStatus().StatusCode() {
 				case StatusCodeInternalError:
 					// 检测到内部错误
 					// 马上停止执行
-					mt.err = worker.Err()
+					mt.setErr(worker.Err())
 					close(mt.completed)
 					return
 				case StatusCodeSuccessed, StatusCodeCanceled:
@@ -187,9 +264,9 @@ func (mt *Monitor) registerAllCompleted() {
 				}
 			}
 			// status 在 lazyInit 之后, 不可能为空
-			// 完成条件: 所有worker 都已经完成, 且 rangeGen 已生成完毕
+			// 完成条件: 所有worker 都已经完成, 缩容留下的range已经分配, 且 rangeGen 已生成完毕
 			gen := mt.status.RangeListGen()
-			if completeNum >= workerNum && (gen == nil || gen.IsDone()) { // 已完成
+			if completeNum >= workerNum && mt.numPendingRanges() == 0 && (gen == nil || gen.IsDone()) { // 已完成
 				close(mt.completed)
 				return
 			}
@@ -199,48 +276,58 @@ func (mt *Monitor) registerAllCompleted() {
 
 // ResetFailedAndNetErrorWorkers 重设部分网络错误的worker
 func (mt *Monitor) ResetFailedAndNetErrorWorkers() {
-	for k := range mt.workers {
+	workers := mt.getWorkers()
+	for k := range workers {
 		if !mt.resetController.CanReset() {
 			continue
 		}
 
-		switch mt.workers[k].GetStatus().StatusCode() {
+		switch workers[k].GetStatus().StatusCode() {
 		case StatusCodeNetError:
-			logger.Verbosef("DEBUG: monitor: ResetFailedAndNetErrorWorkers: reset StatusCodeNetError worker, id: %d\n", mt.workers[k].id)
+			logger.Verbosef("DEBUG: monitor: ResetFailedAndNetErrorWorkers: reset StatusCodeNetError worker, id: %d\n", workers[k].id)
 			goto reset
 		case StatusCodeFailed:
-			logger.Verbosef("DEBUG: monitor: ResetFailedAndNetErrorWorkers: reset StatusCodeFailed worker, id: %d\n", mt.workers[k].id)
+			logger.Verbosef("DEBUG: monitor: ResetFailedAndNetErrorWorkers: reset StatusCodeFailed worker, id: %d\n", workers[k].id)
 			goto reset
 		default:
 			continue
 		}
 
 	reset:
-		mt.workers[k].Reset()
+		workers[k].Reset()
 		mt.resetController.AddResetNum()
 	}
 }
 
 // RangeWorker 遍历worker
 func (mt *Monitor) RangeWorker(f RangeWorkerFunc) {
-	for k := range mt.workers {
-		if !f(k, mt.workers[k]) {
+	workers := mt.getWorkers()
+	for k := range workers {
+		if !f(k, workers[k]) {
 			break
 		}
 	}
 }
 
-// Pause 暂停所有的下载
+// Pause 暂停所有的下载, 同时暂停所有worker, 等待全部暂停后返回
 func (mt *Monitor) Pause() {
-	for k := range mt.workers {
-		mt.workers[k].Pause()
+	workers := mt.getWorkers()
+	wg := sync.WaitGroup{}
+	for k := range workers {
+		wg.Add(1)
+		go func(worker *Worker) {
+			defer wg.Done()
+			worker.Pause()
+		}(workers[k])
 	}
+	wg.Wait()
 }
 
 // Resume 恢复所有的下载
 func (mt *Monitor) Resume() {
-	for k := range mt.workers {
-		mt.workers[k].Resume()
+	workers := mt.getWorkers()
+	for k := range workers {
+		workers[k].Resume()
 	}
 }
 
@@ -250,7 +337,7 @@ func (mt *Monitor) TryAddNewWork() {
 		return
 	}
 	gen := mt.status.RangeListGen()
-	if gen == nil || gen.IsDone() {
+	if (gen == nil || gen.IsDone()) && mt.numPendingRanges() == 0 {
 		return
 	}
 
@@ -263,8 +350,14 @@ func (mt *Monitor) TryAddNewWork() {
 		return
 	}
 
-	// 有空闲的range, 执行
-	_, r := gen.GenRange()
+	// 优先分配缩容时留下的range, 再分配新的range
+	r := mt.popPendingRange()
+	if r == nil {
+		if gen == nil || gen.IsDone() {
+			return
+		}
+		_, r = gen.GenRange()
+	}
 	if r == nil {
 		// 没有range了
 		return
@@ -319,6 +412,53 @@ func (mt *Monitor) DynamicSplitWorker(worker *Worker) {
 	go availableWorker.Execute()
 }
 
+// ReassignTimeoutWorker 停止超时的worker, 将其剩余的range分配给空闲的worker重新下载。
+// 没有空闲的worker时, 使用新的连接重设该worker
+func (mt *Monitor) ReassignTimeoutWorker(worker *Worker) {
+	if !mt.resetController.CanReset() {
+		return
+	}
+
+	// 从负载均衡列表获取新的下载地址, 第一个为占位的主服务器地址, 忽略
+	newUrl := ""
+	if mt.loadBalancer != nil {
+		if lb := mt.loadBalancer.SequentialGet(); lb != nil && strings.HasPrefix(lb.URL, "http") {
+			newUrl = lb.URL
+		}
+	}
+
+	availableWorker := mt.GetAvailableWorker()
+	if availableWorker == nil || worker == availableWorker {
+		if newUrl != "" {
+			worker.SetUrl(newUrl)
+		}
+		mt.resetController.AddResetNum()
+		logger.Verbosef("MONITOR: worker[%d] timeout, reload\n", worker.ID())
+		worker.Reset()
+		return
+	}
+
+	workerRange := worker.GetRange()
+	availableWorker.SetRange(&transfer.Range{
+		Begin: workerRange.LoadBegin(),
+		End:   workerRange.LoadEnd(),
+	})
+	if newUrl != "" {
+		availableWorker.SetUrl(newUrl)
+	} else {
+		availableWorker.SetUrl(worker.url)
+	}
+	availableWorker.ClearStatus()
+
+	// 超时的worker不再有需要下载的数据
+	workerRange.StoreEnd(workerRange.LoadBegin())
+	worker.status.SetStatusCode(StatusCodeCanceled)
+
+	mt.resetController.AddResetNum()
+	logger.Verbosef("MONITOR: worker[%d] timeout, range reassigned to worker[%d]: %s\n", worker.ID(), availableWorker.ID(), availableWorker.GetRange().ShowDetails())
+	go availableWorker.Execute()
+}
+
 // ResetWorker 重设长时间无响应, 和下载速度为 0 的 Worker
 func (mt *Monitor) ResetWorker(worker *Worker) {
 	if !mt.resetController.CanReset() { //达到最大重载次数
@@ -342,6 +482,8 @@ func (mt *Monitor) ResetWorker(worker *Worker) {
 		fallthrough
 	case StatusCodeWaitToWrite: // 正在写入数据
 		fallthrough
+	case StatusCodeWaitToRetry: // 等待重试
+		fallthrough
 	case StatusCodePaused: // 已暂停
 		// 忽略, 返回
 		return
@@ -357,36 +499,85 @@ func (mt *Monitor) ResetWorker(worker *Worker) {
 	worker.Reset()
 }
 
+// cancelWorkers 取消所有worker的下载
+func (mt *Monitor) cancelWorkers() {
+	for _, worker := range mt.getWorkers() {
+		err := worker.Cancel()
+		if err != nil {
+			logger.Verbosef("DEBUG: cancel failed, worker id: %d, err: %s\n", worker.ID(), err)
+		}
+	}
+}
+
+// saveInstanceState 保存断点信息到文件
+func (mt *Monitor) saveInstanceState() {
+	if mt.instanceState == nil {
+		return
+	}
+	mt.instanceState.Put(&transfer.DownloadInstanceInfo{
+		DownloadStatus: mt.status,
+		Ranges:         mt.GetAllWorkersRange(),
+	})
+}
+
+// interrupt 收到 SIGINT/SIGTERM 信号, 暂停下载并保存断点信息, 然后取消下载
+func (mt *Monitor) interrupt(cancel context.CancelFunc) {
+	mt.Pause()
+	mt.saveInstanceState()
+	mt.setErr(ErrDownloadInterrupted)
+	if atomic.CompareAndSwapInt32(&interrupted, 0, 1) {
+		// 多个文件同时下载时只提示一次
+		fmt.Printf("\n%s\n", ErrDownloadInterrupted)
+	}
+	cancel()
+}
+
 // Execute 执行任务
 func (mt *Monitor) Execute(cancelCtx context.Context) {
-	if len(mt.workers) == 0 {
-		mt.err = ErrNoWokers
+	if len(mt.getWorkers()) == 0 {
+		mt.setErr(ErrNoWokers)
+		return
+	}
+	if atomic.LoadInt32(&interrupted) == 1 {
+		// 已经收到过中断信号, 不再开始新的下载
+		mt.setErr(ErrDownloadInterrupted)
 		return
 	}
 
+	// 收到中断信号时先保存断点信息再退出
+	ctx, cancel := context.WithCancel(cancelCtx)
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
 	mt.lazyInit()
-	for _, worker := range mt.workers {
+	for _, worker := range mt.getWorkers() {
 		worker.SetDownloadStatus(mt.status)
+		worker.SetParentContext(ctx)
 		go worker.Execute()
 	}
 
 	mt.registerAllCompleted() // 注册completed
 	ticker := time.NewTicker(990 * time.Millisecond)
 	defer ticker.Stop()
+	autoScaleChan := mt.startAutoScale(ctx)
 
 	//开始监控
 	for {
 		select {
-		case <-cancelCtx.Done():
-			for _, worker := range mt.workers {
-				err := worker.Cancel()
-				if err != nil {
-					logger.Verbosef("DEBUG: cancel failed, worker id: %d, err: %s\n", worker.ID(), err)
-				}
-			}
+		case <-sigChan:
+			signal.Stop(sigChan)
+			mt.interrupt(cancel)
+			mt.cancelWorkers()
+			return
+		case <-ctx.Done():
+			mt.cancelWorkers()
 			return
 		case <-mt.completed:
 			return
+		case speed := <-autoScaleChan:
+			mt.autoScale(ctx, speed)
 		case <-ticker.C:
 			// 初始化监控工作
 			mt.ResetFailedAndNetErrorWorkers()
@@ -394,23 +585,25 @@ func (mt *Monitor) Execute(cancelCtx context.Context) {
 			mt.status.UpdateSpeeds() // 更新速度
 
 			// 保存断点信息到文件
-			if mt.instanceState != nil {
-				mt.instanceState.Put(&transfer.DownloadInstanceInfo{
-					DownloadStatus: mt.status,
-					Ranges:         mt.GetAllWorkersRange(),
-				})
-			}
+			mt.saveInstanceState()
 
 			// 加入新range
 			mt.TryAddNewWork()
 
 			// 是否有失败的worker
-			for _, w := range mt.workers {
+			for _, w := range mt.getWorkers() {
 				if w.status.statusCode == StatusCodeDownloadUrlExpired {
 					mt.ResetWorker(w)
 				}
 			}
 
+			// 超时的worker, 重新分配range
+			for _, w := range mt.getWorkers() {
+				if w.status.statusCode == StatusCodeWorkerTimeout {
+					mt.ReassignTimeoutWorker(w)
+				}
+			}
+
 			// 不重载worker
 			if !mt.isReloadWorker {
 				continue
@@ -430,15 +623,17 @@ func (mt *Monitor) Execute(cancelCtx context.Context) {
 
 				// 先进行动态分配线程
 				logger.Verbosef("DEBUG: monitor: start duplicate.\n")
-				sort.Sort(ByLeftDesc{mt.workers})
-				for _, worker := range mt.workers {
+				// 复制后排序, 不影响其他goroutine正在遍历的列表
+				workers := append(WorkerList(nil), mt.getWorkers()...)
+				sort.Sort(ByLeftDesc{workers})
+				for _, worker := range workers {
 					//动态分配线程
 					mt.DynamicSplitWorker(worker)
 				}
 
 				// 重设长时间无响应, 和下载速度为 0 的线程
 				logger.Verbosef("DEBUG: monitor: start reload.\n")
-				for _, worker := range mt.workers {
+				for _, worker := range workers {
 					mt.ResetWorker(worker)
 				}
 			} // end if
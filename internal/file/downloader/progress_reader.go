@@ -0,0 +1,85 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// ProgressEvent 读取进度事件
+	ProgressEvent struct {
+		BytesRead  int64         // 已读取的字节数
+		TotalBytes int64         // 总字节数, 未知则为0
+		Elapsed    time.Duration // 从开始读取到现在经过的时间
+	}
+
+	// ProgressReader 包装 io.Reader, 每次 Read 都累加已读取的字节数, 并将进度事件发送到订阅的channel.
+	// 发送事件不会阻塞读取, channel已满时丢弃本次事件, 由下一次读取继续发送最新的进度
+	ProgressReader struct {
+		reader     io.Reader
+		bytesRead  *int64 // 已读取的字节数, 通过 Wrap 包装的 ProgressReader 共享同一个计数器
+		totalBytes int64
+		startTime  time.Time
+		ch         chan<- ProgressEvent
+	}
+)
+
+// NewProgressReader 包装 reader, totalBytes 为总字节数, ch 为nil时只计数不发送事件
+func NewProgressReader(reader io.Reader, totalBytes int64, ch chan<- ProgressEvent) *ProgressReader {
+	return &ProgressReader{
+		reader:     reader,
+		bytesRead:  new(int64),
+		totalBytes: totalBytes,
+		startTime:  time.Now(),
+		ch:         ch,
+	}
+}
+
+// Wrap 包装新的 reader, 和当前的 ProgressReader 共享计数器和channel, 用于多个worker汇总同一个文件的进度
+func (pr *ProgressReader) Wrap(reader io.Reader) *ProgressReader {
+	return &ProgressReader{
+		reader:     reader,
+		bytesRead:  pr.bytesRead,
+		totalBytes: pr.totalBytes,
+		startTime:  pr.startTime,
+		ch:         pr.ch,
+	}
+}
+
+// Read 实现 io.Reader
+func (pr *ProgressReader) Read(p []byte) (n int, err error) {
+	n, err = pr.reader.Read(p)
+	if n > 0 {
+		bytesRead := atomic.AddInt64(pr.bytesRead, int64(n))
+		if pr.ch != nil {
+			select {
+			case pr.ch <- ProgressEvent{
+				BytesRead:  bytesRead,
+				TotalBytes: pr.totalBytes,
+				Elapsed:    time.Since(pr.startTime),
+			}:
+			default:
+			}
+		}
+	}
+	return
+}
+
+// BytesRead 返回已读取的字节数
+func (pr *ProgressReader) BytesRead() int64 {
+	return atomic.LoadInt64(pr.bytesRead)
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/library-go/requester/rio/speeds"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type (
+	// DownloadQueueItem 下载队列中的文件
+	DownloadQueueItem struct {
+		FileInfo  *aliyunpan.FileEntity // 网盘文件
+		LocalPath string                // 本地保存路径
+		Err       error                 // 下载结果
+	}
+
+	// DownloadQueueFunc 下载队列中单个文件的下载方法
+	DownloadQueueFunc func(item *DownloadQueueItem) error
+
+	// DownloadQueueResult 下载队列的执行结果
+	DownloadQueueResult struct {
+		Succeeded   int
+		Failed      int
+		FailedItems []*DownloadQueueItem
+	}
+
+	// DownloadQueue 多个文件的下载队列, 使用信号量限制同时运行的 Downloader 数量
+	DownloadQueue struct {
+		MaxConcurrentFiles int // 同时下载的最大文件数量
+
+		config           *Config
+		panClient        *config.PanClient
+		globalSpeedsStat *speeds.Speeds
+		downloadFunc     DownloadQueueFunc
+
+		mu      sync.Mutex
+		items   []*DownloadQueueItem
+		sem     chan struct{}
+		wg      sync.WaitGroup
+		started bool
+		result  DownloadQueueResult
+	}
+)
+
+// NewDownloadQueue 初始化下载队列, 每个文件使用 cfg 的副本创建 Downloader
+func NewDownloadQueue(maxConcurrentFiles int, cfg *Config, p *config.PanClient, globalSpeedsStat *speeds.Speeds) *DownloadQueue {
+	if maxConcurrentFiles < 1 {
+		maxConcurrentFiles = 1
+	}
+	return &DownloadQueue{
+		MaxConcurrentFiles: maxConcurrentFiles,
+		config:             cfg,
+		panClient:          p,
+		globalSpeedsStat:   globalSpeedsStat,
+	}
+}
+
+// SetDownloadFunc 设置单个文件的下载方法, 替换默认的 Downloader 下载
+func (q *DownloadQueue) SetDownloadFunc(f DownloadQueueFunc) {
+	q.downloadFunc = f
+}
+
+// Add 添加文件到下载队列, 队列已经开始时立即排队下载
+func (q *DownloadQueue) Add(fileInfo *aliyunpan.FileEntity, localPath string) {
+	item := &DownloadQueueItem{
+		FileInfo:  fileInfo,
+		LocalPath: localPath,
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, item)
+	if q.started {
+		q.run(item)
+	}
+}
+
+// Start 开始下载队列中的文件
+func (q *DownloadQueue) Start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		return
+	}
+	q.started = true
+	q.sem = make(chan struct{}, q.MaxConcurrentFiles)
+	for _, item := range q.items {
+		q.run(item)
+	}
+}
+
+// run 启动文件的下载, 获取到信号量后才开始执行, 调用前需要加锁
+func (q *DownloadQueue) run(item *DownloadQueueItem) {
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		q.sem <- struct{}{}
+		defer func() {
+			<-q.sem
+		}()
+
+		err := q.download(item)
+
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		item.Err = err
+		if err != nil {
+			q.result.Failed++
+			q.result.FailedItems = append(q.result.FailedItems, item)
+		} else {
+			q.result.Succeeded++
+		}
+	}()
+}
+
+// download 下载单个文件
+func (q *DownloadQueue) download(item *DownloadQueueItem) error {
+	if q.downloadFunc != nil {
+		return q.downloadFunc(item)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(item.LocalPath), 0777); err != nil {
+		return err
+	}
+	writer, file, err := NewDownloaderWriterByFilename(item.LocalPath, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cfg := *q.config
+	der := NewDownloader(writer, &cfg, q.panClient, q.globalSpeedsStat)
+	der.SetFileInfo(item.FileInfo)
+	der.SetDriveId(item.FileInfo.DriveId)
+	return der.Execute()
+}
+
+// WaitAll 等待所有文件下载结束, 输出成功和失败的数量
+func (q *DownloadQueue) WaitAll() DownloadQueueResult {
+	q.Start()
+	q.wg.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	fmt.Printf("下载队列结束, 成功: %d, 失败: %d\n", q.result.Succeeded, q.result.Failed)
+	for _, item := range q.result.FailedItems {
+		fmt.Printf("下载失败: %s, %s\n", item.LocalPath, item.Err)
+	}
+	return q.result
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultRetryInitialDelay 默认的第一次重试前等待的时间
+	DefaultRetryInitialDelay = 1 * time.Second
+	// DefaultRetryMaxDelay 默认的重试等待时间上限
+	DefaultRetryMaxDelay = 30 * time.Second
+)
+
+type (
+	// RetryPolicy worker遇到临时性网络错误时的重试策略, 重试间隔按照指数退避增长
+	RetryPolicy struct {
+		MaxRetries   int           // 最大重试次数
+		InitialDelay time.Duration // 第一次重试前等待的时间
+		MaxDelay     time.Duration // 重试等待时间的上限, 0表示不限制
+	}
+)
+
+// NewRetryPolicy 使用默认的重试间隔创建重试策略, maxRetries 小于等于0时返回nil, 即不重试
+func NewRetryPolicy(maxRetries int) *RetryPolicy {
+	if maxRetries <= 0 {
+		return nil
+	}
+	return &RetryPolicy{
+		MaxRetries:   maxRetries,
+		InitialDelay: DefaultRetryInitialDelay,
+		MaxDelay:     DefaultRetryMaxDelay,
+	}
+}
+
+// Delay 第 attempt 次重试(从0开始)前等待的时间, 即 InitialDelay * 2^attempt, 不超过 MaxDelay
+func (rp *RetryPolicy) Delay(attempt int) time.Duration {
+	delay := rp.InitialDelay
+	for i := 0; i < attempt; i++ {
+		if rp.MaxDelay > 0 && delay >= rp.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	if rp.MaxDelay > 0 && delay > rp.MaxDelay {
+		delay = rp.MaxDelay
+	}
+	return delay
+}
+
+// IsTransientError 是否为可以重试的临时性网络错误, 包括连接意外断开, 连接被重置和超时
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return strings.Contains(err.Error(), "connection reset")
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// SegmentLogger 将每个分段的下载数据以CSV格式追加到文件, 用于离线分析各CDN节点的性能差异
+	SegmentLogger struct {
+		mu     sync.Mutex
+		file   *os.File
+		writer *csv.Writer
+	}
+
+	// SegmentRecord 一条分段下载记录
+	SegmentRecord struct {
+		WorkerId   int
+		RangeBegin int64
+		RangeEnd   int64
+		Bytes      int64
+		Duration   time.Duration
+		CdnHost    string
+		HttpStatus int
+	}
+)
+
+var segmentLogHeader = []string{"worker_id", "range_begin", "range_end", "bytes", "duration_ms", "speed_bps", "cdn_host", "http_status"}
+
+// NewSegmentLogger 以追加方式打开记录文件, 文件不存在或者为空时先写入表头
+func NewSegmentLogger(filePath string) (*SegmentLogger, error) {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	sl := &SegmentLogger{
+		file:   file,
+		writer: csv.NewWriter(file),
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() == 0 {
+		sl.writer.Write(segmentLogHeader)
+		sl.writer.Flush()
+		if err = sl.writer.Error(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return sl, nil
+}
+
+// Record 追加一条分段下载记录
+func (sl *SegmentLogger) Record(r *SegmentRecord) error {
+	if sl == nil || r == nil {
+		return nil
+	}
+	durationMs := r.Duration.Milliseconds()
+	speed := int64(0)
+	if r.Duration > 0 {
+		speed = int64(float64(r.Bytes) / r.Duration.Seconds())
+	}
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	sl.writer.Write([]string{
+		strconv.Itoa(r.WorkerId),
+		strconv.FormatInt(r.RangeBegin, 10),
+		strconv.FormatInt(r.RangeEnd, 10),
+		strconv.FormatInt(r.Bytes, 10),
+		strconv.FormatInt(durationMs, 10),
+		strconv.FormatInt(speed, 10),
+		r.CdnHost,
+		strconv.Itoa(r.HttpStatus),
+	})
+	sl.writer.Flush()
+	return sl.writer.Error()
+}
+
+// Close 关闭记录文件
+func (sl *SegmentLogger) Close() error {
+	if sl == nil {
+		return nil
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	sl.writer.Flush()
+	return sl.file.Close()
+}
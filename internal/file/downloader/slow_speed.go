@@ -0,0 +1,78 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrDownloadTooSlow 下载速度持续低于阈值, 下载已取消
+	ErrDownloadTooSlow = errors.New("下载速度持续过慢, 已取消下载")
+)
+
+type (
+	// SlowSpeedConfig 下载速度持续过慢时取消下载的配置
+	SlowSpeedConfig struct {
+		Rate     int64         // 速度阈值, 单位 字节/秒
+		Duration time.Duration // 平均速度低于阈值持续的时长
+	}
+
+	speedSample struct {
+		at         time.Time
+		downloaded int64
+	}
+
+	// slowSpeedDetector 按照最近 Duration 时长内的平均速度判断下载是否过慢
+	slowSpeedDetector struct {
+		config  SlowSpeedConfig
+		samples []speedSample
+	}
+)
+
+// Enabled 是否开启
+func (sc SlowSpeedConfig) Enabled() bool {
+	return sc.Rate > 0 && sc.Duration > 0
+}
+
+func newSlowSpeedDetector(config SlowSpeedConfig) *slowSpeedDetector {
+	return &slowSpeedDetector{
+		config:  config,
+		samples: make([]speedSample, 0, 16),
+	}
+}
+
+// observe 记录一次已下载的数据量, 最近 Duration 时长内的平均速度低于 Rate 时返回true.
+// 下载开始后不足 Duration 时长不做判断
+func (sd *slowSpeedDetector) observe(now time.Time, downloaded int64) bool {
+	sd.samples = append(sd.samples, speedSample{at: now, downloaded: downloaded})
+
+	// 丢弃窗口以外的采样, 保留一个刚好覆盖 Duration 时长的起点
+	drop := 0
+	for drop+1 < len(sd.samples) && now.Sub(sd.samples[drop+1].at) >= sd.config.Duration {
+		drop++
+	}
+	if drop > 0 {
+		sd.samples = append(sd.samples[:0], sd.samples[drop:]...)
+	}
+
+	oldest := sd.samples[0]
+	elapsed := now.Sub(oldest.at)
+	if elapsed < sd.config.Duration {
+		return false
+	}
+	speed := float64(downloaded-oldest.downloaded) / elapsed.Seconds()
+	return speed < float64(sd.config.Rate)
+}
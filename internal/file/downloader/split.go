@@ -0,0 +1,200 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+type (
+	// SplitFileWriter 将下载的数据直接写入多个最大为 splitSize 字节的分块文件 <文件名>.part001, <文件名>.part002 ...,
+	// 不生成完整的文件, 用于保存到有单文件大小限制的文件系统
+	SplitFileWriter struct {
+		filePath  string
+		splitSize int64
+		perm      os.FileMode
+		files     []*os.File
+		mu        sync.Mutex
+	}
+)
+
+// SplitFilePartName 返回分割后的第 index 个分块文件名, index 从1开始
+func SplitFilePartName(filePath string, index int) string {
+	return fmt.Sprintf("%s.part%03d", filePath, index)
+}
+
+// SplitFilePartCount 返回 totalSize 大小的文件分割后的分块数量
+func SplitFilePartCount(totalSize, splitSize int64) int {
+	if totalSize <= 0 || splitSize <= 0 {
+		return 0
+	}
+	return int((totalSize + splitSize - 1) / splitSize)
+}
+
+// SplitFilePartsExist 检查文件的所有分块文件是否都已存在并且大小正确
+func SplitFilePartsExist(filePath string, splitSize, totalSize int64) bool {
+	count := SplitFilePartCount(totalSize, splitSize)
+	if count == 0 {
+		return false
+	}
+	for index := 1; index <= count; index++ {
+		info, err := os.Stat(SplitFilePartName(filePath, index))
+		if err != nil {
+			return false
+		}
+		partSize := splitSize
+		if index == count {
+			partSize = totalSize - splitSize*int64(count-1)
+		}
+		if info.Size() != partSize {
+			return false
+		}
+	}
+	return true
+}
+
+// NewSplitFileWriter 创建分块文件输出, 分块文件在第一次写入时创建
+func NewSplitFileWriter(filePath string, splitSize int64, perm os.FileMode) *SplitFileWriter {
+	return &SplitFileWriter{
+		filePath:  filePath,
+		splitSize: splitSize,
+		perm:      perm,
+	}
+}
+
+// partFile 获取第 index 个分块文件, index 从0开始
+func (sw *SplitFileWriter) partFile(index int) (*os.File, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	for len(sw.files) <= index {
+		sw.files = append(sw.files, nil)
+	}
+	if sw.files[index] == nil {
+		// 需要读取分块文件用于校验, 所以以读写方式打开
+		f, err := os.OpenFile(SplitFilePartName(sw.filePath, index+1), os.O_CREATE|os.O_RDWR, sw.perm)
+		if err != nil {
+			return nil, err
+		}
+		sw.files[index] = f
+	}
+	return sw.files[index], nil
+}
+
+// WriteAt 将数据写入对应的分块文件, 跨越分块边界的数据拆分写入相邻的分块
+func (sw *SplitFileWriter) WriteAt(p []byte, off int64) (n int, err error) {
+	for len(p) > 0 {
+		index := int(off / sw.splitSize)
+		partOff := off % sw.splitSize
+		size := int64(len(p))
+		if size > sw.splitSize-partOff {
+			size = sw.splitSize - partOff
+		}
+		f, err := sw.partFile(index)
+		if err != nil {
+			return n, err
+		}
+		written, err := f.WriteAt(p[:size], partOff)
+		n += written
+		if err != nil {
+			return n, err
+		}
+		p = p[size:]
+		off += size
+	}
+	return n, nil
+}
+
+// ReadAt 从分块文件读取数据, 用于下载完成后校验
+func (sw *SplitFileWriter) ReadAt(p []byte, off int64) (n int, err error) {
+	for len(p) > 0 {
+		index := int(off / sw.splitSize)
+		partOff := off % sw.splitSize
+		size := int64(len(p))
+		if size > sw.splitSize-partOff {
+			size = sw.splitSize - partOff
+		}
+		f, err := sw.partFile(index)
+		if err != nil {
+			return n, err
+		}
+		read, err := f.ReadAt(p[:size], partOff)
+		n += read
+		if err != nil {
+			return n, err
+		}
+		p = p[size:]
+		off += size
+	}
+	return n, nil
+}
+
+// Parts 返回已经创建的分块文件路径
+func (sw *SplitFileWriter) Parts() []string {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	parts := []string{}
+	for index, f := range sw.files {
+		if f != nil {
+			parts = append(parts, SplitFilePartName(sw.filePath, index+1))
+		}
+	}
+	return parts
+}
+
+// Chmod 修改所有分块文件的权限
+func (sw *SplitFileWriter) Chmod(mode os.FileMode) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	for _, f := range sw.files {
+		if f == nil {
+			continue
+		}
+		if err := f.Chmod(mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove 关闭并删除所有分块文件
+func (sw *SplitFileWriter) Remove() error {
+	parts := sw.Parts()
+	sw.Close()
+	var err error
+	for _, p := range parts {
+		if er := os.Remove(p); er != nil && !os.IsNotExist(er) {
+			err = er
+		}
+	}
+	return err
+}
+
+// Close 关闭所有分块文件
+func (sw *SplitFileWriter) Close() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	var err error
+	for index, f := range sw.files {
+		if f == nil {
+			continue
+		}
+		if er := f.Close(); er != nil {
+			err = er
+		}
+		sw.files[index] = nil
+	}
+	return err
+}
@@ -65,6 +65,10 @@ const (
 	StatusCodeDownloadUrlExpired
 	//StatusCodeIllegalDownloadFile 文件非法，不允许下载
 	StatusCodeIllegalDownloadFile
+	//StatusCodeWorkerTimeout worker超时
+	StatusCodeWorkerTimeout
+	//StatusCodeWaitToRetry 遇到临时性网络错误, 等待重试
+	StatusCodeWaitToRetry
 )
 
 //GetStatusText 根据状态码获取状态信息
@@ -94,6 +98,10 @@ func GetStatusText(sc StatusCode) string {
 		return "已重设连接"
 	case StatusCodeCanceled:
 		return "已取消"
+	case StatusCodeWorkerTimeout:
+		return "超时"
+	case StatusCodeWaitToRetry:
+		return "等待重试"
 	default:
 		return "未知状态码"
 	}
@@ -0,0 +1,106 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package downloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// MinTokenBucketBurst 令牌桶最小的容量
+	MinTokenBucketBurst = 4 * 1024
+)
+
+type (
+	// TokenBucket 令牌桶限速, 用于限制单个worker的下载速度.
+	// 令牌按照 rate 每秒的速度补充, 最多累积 burst 个, 避免worker空闲后以线路速度突发下载
+	TokenBucket struct {
+		mu     sync.Mutex
+		rate   int64
+		burst  int64
+		tokens float64
+		last   time.Time
+	}
+)
+
+// NewTokenBucket 初始化令牌桶, rate 为每秒补充的令牌数(字节), burst 为令牌桶容量
+func NewTokenBucket(rate, burst int64) *TokenBucket {
+	if burst < MinTokenBucketBurst {
+		burst = MinTokenBucketBurst
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Burst 令牌桶容量, 单次 WaitN 不应该超过该值
+func (tb *TokenBucket) Burst() int64 {
+	return tb.burst
+}
+
+// refill 按照经过的时间补充令牌, 调用前需要加锁
+func (tb *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(tb.last).Seconds()
+	tb.last = now
+	tb.tokens += elapsed * float64(tb.rate)
+	if tb.tokens > float64(tb.burst) {
+		tb.tokens = float64(tb.burst)
+	}
+}
+
+// WaitN 获取 n 个令牌, 令牌不足时阻塞到令牌补充足够或者 ctx 取消
+func (tb *TokenBucket) WaitN(ctx context.Context, n int64) error {
+	if tb == nil || tb.rate <= 0 || n <= 0 {
+		return nil
+	}
+	tb.mu.Lock()
+	tb.refill(time.Now())
+	// 预先扣除令牌, 不足的部分按照速度计算需要等待的时间
+	tb.tokens -= float64(n)
+	var wait time.Duration
+	if tb.tokens < 0 {
+		wait = time.Duration(-tb.tokens / float64(tb.rate) * float64(time.Second))
+	}
+	tb.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Refund 归还未使用的令牌
+func (tb *TokenBucket) Refund(n int64) {
+	if tb == nil || n <= 0 {
+		return
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens += float64(n)
+	if tb.tokens > float64(tb.burst) {
+		tb.tokens = float64(tb.burst)
+	}
+}
@@ -14,11 +14,15 @@
 package downloader
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/tickstep/library-go/logger"
 	"github.com/tickstep/library-go/requester"
 	mathrand "math/rand"
 	"mime"
+	"net"
+	"net/http"
 	"net/url"
 	"path"
 	"regexp"
@@ -38,6 +42,12 @@ var (
 
 	// 文件被禁止下载
 	ErrFileDownloadForbidden = errors.New("文件被禁止下载")
+
+	// ErrTooManyRedirects 重定向次数超过限制
+	ErrTooManyRedirects = errors.New("重定向次数超过限制")
+
+	// ErrStartAtOutOfRange 开始下载的位置超过文件大小
+	ErrStartAtOutOfRange = errors.New("开始下载的位置超过文件大小")
 )
 
 // RandomNumber 生成指定区间随机数
@@ -114,3 +124,175 @@ func IsUrlExpired(urlStr string) bool {
 	}
 	return false
 }
+
+// NewSharedTransport 创建可被多个worker共享的 http.Transport, 保留全局代理、本地网卡等设置
+func NewSharedTransport(poolSize int) *http.Transport {
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	client := requester.NewHTTPClient()
+	client.SetKeepAlive(true)
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok || transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	transport.MaxIdleConnsPerHost = poolSize
+	if transport.MaxIdleConns > 0 && transport.MaxIdleConns < poolSize {
+		transport.MaxIdleConns = poolSize
+	}
+	return transport
+}
+
+// CheckLocalIP 检测IP地址是否已分配给本机的网卡
+func CheckLocalIP(ip string) error {
+	localIP := net.ParseIP(ip)
+	if localIP == nil {
+		return fmt.Errorf("无效的IP地址: %s", ip)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(localIP) {
+			return nil
+		}
+	}
+	return fmt.Errorf("IP地址 %s 不属于本机的任何网卡", ip)
+}
+
+// NewBindIPTransport 基于 transport 复制一份新的 Transport, 出站连接绑定到指定的本地IP
+func NewBindIPTransport(transport http.RoundTripper, ip string) *http.Transport {
+	t, ok := transport.(*http.Transport)
+	if !ok || t == nil {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	dialer := &net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: net.ParseIP(ip)},
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+	t.DialTLSContext = nil
+	return t
+}
+
+// ParseProxyURL 解析代理地址, 支持 http, https, socks5 代理, 例如 http://127.0.0.1:8888
+func ParseProxyURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的代理地址: %s", proxyURL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("不支持的代理类型: %s, 只支持 http, https, socks5 代理", proxyURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("无效的代理地址: %s", proxyURL)
+	}
+	return u, nil
+}
+
+// NewProxyTransport 基于 transport 复制一份新的 Transport, 通过指定的代理发起请求
+func NewProxyTransport(transport http.RoundTripper, proxyURL *url.URL) *http.Transport {
+	t, ok := transport.(*http.Transport)
+	if !ok || t == nil {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	t.Proxy = http.ProxyURL(proxyURL)
+	return t
+}
+
+type (
+	// contextTransport 请求附加 ctx, ctx 结束时取消请求
+	contextTransport struct {
+		transport http.RoundTripper
+		ctx       context.Context
+	}
+)
+
+// NewContextTransport 返回在 ctx 结束时取消请求的 RoundTripper, 用于无法传入 context 的 requester 请求
+func NewContextTransport(transport http.RoundTripper, ctx context.Context) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &contextTransport{
+		transport: transport,
+		ctx:       ctx,
+	}
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqCtx, cancel := context.WithCancel(req.Context())
+	go func() {
+		select {
+		case <-t.ctx.Done():
+			cancel()
+		case <-reqCtx.Done():
+		}
+	}()
+	return t.transport.RoundTrip(req.WithContext(reqCtx))
+}
+
+// NewCheckRedirectFunc 返回限制重定向次数的 CheckRedirect 函数, 超过 maxRedirects 次则返回错误
+func NewCheckRedirectFunc(maxRedirects int) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return fmt.Errorf("%w: %d", ErrTooManyRedirects, maxRedirects)
+		}
+		return nil
+	}
+}
+
+// FormatETA 按指定格式输出剩余时间, 剩余时间未知则返回 -
+func FormatETA(left time.Duration, format string) string {
+	if left < 0 {
+		return "-"
+	}
+	if format != ETAFormatDatetime {
+		return left.String()
+	}
+	now := time.Now()
+	eta := now.Add(left)
+	if eta.YearDay() != now.YearDay() || eta.Year() != now.Year() {
+		return eta.Format("2006-01-02 15:04:05")
+	}
+	return eta.Format("15:04:05")
+}
+
+// FitMemoryLimit 根据内存上限调整下载缓存大小和并发线程数, 使 已使用的内存 + cacheSize*parallel 不超过 maxMemory.
+// 优先调低缓存大小(最小1024), 仍然超过时再调低并发线程数(最小为 minParallel)
+func FitMemoryLimit(maxMemory, inUse int64, cacheSize, parallel, minParallel int) (int, int) {
+	if minParallel < 1 {
+		minParallel = 1
+	}
+	available := maxMemory - inUse
+	if int64(cacheSize)*int64(parallel) <= available {
+		return cacheSize, parallel
+	}
+	if available <= 0 {
+		return 1024, minParallel
+	}
+
+	// 调低缓存大小
+	if size := available / int64(parallel); size < int64(cacheSize) {
+		cacheSize = int(size)
+	}
+	fixCacheSize(&cacheSize)
+	if int64(cacheSize)*int64(parallel) <= available {
+		return cacheSize, parallel
+	}
+
+	// 调低并发线程数
+	parallel = int(available / int64(cacheSize))
+	if parallel < minParallel {
+		parallel = minParallel
+	}
+	return cacheSize, parallel
+}
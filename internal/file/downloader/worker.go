@@ -30,6 +30,18 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrWorkerTimeout worker 超过指定时间没有收到数据
+	ErrWorkerTimeout = errors.New("worker timeout")
+)
+
+const (
+	// workerPauseTimeout 暂停worker时等待下载循环响应的最长时间
+	workerPauseTimeout = 3 * time.Second
 )
 
 type (
@@ -49,6 +61,16 @@ type (
 		writerAt         io.WriterAt
 		writeMu          *sync.Mutex
 		execMu           sync.Mutex
+		timeout          time.Duration   // 超过该时间没有收到数据则停止worker, 0表示不限制
+		requestTimeout   time.Duration   // 单次Range请求的超时时间, 0表示不限制
+		parentCtx        context.Context // worker请求的父context, 由monitor设置
+		timedOut         int32           // 是否已超时
+		headerRecorder   *HeaderRecorder // 记录响应头, 为nil则不记录
+		segmentLogger    *SegmentLogger  // 记录分段下载数据, 为nil则不记录
+		rateLimit        *TokenBucket    // 单个worker的限速, 为nil则不限速
+		segmentOverlap   int64           // 向前多请求的字节数, 和前一个分段重叠, 重叠部分读取后丢弃
+		retryPolicy      *RetryPolicy    // 临时性网络错误的重试策略, 为nil则不重试, 直接交给monitor处理
+		progressReader   *ProgressReader // 响应数据的读取进度, 为nil则不统计
 
 		pauseChan              chan struct{}
 		workerCancelFunc       context.CancelFunc
@@ -90,6 +112,7 @@ func (wer *Worker) ID() int {
 func (wer *Worker) lazyInit() {
 	if wer.client == nil {
 		wer.client = requester.NewHTTPClient()
+		wer.client.SetKeepAlive(true)
 	}
 	if wer.pauseChan == nil {
 		wer.pauseChan = make(chan struct{})
@@ -121,6 +144,56 @@ func (wer *Worker) SetPanClient(p *config.PanClient) {
 	wer.panClient = p
 }
 
+// SetTimeout 设置worker超时时间, 超过该时间没有收到数据则停止worker
+func (wer *Worker) SetTimeout(timeout time.Duration) {
+	wer.timeout = timeout
+}
+
+// SetRequestTimeout 设置单次读取的空闲超时时间, 建立连接或者一次读取超过该时间则取消请求, 剩余的range由monitor分配给新的worker
+func (wer *Worker) SetRequestTimeout(timeout time.Duration) {
+	wer.requestTimeout = timeout
+}
+
+// SetRetryPolicy 设置临时性网络错误的重试策略
+func (wer *Worker) SetRetryPolicy(rp *RetryPolicy) {
+	wer.retryPolicy = rp
+}
+
+// SetProgressReader 设置读取进度, 每次请求的响应数据都通过 pr.Wrap 包装后读取
+func (wer *Worker) SetProgressReader(pr *ProgressReader) {
+	wer.progressReader = pr
+}
+
+// SetParentContext 设置worker请求的父context
+func (wer *Worker) SetParentContext(ctx context.Context) {
+	wer.parentCtx = ctx
+}
+
+// TimedOut 上一次执行是否因为超时而停止
+func (wer *Worker) TimedOut() bool {
+	return atomic.LoadInt32(&wer.timedOut) == 1
+}
+
+// SetHeaderRecorder 设置响应头记录器, 每次分段请求成功后记录响应头
+func (wer *Worker) SetHeaderRecorder(hr *HeaderRecorder) {
+	wer.headerRecorder = hr
+}
+
+// SetSegmentLogger 设置分段下载记录器, 每次分段请求结束后记录下载的数据量和速度
+func (wer *Worker) SetSegmentLogger(sl *SegmentLogger) {
+	wer.segmentLogger = sl
+}
+
+// SetSegmentOverlap 设置分段重叠的字节数, 用于规避部分CDN节点在分段边界处丢失数据的问题
+func (wer *Worker) SetSegmentOverlap(overlap int64) {
+	wer.segmentOverlap = overlap
+}
+
+// SetRateLimit 设置单个worker的限速
+func (wer *Worker) SetRateLimit(rl *TokenBucket) {
+	wer.rateLimit = rl
+}
+
 // SetAcceptRange 设置AcceptRange
 func (wer *Worker) SetAcceptRange(acceptRanges string) {
 	wer.acceptRanges = acceptRanges
@@ -175,11 +248,17 @@ func (wer *Worker) Pause() {
 		return
 	}
 
-	if wer.status.statusCode == StatusCodePaused {
+	if wer.status.statusCode != StatusCodeDownloading {
+		// 已暂停或者不在下载中, worker没有在读取 pauseChan
 		return
 	}
-	wer.pauseChan <- struct{}{}
-	wer.status.statusCode = StatusCodePaused
+	select {
+	case wer.pauseChan <- struct{}{}:
+		wer.status.statusCode = StatusCodePaused
+	case <-time.After(workerPauseTimeout):
+		// 下载循环已经结束
+		logger.Verbosef("DEBUG: worker %d pause timeout\n", wer.id)
+	}
 }
 
 // Resume 恢复下载
@@ -246,7 +325,7 @@ func (wer *Worker) Completed() bool {
 // Failed 是否失败
 func (wer *Worker) Failed() bool {
 	switch wer.status.statusCode {
-	case StatusCodeFailed, StatusCodeInternalError, StatusCodeTooManyConnections, StatusCodeNetError:
+	case StatusCodeFailed, StatusCodeInternalError, StatusCodeTooManyConnections, StatusCodeNetError, StatusCodeWorkerTimeout:
 		return true
 	default:
 		return false
@@ -270,6 +349,58 @@ func (wer *Worker) Execute() {
 	wer.execMu.Lock()
 	defer wer.execMu.Unlock()
 
+	retried := 0
+	for {
+		// 下载链接过期时返回, 由monitor刷新链接后重设worker
+		wer.execute()
+		if !wer.shouldRetry(retried) {
+			return
+		}
+
+		// 临时性网络错误, 等待一段时间后从已写入的位置继续请求
+		delay := wer.retryPolicy.Delay(retried)
+		retried++
+		logger.Verbosef("worker[%d] transient error: %s, retry %d/%d after %s\n", wer.id, wer.err, retried, wer.retryPolicy.MaxRetries, delay)
+		if !wer.waitRetry(delay) {
+			return
+		}
+		wer.err = nil
+	}
+}
+
+// shouldRetry worker是否因为临时性网络错误失败, 并且还可以重试
+func (wer *Worker) shouldRetry(retried int) bool {
+	if wer.retryPolicy == nil || retried >= wer.retryPolicy.MaxRetries {
+		return false
+	}
+	switch wer.status.statusCode {
+	case StatusCodeNetError, StatusCodeFailed:
+		return IsTransientError(wer.err)
+	default:
+		return false
+	}
+}
+
+// waitRetry 等待重试, 父context取消时返回false.
+// 等待期间设置为等待重试状态, monitor不会重设该worker
+func (wer *Worker) waitRetry(delay time.Duration) bool {
+	wer.status.statusCode = StatusCodeWaitToRetry
+	if wer.parentCtx == nil {
+		time.Sleep(delay)
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-wer.parentCtx.Done():
+		return false
+	}
+}
+
+// execute 执行一次下载请求
+func (wer *Worker) execute() {
 	wer.status.statusCode = StatusCodeInit
 	single := wer.acceptRanges == ""
 
@@ -310,13 +441,66 @@ func (wer *Worker) Execute() {
 		return
 	}
 
+	// worker超时控制, 每次收到数据都会重新计时, 超时后取消请求
+	// 单次读取超时控制, 建立连接和每次读取数据都需要在该时间内完成, 写入硬盘和限速等待的时间不计算在内
+	var (
+		requestCtx   context.Context
+		timeoutTimer *time.Timer
+		idleTimer    *time.Timer
+	)
+	atomic.StoreInt32(&wer.timedOut, 0)
+	if wer.timeout > 0 || wer.requestTimeout > 0 {
+		parentCtx := wer.parentCtx
+		if parentCtx == nil {
+			parentCtx = context.Background()
+		}
+		var requestCancelFunc context.CancelFunc
+		requestCtx, requestCancelFunc = context.WithCancel(parentCtx)
+		defer requestCancelFunc()
+		if wer.timeout > 0 {
+			timeoutTimer = time.AfterFunc(wer.timeout, func() {
+				atomic.StoreInt32(&wer.timedOut, 1)
+				requestCancelFunc()
+			})
+			defer timeoutTimer.Stop()
+		}
+		if wer.requestTimeout > 0 {
+			idleTimer = time.AfterFunc(wer.requestTimeout, func() {
+				atomic.StoreInt32(&wer.timedOut, 1)
+				requestCancelFunc()
+			})
+			defer idleTimer.Stop()
+		}
+	}
+
+	// 分段重叠, 从前一个分段的末尾开始请求, 重叠部分已经由前一个分段写入
+	overlap := int64(0)
+	if !single && wer.segmentOverlap > 0 && wer.wrange.Begin > 0 {
+		overlap = wer.segmentOverlap
+		if overlap > wer.wrange.Begin {
+			overlap = wer.wrange.Begin
+		}
+	}
+
 	// do download data
-	var resp *http.Response
+	var (
+		resp         *http.Response
+		segmentBegin = wer.wrange.Begin
+		segmentEnd   = wer.wrange.End
+		segmentStart = time.Now()
+	)
 	apierr := wer.panClient.OpenapiPanClient().DownloadFileData(wer.url, aliyunpan.FileDownloadRange{
-		Offset: wer.wrange.Begin,
+		Offset: wer.wrange.Begin - overlap,
 		End:    wer.wrange.End - 1,
 	}, func(httpMethod, fullUrl string, headers map[string]string) (*http.Response, error) {
-		resp, wer.err = wer.client.Req(httpMethod, fullUrl, nil, headers)
+		if requestCtx != nil {
+			// 复制一份 client, 请求仍然通过 requester 发起, requestCtx 结束时取消请求
+			client := *wer.client
+			client.Transport = NewContextTransport(wer.client.Transport, requestCtx)
+			resp, wer.err = client.Req(httpMethod, fullUrl, nil, headers)
+		} else {
+			resp, wer.err = wer.client.Req(httpMethod, fullUrl, nil, headers)
+		}
 		if wer.err != nil {
 			return nil, wer.err
 		}
@@ -332,16 +516,50 @@ func (wer *Worker) Execute() {
 		}
 	}
 	if wer.err != nil || apierr != nil {
+		if wer.TimedOut() {
+			wer.status.statusCode = StatusCodeWorkerTimeout
+			wer.err = ErrWorkerTimeout
+			return
+		}
 		wer.status.statusCode = StatusCodeNetError
 		return
 	}
 
+	if wer.segmentLogger != nil {
+		defer func() {
+			cdnHost := ""
+			if resp.Request != nil && resp.Request.URL != nil {
+				cdnHost = resp.Request.URL.Host
+			}
+			if e := wer.segmentLogger.Record(&SegmentRecord{
+				WorkerId:   wer.id,
+				RangeBegin: segmentBegin,
+				RangeEnd:   segmentEnd - 1,
+				Bytes:      wer.wrange.Begin - segmentBegin,
+				Duration:   time.Since(segmentStart),
+				CdnHost:    cdnHost,
+				HttpStatus: resp.StatusCode,
+			}); e != nil {
+				logger.Verbosef("DEBUG: save segment log error: %s\n", e)
+			}
+		}()
+	}
+
 	// 判断响应状态
 	switch resp.StatusCode {
 	case 200, 206:
 		// do nothing, continue
 		wer.status.statusCode = StatusCodeDownloading
+		if wer.headerRecorder != nil {
+			if e := wer.headerRecorder.Record(wer.fileId, wer.id, wer.url, wer.wrange, resp); e != nil {
+				logger.Verbosef("DEBUG: save response headers error: %s\n", e)
+			}
+		}
 		break
+	case 401: // 链接签名失效
+		wer.status.statusCode = StatusCodeDownloadUrlExpired
+		wer.err = errors.New(resp.Status)
+		return
 	case 416: //Requested Range Not Satisfiable
 		fallthrough
 	case 403: // 链接过期也会返回403
@@ -378,9 +596,9 @@ func (wer *Worker) Execute() {
 
 	if !single {
 		// 检查请求长度
-		if contentLength != rangeLength {
+		if contentLength != rangeLength+overlap {
 			wer.status.statusCode = StatusCodeNetError
-			wer.err = fmt.Errorf("Content-Length is unexpected: %d, need %d", contentLength, rangeLength)
+			wer.err = fmt.Errorf("Content-Length is unexpected: %d, need %d", contentLength, rangeLength+overlap)
 			return
 		}
 		// 检查总大小
@@ -396,12 +614,35 @@ func (wer *Worker) Execute() {
 		}
 	}
 
+	// 丢弃重叠部分的数据
+	if overlap > 0 {
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, overlap); err != nil {
+			if wer.TimedOut() {
+				wer.status.statusCode = StatusCodeWorkerTimeout
+				wer.err = ErrWorkerTimeout
+				return
+			}
+			wer.status.statusCode = StatusCodeNetError
+			wer.err = fmt.Errorf("discard overlapping bytes error: %s", err)
+			return
+		}
+	}
+
+	if idleTimer != nil {
+		// 响应头已经收到, 之后只在读取数据时计时
+		idleTimer.Stop()
+	}
+
 	var (
 		buf       = cachepool.SyncPool.Get().([]byte)
 		n, nn     int
 		n64, nn64 int64
+		body      io.Reader = resp.Body
 	)
 	defer cachepool.SyncPool.Put(buf)
+	if wer.progressReader != nil {
+		body = wer.progressReader.Wrap(resp.Body)
+	}
 
 	for {
 		select {
@@ -422,8 +663,30 @@ func (wer *Worker) Execute() {
 
 			// 读取数据
 			for n < len(buf) && readErr == nil && (single || wer.wrange.Len() > 0) {
-				nn, readErr = resp.Body.Read(buf[n:])
+				readBuf := buf[n:]
+				if wer.rateLimit != nil {
+					// 单个worker限速, 先获取令牌再读取数据
+					if int64(len(readBuf)) > wer.rateLimit.Burst() {
+						readBuf = readBuf[:wer.rateLimit.Burst()]
+					}
+					if wer.rateLimit.WaitN(workerCancelCtx, int64(len(readBuf))) != nil {
+						break // 已取消
+					}
+				}
+				if idleTimer != nil {
+					idleTimer.Reset(wer.requestTimeout)
+				}
+				nn, readErr = body.Read(readBuf)
+				if idleTimer != nil {
+					idleTimer.Stop()
+				}
 				nn64 = int64(nn)
+				if wer.rateLimit != nil {
+					wer.rateLimit.Refund(int64(len(readBuf) - nn))
+				}
+				if nn > 0 && timeoutTimer != nil {
+					timeoutTimer.Reset(wer.timeout)
+				}
 
 				// 更新速度统计
 				if wer.downloadStatus != nil {
@@ -507,6 +770,11 @@ func (wer *Worker) Execute() {
 						logger.Verbosef("DEBUG: RangeLen is negative at end: %v, %d\n", wer.wrange, wer.wrange.Len())
 					}
 					return
+				case wer.TimedOut():
+					// 超时, 由monitor将剩余的range分配给新的worker
+					wer.status.statusCode = StatusCodeWorkerTimeout
+					wer.err = ErrWorkerTimeout
+					return
 				default:
 					// 其他错误, 返回
 					wer.status.statusCode = StatusCodeFailed
@@ -29,8 +29,32 @@ type (
 	Writer interface {
 		io.WriterAt
 	}
+
+	// Stater 获取文件信息接口
+	Stater interface {
+		Stat() (os.FileInfo, error)
+	}
 )
 
+// AllocSparseFile 在 size-1 位置写入一个零字节, 使文件达到指定大小, 中间没有写入的部分由文件系统按照稀疏文件处理.
+// 文件已经达到指定大小时不写入, 避免覆盖断点续传已经下载的数据
+func AllocSparseFile(writer io.WriterAt, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	if stater, ok := writer.(Stater); ok {
+		info, err := stater.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() >= size {
+			return nil
+		}
+	}
+	_, err := writer.WriteAt([]byte{0}, size-1)
+	return err
+}
+
 // NewDownloaderWriterByFilename 创建下载器数据输出接口, 类似于os.OpenFile
 func NewDownloaderWriterByFilename(name string, flag int, perm os.FileMode) (writer Writer, file *os.File, err error) {
 	if runtime.GOOS == "windows" {
@@ -68,6 +68,7 @@ type (
 		Parallel  int   // 上传并发量
 		BlockSize int64 // 上传分块
 		MaxRate   int64 // 限制最大上传速度
+		ReadAhead int   // 预读分片数量, 0表示不预读
 	}
 )
 
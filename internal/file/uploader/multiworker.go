@@ -29,6 +29,7 @@ type (
 		partOffset int64
 		splitUnit  SplitUnit
 		uploadDone bool
+		readAhead  bool // 已经从预读通道中取过分片数据
 	}
 
 	workerList []*worker
@@ -49,7 +50,9 @@ func (muer *MultiUploader) upload() (uperr error) {
 	}
 
 	var (
-		uploadDeque = lane.NewDeque()
+		uploadDeque   = lane.NewDeque()
+		pendingList   = make([]*worker, 0, len(muer.workers))
+		readAheadChan <-chan *readAheadPart
 	)
 
 	// 加入队列
@@ -58,9 +61,17 @@ func (muer *MultiUploader) upload() (uperr error) {
 	for _, wer := range muer.workers {
 		if !wer.uploadDone {
 			uploadDeque.Append(wer)
+			pendingList = append(pendingList, wer)
 		}
 	}
 
+	// 预读后续分片到内存, 隐藏磁盘读取的延迟
+	if muer.config.ReadAhead > 0 && len(pendingList) > 1 {
+		readAheadStop := make(chan struct{})
+		defer close(readAheadStop)
+		readAheadChan = muer.startReadAhead(pendingList, muer.config.ReadAhead, readAheadStop)
+	}
+
 	// 上传客户端
 	uploadClient := requester.NewHTTPClient()
 	uploadClient.SetTimeout(0)
@@ -78,6 +89,7 @@ func (muer *MultiUploader) upload() (uperr error) {
 		}
 
 		wer := e.(*worker)
+		muer.takeReadAhead(readAheadChan, wer)
 		go func() { // 异步上传
 			defer wg.Done()
 
@@ -131,6 +143,9 @@ func (muer *MultiUploader) upload() (uperr error) {
 				return
 			}
 			wer.uploadDone = uploadDone
+			if mb, ok := wer.splitUnit.(*memoryBlock); ok && uploadDone {
+				mb.release()
+			}
 
 			// 通知更新
 			if muer.updateInstanceStateChan != nil && len(muer.updateInstanceStateChan) < cap(muer.updateInstanceStateChan) {
@@ -0,0 +1,171 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package uploader
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan/library/requester/transfer"
+	"github.com/tickstep/library-go/logger"
+	"github.com/tickstep/library-go/requester/rio/speeds"
+	"io"
+	"os"
+	"sync"
+)
+
+type (
+	// memoryBlock 预读到内存中的分片, 实现SplitUnit接口
+	memoryBlock struct {
+		readRange           transfer.Range
+		readed              int64
+		data                []byte
+		speedsStatRef       *speeds.Speeds
+		globalSpeedsStatRef *speeds.Speeds
+		rateLimit           *speeds.RateLimit
+		mu                  sync.Mutex
+	}
+
+	// readAheadPart 预读的分片数据
+	readAheadPart struct {
+		id   int
+		unit SplitUnit
+		err  error
+	}
+)
+
+// NewMemorySplitUnit 将已读取到内存的分片数据实现SplitUnit接口
+func NewMemorySplitUnit(data []byte, readRange transfer.Range, speedsStat *speeds.Speeds, rateLimit *speeds.RateLimit, globalSpeedsStat *speeds.Speeds) SplitUnit {
+	return &memoryBlock{
+		readRange:           readRange,
+		data:                data,
+		speedsStatRef:       speedsStat,
+		globalSpeedsStatRef: globalSpeedsStat,
+		rateLimit:           rateLimit,
+	}
+}
+
+func (mb *memoryBlock) Read(b []byte) (n int, err error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.readed >= int64(len(mb.data)) {
+		return 0, io.EOF
+	}
+	n = copy(b, mb.data[mb.readed:])
+
+	n64 := int64(n)
+	mb.readed += n64
+	if mb.rateLimit != nil {
+		mb.rateLimit.Add(n64) // 限速阻塞
+	}
+	if mb.speedsStatRef != nil {
+		mb.speedsStatRef.Add(n64)
+	}
+	if mb.globalSpeedsStatRef != nil {
+		mb.globalSpeedsStatRef.Add(n64)
+	}
+	return
+}
+
+func (mb *memoryBlock) Seek(offset int64, whence int) (int64, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	switch whence {
+	case os.SEEK_SET:
+		mb.readed = offset
+	case os.SEEK_CUR:
+		mb.readed += offset
+	case os.SEEK_END:
+		mb.readed = mb.Len() + offset
+	default:
+		return 0, fmt.Errorf("unsupport whence: %d", whence)
+	}
+	if mb.readed < 0 {
+		mb.readed = 0
+	}
+	return mb.readed, nil
+}
+
+// release 分片上传完成后释放内存, 保留已读取的统计
+func (mb *memoryBlock) release() {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.data = nil
+}
+
+func (mb *memoryBlock) Len() int64 {
+	return mb.readRange.End - mb.readRange.Begin
+}
+
+func (mb *memoryBlock) Left() int64 {
+	return mb.Len() - mb.readed
+}
+
+func (mb *memoryBlock) Range() transfer.Range {
+	return mb.readRange
+}
+
+func (mb *memoryBlock) Readed() int64 {
+	return mb.readed
+}
+
+// startReadAhead 按上传顺序预读分片到内存, 通道中最多缓存 readAhead 个分片.
+// 通道关闭表示没有更多的预读分片
+func (muer *MultiUploader) startReadAhead(workers []*worker, readAhead int, stop <-chan struct{}) <-chan *readAheadPart {
+	partChan := make(chan *readAheadPart, readAhead)
+	go func() {
+		defer close(partChan)
+		for _, wer := range workers {
+			r := wer.splitUnit.Range()
+			part := &readAheadPart{
+				id: wer.id,
+			}
+			data := make([]byte, r.End-r.Begin)
+			n, err := muer.file.ReadAt(data, r.Begin)
+			if err != nil && !(err == io.EOF && int64(n) == r.End-r.Begin) {
+				part.err = err
+			} else {
+				part.unit = NewMemorySplitUnit(data, r, muer.speedsStat, muer.rateLimit, muer.globalSpeedsStat)
+			}
+			select {
+			case partChan <- part:
+			case <-stop:
+				return
+			case <-muer.canceled:
+				return
+			}
+		}
+	}()
+	return partChan
+}
+
+// takeReadAhead 从预读通道中取出 wer 对应的分片, 预读失败时仍然使用磁盘读取
+func (muer *MultiUploader) takeReadAhead(partChan <-chan *readAheadPart, wer *worker) {
+	if partChan == nil || wer.readAhead {
+		// 重试的分片不再重复预读
+		return
+	}
+	wer.readAhead = true
+	for part := range partChan {
+		if part.id != wer.id {
+			continue
+		}
+		if part.err != nil {
+			logger.Verbosef("read ahead part %d failed: %s, fallback to disk read\n", wer.id, part.err)
+			return
+		}
+		wer.splitUnit = part.unit
+		return
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package uploader_test
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan/internal/file/uploader"
+	"github.com/tickstep/library-go/requester"
+	"github.com/tickstep/library-go/requester/rio"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type (
+	// slowReaderAt 模拟机械硬盘的读取延迟
+	slowReaderAt struct {
+		size  int64
+		delay time.Duration
+	}
+
+	// fakeMultiUpload 模拟网络上传, 每个分片固定耗时
+	fakeMultiUpload struct {
+		delay time.Duration
+	}
+)
+
+func (sr *slowReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off >= sr.size {
+		return 0, io.EOF
+	}
+	time.Sleep(sr.delay)
+	left := sr.size - off
+	if int64(len(p)) > left {
+		return int(left), io.EOF
+	}
+	return len(p), nil
+}
+
+func (sr *slowReaderAt) Len() int64 {
+	return sr.size
+}
+
+func (fmu *fakeMultiUpload) Precreate() error {
+	return nil
+}
+
+func (fmu *fakeMultiUpload) UploadFile(ctx context.Context, partseq int, partOffset int64, partEnd int64, readerlen64 rio.ReaderLen64, uploadClient *requester.HTTPClient) (bool, error) {
+	if _, err := io.Copy(ioutil.Discard, readerlen64); err != nil {
+		return false, err
+	}
+	time.Sleep(fmu.delay)
+	return true, nil
+}
+
+func (fmu *fakeMultiUpload) CommitFile() error {
+	return nil
+}
+
+func benchmarkUpload(b *testing.B, readAhead int) {
+	var (
+		blockSize int64 = 256 * 1024
+		fileSize        = blockSize * 16
+	)
+	for i := 0; i < b.N; i++ {
+		muer := uploader.NewMultiUploader(&fakeMultiUpload{delay: 2 * time.Millisecond},
+			&slowReaderAt{size: fileSize, delay: 100 * time.Microsecond},
+			&uploader.MultiUploaderConfig{
+				Parallel:  1,
+				BlockSize: blockSize,
+				ReadAhead: readAhead,
+			}, &aliyunpan.CreateFileUploadResult{}, nil)
+		if err := muer.Execute(); err != nil {
+			b.Fatalf("upload error: %s\n", err)
+		}
+	}
+	b.SetBytes(fileSize)
+}
+
+func BenchmarkUploadWithoutReadAhead(b *testing.B) {
+	benchmarkUpload(b, 0)
+}
+
+func BenchmarkUploadReadAhead(b *testing.B) {
+	benchmarkUpload(b, 4)
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package pandownload
+
+import (
+	"github.com/tickstep/aliyunpan/library/crypto"
+	"os"
+)
+
+const (
+	// DecryptSuffix 文件解密中的临时文件后缀
+	DecryptSuffix = ".aliyunpan-decrypting"
+)
+
+// DecryptFile 解密使用 upload -encrypt 上传的文件，加密参数保存在文件头部
+func DecryptFile(savePath, passphrase string) error {
+	cipherFile, err := os.Open(savePath)
+	if err != nil {
+		return err
+	}
+	defer cipherFile.Close()
+
+	params, err := crypto.ReadGCMStreamHeader(cipherFile)
+	if err == crypto.ErrGCMStreamHeaderNotFound {
+		return ErrDecryptParamsNotFound
+	} else if err != nil {
+		return err
+	}
+
+	info, err := cipherFile.Stat()
+	if err != nil {
+		return err
+	}
+	tmpFilePath := savePath + DecryptSuffix
+	plainFile, err := os.OpenFile(tmpFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	err = crypto.GCMStreamDecrypt(plainFile, cipherFile, passphrase, params)
+	if er := plainFile.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		os.Remove(tmpFilePath)
+		return err
+	}
+	cipherFile.Close()
+	return os.Rename(tmpFilePath, savePath)
+}
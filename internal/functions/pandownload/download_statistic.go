@@ -15,10 +15,22 @@ package pandownload
 
 import (
 	"github.com/tickstep/aliyunpan/internal/functions"
+	"sync/atomic"
 )
 
 type (
 	DownloadStatistic struct {
+		excludedCount int64 // 放在第一个字段, 保证32位平台上原子操作的64位对齐
 		functions.Statistic
 	}
 )
+
+// AddExcludedCount 增加被排除的文件数量
+func (s *DownloadStatistic) AddExcludedCount(n int64) int64 {
+	return atomic.AddInt64(&s.excludedCount, n)
+}
+
+// ExcludedCount 被排除的文件数量
+func (s *DownloadStatistic) ExcludedCount() int64 {
+	return atomic.LoadInt64(&s.excludedCount)
+}
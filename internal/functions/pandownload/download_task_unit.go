@@ -21,6 +21,7 @@ import (
 	"github.com/tickstep/aliyunpan/internal/config"
 	"github.com/tickstep/aliyunpan/internal/file/downloader"
 	"github.com/tickstep/aliyunpan/internal/functions"
+	"github.com/tickstep/aliyunpan/internal/functions/pantag"
 	"github.com/tickstep/aliyunpan/internal/localfile"
 	"github.com/tickstep/aliyunpan/internal/log"
 	"github.com/tickstep/aliyunpan/internal/plugins"
@@ -66,10 +67,29 @@ type (
 		OriginSaveRootPath string // 文件保存在本地的根目录路径
 		DriveId            string
 
+		// 平铺保存模式的保存路径登记表, 为空代表保留网盘的目录结构
+		FlatSavePaths *FlatSavePathRegistry
+
+		// 按照文件修改日期保存到 YYYY/MM/DD 子目录
+		OutputDirPerDate bool
+		dateDirResolved  bool // 保存路径是否已经加上日期子目录, 重试时不再重复添加
+
+		// 解密密码，不为空则下载完成后解密 upload -encrypt 上传的文件
+		DecryptPassphrase string
+
+		// 本地标签数据库，下载完成后将保存的扩展属性写回本地文件
+		TagDatabase *pantag.TagDatabase
+
 		fileInfo *aliyunpan.FileEntity // 文件或目录详情
 
 		// 下载文件记录器
 		FileRecorder *log.FileRecorder
+
+		// 下载失败记录器, 为nil则不记录
+		ErrorRecorder *log.FileRecorder
+
+		// 未完成下载的登记文件, 为nil则不登记
+		Registry *DownloadRegistry
 	}
 )
 
@@ -110,8 +130,9 @@ func (dtu *DownloadTaskUnit) verboseInfof(format string, a ...interface{}) {
 // download 执行下载文件（非目录）
 func (dtu *DownloadTaskUnit) download() (err error) {
 	var (
-		writer downloader.Writer
-		file   *os.File
+		writer      downloader.Writer
+		file        *os.File
+		splitWriter *downloader.SplitFileWriter
 	)
 
 	// 创建下载的目录
@@ -164,15 +185,28 @@ func (dtu *DownloadTaskUnit) download() (err error) {
 	dtu.Cfg.InstanceStatePath = savePathSymlinkFile.RealPath + DownloadSuffix
 
 	// 打开文件
-	writer, file, err = downloader.NewDownloaderWriterByFilename(savePathSymlinkFile.RealPath, os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		return fmt.Errorf("%s, %s", StrDownloadInitError, err)
+	if dtu.isSplitOutput() {
+		// 直接写入分块文件, 不生成完整的文件
+		splitWriter = downloader.NewSplitFileWriter(savePathSymlinkFile.RealPath, dtu.Cfg.SplitSize, 0666)
+		writer = splitWriter
+		defer splitWriter.Close()
+	} else {
+		writer, file, err = downloader.NewDownloaderWriterByFilename(savePathSymlinkFile.RealPath, os.O_CREATE|os.O_WRONLY, 0666)
+		if err != nil {
+			return fmt.Errorf("%s, %s", StrDownloadInitError, err)
+		}
+		defer file.Close()
 	}
-	defer file.Close()
 
 	der := downloader.NewDownloader(writer, dtu.Cfg, dtu.PanClient, dtu.GlobalSpeedsStat)
 	der.SetFileInfo(dtu.fileInfo)
 	der.SetDriveId(dtu.DriveId)
+	if dtu.Cfg.PerWorkerRateLimit && dtu.Cfg.MaxRate > 0 {
+		der.SetWorkerRateLimit(dtu.Cfg.MaxRate)
+	}
+	if dtu.Cfg.RetryPolicy != nil {
+		der.SetRetryPolicy(dtu.Cfg.RetryPolicy)
+	}
 	der.SetStatusCodeBodyCheckFunc(func(respBody io.Reader) error {
 		// 解析错误
 		return apierror.NewFailedApiError("")
@@ -183,9 +217,39 @@ func (dtu *DownloadTaskUnit) download() (err error) {
 		dtu.PrintFormat = DefaultPrintFormat
 	}
 
+	// 登记下载任务, 下载完成或者本地文件被删除后移除, 其他错误标记为暂停以便继续下载
+	if dtu.Registry != nil {
+		registryErr := dtu.Registry.Put(&DownloadRegistryEntry{
+			FileId:    dtu.fileInfo.FileId,
+			DriveId:   dtu.DriveId,
+			PanPath:   dtu.FilePanPath,
+			SavePath:  dtu.SavePath,
+			TotalSize: dtu.fileInfo.FileSize,
+			Status:    RegistryStatusDownloading,
+		})
+		if registryErr != nil {
+			dtu.verboseInfof("[%s] update download registry error: %s\n", dtu.taskInfo.Id(), registryErr)
+		}
+		defer func() {
+			_, checksumMismatch := err.(*downloader.ChecksumMismatchError)
+			if err == nil || err == downloader.ErrFileDownloadForbidden || checksumMismatch {
+				registryErr = dtu.Registry.Remove(dtu.SavePath)
+			} else {
+				registryErr = dtu.Registry.SetStatus(dtu.SavePath, RegistryStatusPaused)
+			}
+			if registryErr != nil {
+				dtu.verboseInfof("[%s] update download registry error: %s\n", dtu.taskInfo.Id(), registryErr)
+			}
+		}()
+	}
+
 	// 这里用共享变量的方式
 	isComplete := false
 	der.OnDownloadStatusEvent(func(status transfer.DownloadStatuser, workersCallback func(downloader.RangeWorkerFunc)) {
+		if dtu.Registry != nil {
+			dtu.Registry.UpdateProgress(dtu.SavePath, status.Downloaded())
+		}
+
 		// 这里可能会下载结束了, 还会输出内容
 		builder := &strings.Builder{}
 		if dtu.IsPrintStatus {
@@ -206,23 +270,21 @@ func (dtu *DownloadTaskUnit) download() (err error) {
 		}
 
 		// 如果下载速度为0, 剩余下载时间未知, 则用 - 代替
-		var leftStr string
-		left := status.TimeLeft()
-		if left < 0 {
-			leftStr = "-"
-		} else {
-			leftStr = left.String()
-		}
+		leftStr := downloader.FormatETA(status.TimeLeft(), dtu.Cfg.ETAFormat)
 
 		if dtu.Cfg.ShowProgress {
 			downloadedPercentage := fmt.Sprintf("%.2f%%", float64(status.Downloaded())/float64(status.TotalSize())*100)
-			fmt.Fprintf(builder, "\r[%s] ↓ %s/%s(%s) %s/s(%s/s) in %s, left %s ............", dtu.taskInfo.Id(),
+			leftLabel := "left"
+			if dtu.Cfg.ETAFormat == downloader.ETAFormatDatetime {
+				leftLabel = "ETA:"
+			}
+			fmt.Fprintf(builder, "\r[%s] ↓ %s/%s(%s) %s/s(%s/s) in %s, %s %s ............", dtu.taskInfo.Id(),
 				converter.ConvertFileSize(status.Downloaded(), 2),
 				converter.ConvertFileSize(status.TotalSize(), 2),
 				downloadedPercentage,
 				converter.ConvertFileSize(status.SpeedsPerSecond(), 2),
 				converter.ConvertFileSize(dtu.GlobalSpeedsStat.GetSpeeds(), 2),
-				status.TimeElapsed()/1e7*1e7, leftStr,
+				status.TimeElapsed()/1e7*1e7, leftLabel, leftStr,
 			)
 		}
 
@@ -246,15 +308,28 @@ func (dtu *DownloadTaskUnit) download() (err error) {
 			// 文件被禁止下载
 			isComplete = false
 			// 删除本地文件
-			removeErr := os.Remove(dtu.SavePath)
+			var removeErr error
+			if splitWriter != nil {
+				removeErr = splitWriter.Remove()
+			} else {
+				removeErr = os.Remove(dtu.SavePath)
+			}
 			if removeErr != nil {
 				dtu.verboseInfof("[%s] remove file error: %s\n", dtu.taskInfo.Id(), removeErr)
 			}
 			fmt.Printf("[%s] 下载失败，文件不合法或者被禁止下载: %s\n", dtu.taskInfo.Id(), dtu.SavePath)
 			return err
+		} else if _, ok := err.(*downloader.ChecksumMismatchError); ok {
+			// 文件校验失败, 本地文件已经删除, 重试时重新下载
+			isComplete = false
+			fmt.Printf("\n[%s] %s\n", dtu.taskInfo.Id(), err)
+			return err
 		} else {
 			// 下载发生错误
 			// 下载失败, 删去空文件
+			if file == nil {
+				return err
+			}
 			if info, infoErr := file.Stat(); infoErr == nil {
 				if info.Size() == 0 {
 					// 空文件, 应该删除
@@ -273,16 +348,29 @@ func (dtu *DownloadTaskUnit) download() (err error) {
 
 	// 下载成功
 	if dtu.IsExecutedPermission {
-		err = file.Chmod(0766)
+		if splitWriter != nil {
+			err = splitWriter.Chmod(0766)
+		} else {
+			err = file.Chmod(0766)
+		}
 		if err != nil {
 			fmt.Printf("[%s] 警告, 加执行权限错误: %s\n", dtu.taskInfo.Id(), err)
 		}
 	}
+	if splitWriter != nil {
+		fmt.Printf("\n[%s] 下载完成, 共 %d 个分块, 保存位置: %s\n", dtu.taskInfo.Id(), len(splitWriter.Parts()), downloader.SplitFilePartName(dtu.SavePath, 1))
+		return nil
+	}
 	fmt.Printf("\n[%s] 下载完成, 保存位置: %s\n", dtu.taskInfo.Id(), dtu.SavePath)
 
 	return nil
 }
 
+// isSplitOutput 是否直接下载为分块文件, 文件大小不超过分块大小时不分割
+func (dtu *DownloadTaskUnit) isSplitOutput() bool {
+	return dtu.Cfg.SplitSize > 0 && dtu.fileInfo.FileSize > dtu.Cfg.SplitSize
+}
+
 // handleError 下载错误处理器
 func (dtu *DownloadTaskUnit) handleError(result *taskframework.TaskUnitRunResult) {
 	switch value := result.Err.(type) {
@@ -301,6 +389,9 @@ func (dtu *DownloadTaskUnit) handleError(result *taskframework.TaskUnitRunResult
 	default:
 		if result.Err == downloader.ErrFileDownloadForbidden {
 			result.NeedRetry = false
+		} else if result.Err == downloader.ErrDownloadTooSlow {
+			// 速度过慢被取消, 不再重试, 避免阻塞后面的下载. 断点信息保留, 可以稍后继续下载
+			result.NeedRetry = false
 		} else {
 			// 其他错误, 尝试重试
 			result.NeedRetry = true
@@ -309,6 +400,19 @@ func (dtu *DownloadTaskUnit) handleError(result *taskframework.TaskUnitRunResult
 	time.Sleep(1 * time.Second)
 }
 
+// canFallbackSingleThread 下载错误是否可以使用单线程重新下载
+func (dtu *DownloadTaskUnit) canFallbackSingleThread(err error) bool {
+	if err == downloader.ErrFileDownloadForbidden || err == downloader.ErrDownloadInterrupted || err == downloader.ErrDownloadTooSlow {
+		// 速度过慢取消的文件保留断点, 稍后重新下载续传
+		return false
+	}
+	if _, ok := err.(*os.PathError); ok {
+		// 本地文件错误, 单线程也无法下载
+		return false
+	}
+	return true
+}
+
 // checkFileValid 检测文件有效性
 func (dtu *DownloadTaskUnit) checkFileValid(result *taskframework.TaskUnitRunResult) (ok bool) {
 	if dtu.NoCheck {
@@ -366,6 +470,7 @@ func (dtu *DownloadTaskUnit) OnRetry(lastRunResult *taskframework.TaskUnitRunRes
 func (dtu *DownloadTaskUnit) OnSuccess(lastRunResult *taskframework.TaskUnitRunResult) {
 	// 执行插件
 	dtu.pluginCallback("success")
+	dtu.notifySound()
 
 	// 下载文件数据记录
 	if config.Config.FileRecordConfig == "1" {
@@ -385,6 +490,25 @@ func (dtu *DownloadTaskUnit) OnSuccess(lastRunResult *taskframework.TaskUnitRunR
 func (dtu *DownloadTaskUnit) OnFailed(lastRunResult *taskframework.TaskUnitRunResult) {
 	// 失败
 	dtu.pluginCallback("fail")
+	dtu.notifySound()
+
+	// 下载失败记录
+	if dtu.ErrorRecorder != nil {
+		item := &log.FileRecordItem{
+			Status:   "失败: " + lastRunResult.ResultMessage,
+			TimeStr:  utils.NowTimeStr(),
+			FilePath: dtu.FilePanPath,
+		}
+		if lastRunResult.Err != nil {
+			item.Status += ", " + lastRunResult.Err.Error()
+		}
+		if dtu.fileInfo != nil {
+			item.FileSize = dtu.fileInfo.FileSize
+		}
+		if err := dtu.ErrorRecorder.Append(item); err != nil {
+			logger.Verbosef("save download error record error: %s\n", err)
+		}
+	}
 
 	// 失败
 	if lastRunResult.Err == nil {
@@ -395,6 +519,14 @@ func (dtu *DownloadTaskUnit) OnFailed(lastRunResult *taskframework.TaskUnitRunRe
 	fmt.Printf("[%s] %s, %s\n", dtu.taskInfo.Id(), lastRunResult.ResultMessage, lastRunResult.Err)
 }
 
+// notifySound 开启 NotifyDoneSound 时播放提示音
+func (dtu *DownloadTaskUnit) notifySound() {
+	if dtu.Cfg == nil || !dtu.Cfg.NotifyDoneSound {
+		return
+	}
+	PlayNotifySound(dtu.Cfg.NotifySound)
+}
+
 func (dtu *DownloadTaskUnit) pluginCallback(result string) {
 	if dtu.fileInfo == nil {
 		return
@@ -495,10 +627,11 @@ func (dtu *DownloadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 		//	os.MkdirAll(dtu.SavePath, 0777) // 首先在本地创建目录, 保证空目录也能被保存
 		//}
 		// 支持本地符号逻辑文件，整体逻辑等效上面的注释代码
+		// 平铺保存模式、按日期保存模式不需要创建子目录
 		originSaveRootSymlinkFile := localfile.NewSymlinkFile(dtu.OriginSaveRootPath)
 		suffixPath := localfile.GetSuffixPath(dtu.SavePath, dtu.OriginSaveRootPath)
 		savePathSymlinkFile, _, err := localfile.RetrieveRealPathFromLogicSuffixPath(originSaveRootSymlinkFile, suffixPath)
-		if err != nil && !os.IsExist(err) {
+		if dtu.FlatSavePaths == nil && !dtu.OutputDirPerDate && err != nil && !os.IsExist(err) {
 			realSavePath := savePathSymlinkFile.RealPath
 			suffixPath = localfile.GetSuffixPath(dtu.SavePath, savePathSymlinkFile.LogicPath) // 获取后缀不存在的路径
 			if suffixPath != "" {
@@ -556,6 +689,12 @@ func (dtu *DownloadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 				fmt.Printf("排除文件: %s\n", fileList[k].Path)
 				continue
 			}
+			if !fileList[k].IsFolder() && utils.IsExcludeGlobFile(fileList[k].Path, dtu.Cfg.ExcludeGlobs) {
+				if dtu.DownloadStatistic != nil {
+					dtu.DownloadStatistic.AddExcludedCount(1)
+				}
+				continue
+			}
 
 			if fileList[k].IsFolder() {
 				logger.Verbosef("[%s] create sub folder download task: %s\n",
@@ -569,6 +708,9 @@ func (dtu *DownloadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 			subUnit.fileInfo = fileList[k] // 保存文件信息
 			subUnit.FilePanPath = fileList[k].Path
 			subUnit.SavePath = filepath.Join(dtu.OriginSaveRootPath, fileList[k].Path) // 保存位置
+			if dtu.FlatSavePaths != nil {
+				subUnit.SavePath = filepath.Join(dtu.OriginSaveRootPath, fileList[k].FileName)
+			}
 
 			// 加入父队列，按照队列调度进行下载
 			info := dtu.ParentTaskExecutor.Append(&subUnit, dtu.taskInfo.MaxRetry())
@@ -582,11 +724,36 @@ func (dtu *DownloadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 
 	fmt.Printf("[%s] 准备下载: %s\n", dtu.taskInfo.Id(), dtu.FilePanPath)
 
+	// 按照文件修改日期保存到子目录
+	if dtu.OutputDirPerDate && !dtu.dateDirResolved {
+		dtu.SavePath = DateSavePath(dtu.OriginSaveRootPath, dtu.SavePath, dtu.fileInfo.UpdatedAt)
+		dtu.dateDirResolved = true
+	}
+
+	// 平铺保存模式, 检测不同目录下的同名文件
+	if dtu.FlatSavePaths != nil {
+		savePath, skip := dtu.FlatSavePaths.Resolve(dtu.SavePath, dtu.FilePanPath)
+		if skip {
+			fmt.Printf("[%s] 文件与 %s 同名冲突, 跳过...\n", dtu.taskInfo.Id(), dtu.FlatSavePaths.ConflictPanPath(dtu.SavePath))
+			result.Succeed = true
+			return
+		}
+		if savePath != dtu.SavePath {
+			fmt.Printf("[%s] 文件同名冲突, 重命名保存为: %s\n", dtu.taskInfo.Id(), savePath)
+			dtu.SavePath = savePath
+		}
+	}
+
 	//if !dtu.IsOverwrite && FileExist(dtu.SavePath) {
 	//	fmt.Printf("[%s] 文件已经存在: %s, 跳过...\n", dtu.taskInfo.Id(), dtu.SavePath)
 	//	result.Succeed = true // 执行成功
 	//	return
 	//}
+	if !dtu.IsOverwrite && dtu.isSplitOutput() && SplitFileExist(dtu.SavePath, dtu.Cfg.SplitSize, dtu.fileInfo.FileSize) {
+		fmt.Printf("[%s] 分块文件已经存在: %s, 跳过...\n", dtu.taskInfo.Id(), downloader.SplitFilePartName(dtu.SavePath, 1))
+		result.Succeed = true // 执行成功
+		return
+	}
 	// 支持符号文件，逻辑和注释代码一致
 	if !dtu.IsOverwrite && SymlinkFileExist(dtu.SavePath, dtu.OriginSaveRootPath) {
 		fmt.Printf("[%s] 文件已经存在: %s, 跳过...\n", dtu.taskInfo.Id(), dtu.SavePath)
@@ -598,7 +765,23 @@ func (dtu *DownloadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 
 	var ok bool
 	er := dtu.download()
+	if er != nil && dtu.Cfg.FallbackSingleThread && !dtu.Cfg.SingleThread && dtu.canFallbackSingleThread(er) {
+		// 部分CDN节点不支持多个Range并发请求, 使用单线程重新下载整个文件
+		fmt.Printf("\n[%s] 多线程下载失败: %s, 使用单线程模式重新下载\n", dtu.taskInfo.Id(), er)
+		logger.Verbosef("[%s] fallback to single thread download: %s\n", dtu.taskInfo.Id(), dtu.FilePanPath)
+		dtu.Cfg.SingleThread = true
+		er = dtu.download()
+	}
 
+	if er == downloader.ErrDownloadInterrupted {
+		// 收到中断信号, 断点信息已保存, 不再开始队列中剩余的下载, 也不记为失败
+		if dtu.ParentTaskExecutor != nil {
+			dtu.ParentTaskExecutor.Stop()
+		}
+		result.Err = er
+		result.Cancel = true
+		return result
+	}
 	if er != nil {
 		// 以上执行不成功, 返回
 		result.ResultMessage = StrDownloadFailed
@@ -614,6 +797,32 @@ func (dtu *DownloadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 		return result
 	}
 
+	// 解密文件
+	if dtu.DecryptPassphrase != "" {
+		err := DecryptFile(dtu.SavePath, dtu.DecryptPassphrase)
+		if err == ErrDecryptParamsNotFound {
+			fmt.Printf("[%s] %s, 跳过解密: %s\n", dtu.taskInfo.Id(), err, dtu.SavePath)
+		} else if err != nil {
+			result.ResultMessage = "文件解密失败"
+			result.Err = err
+			result.NeedRetry = false
+			return result
+		} else {
+			fmt.Printf("[%s] 文件解密成功: %s\n", dtu.taskInfo.Id(), dtu.SavePath)
+		}
+	}
+
+	// 恢复文件扩展属性, 分块文件不是完整的文件, 不写入
+	if dtu.TagDatabase != nil && !dtu.isSplitOutput() {
+		if attrs := dtu.TagDatabase.Get(dtu.fileInfo.ContentHash); len(attrs) > 0 {
+			if err := pantag.WriteXattrs(dtu.SavePath, attrs); err != nil {
+				fmt.Printf("[%s] 写入文件扩展属性失败: %s\n", dtu.taskInfo.Id(), err)
+			} else {
+				logger.Verbosef("[%s] restore %d xattrs for %s\n", dtu.taskInfo.Id(), len(attrs), dtu.SavePath)
+			}
+		}
+	}
+
 	//// 文件下载成功，更改文件修改时间和云盘的同步
 	//if err := os.Chtimes(dtu.SavePath, utils.ParseTimeStr(dtu.fileInfo.CreatedAt), utils.ParseTimeStr(dtu.fileInfo.CreatedAt)); err != nil {
 	//	logger.Verbosef(err.Error())
@@ -26,4 +26,6 @@ var (
 	ErrDlinkNotFound = errors.New("未取得下载链接")
 	// ErrShareInfoNotFound 未在已分享列表中找到分享信息
 	ErrShareInfoNotFound = errors.New("未在已分享列表中找到分享信息")
+	// ErrDecryptParamsNotFound 网盘文件没有加密参数
+	ErrDecryptParamsNotFound = errors.New("该文件没有加密参数, 不是加密上传的文件")
 )
@@ -0,0 +1,20 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package pandownload
+
+// PlayNotifySound 播放下载结束的提示音, soundFile 为空则使用系统默认的提示音.
+// 找不到播放器或者播放失败时静默忽略, 不影响下载结果
+func PlayNotifySound(soundFile string) {
+	_ = playSound(soundFile)
+}
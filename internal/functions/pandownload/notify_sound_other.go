@@ -0,0 +1,47 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package pandownload
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+const (
+	// darwinDefaultSound macOS 默认提示音
+	darwinDefaultSound = "/System/Library/Sounds/Glass.aiff"
+	// linuxDefaultSound freedesktop 声音主题的默认提示音
+	linuxDefaultSound = "/usr/share/sounds/freedesktop/stereo/complete.oga"
+)
+
+// playSound macOS 使用 afplay, Linux 使用 PulseAudio 的 paplay 播放
+func playSound(soundFile string) error {
+	player, defaultSound := "paplay", linuxDefaultSound
+	if runtime.GOOS == "darwin" {
+		player, defaultSound = "afplay", darwinDefaultSound
+	}
+	playerPath, err := exec.LookPath(player)
+	if err != nil {
+		return errors.New("sound player not found: " + player)
+	}
+	if soundFile == "" {
+		soundFile = defaultSound
+	}
+	return exec.Command(playerPath, soundFile).Run()
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package pandownload
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sndSync      = 0x0000
+	sndAlias     = 0x00010000
+	sndFilename  = 0x00020000
+	sndNoDefault = 0x0002
+)
+
+var procPlaySound = syscall.NewLazyDLL("winmm.dll").NewProc("PlaySoundW")
+
+// playSound 调用 winmm.dll 的 PlaySoundW 播放, soundFile 为空则播放系统的 SystemAsterisk 提示音
+func playSound(soundFile string) error {
+	name, flags := "SystemAsterisk", uint32(sndAlias)
+	if soundFile != "" {
+		name, flags = soundFile, sndFilename
+	}
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if err = procPlaySound.Find(); err != nil {
+		return err
+	}
+	r, _, err := procPlaySound.Call(uintptr(unsafe.Pointer(p)), 0, uintptr(flags|sndSync|sndNoDefault))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package pandownload
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OutputStructurePreserve 在本地保留网盘的目录结构
+	OutputStructurePreserve = "preserve"
+	// OutputStructureFlat 所有文件直接保存到目标目录下
+	OutputStructureFlat = "flat"
+
+	// FlatConflictRename 平铺保存时同名文件自动重命名
+	FlatConflictRename = "rename"
+	// FlatConflictSkip 平铺保存时跳过后出现的同名文件
+	FlatConflictSkip = "skip"
+)
+
+type (
+	// FlatSavePathRegistry 平铺保存模式下的保存路径登记表, 用于检测不同目录下同名文件的冲突
+	FlatSavePathRegistry struct {
+		strategy  string
+		mu        sync.Mutex
+		savePaths map[string]string // 本地保存路径 -> 网盘文件路径
+		panPaths  map[string]string // 网盘文件路径 -> 本地保存路径
+	}
+)
+
+// NewFlatSavePathRegistry 创建登记表, strategy 为同名冲突的处理策略
+func NewFlatSavePathRegistry(strategy string) *FlatSavePathRegistry {
+	if strategy != FlatConflictSkip {
+		strategy = FlatConflictRename
+	}
+	return &FlatSavePathRegistry{
+		strategy:  strategy,
+		savePaths: map[string]string{},
+		panPaths:  map[string]string{},
+	}
+}
+
+// Resolve 登记网盘文件的保存路径, 返回最终使用的保存路径. skip 为 true 代表该文件和已登记的文件同名, 需要跳过
+func (r *FlatSavePathRegistry) Resolve(savePath, panPath string) (finalPath string, skip bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 同一个文件重试时使用相同的路径
+	if p, ok := r.panPaths[panPath]; ok {
+		return p, false
+	}
+
+	finalPath = savePath
+	if _, exist := r.savePaths[finalPath]; exist {
+		if r.strategy == FlatConflictSkip {
+			return savePath, true
+		}
+		ext := filepath.Ext(savePath)
+		base := strings.TrimSuffix(savePath, ext)
+		for i := 1; ; i++ {
+			finalPath = fmt.Sprintf("%s (%d)%s", base, i, ext)
+			if _, exist = r.savePaths[finalPath]; !exist {
+				break
+			}
+		}
+	}
+	r.savePaths[finalPath] = panPath
+	r.panPaths[panPath] = finalPath
+	return finalPath, false
+}
+
+// DateSavePath 在保存根目录下插入文件修改日期的 YYYY/MM/DD 子目录, 日期无法解析时返回原路径
+func DateSavePath(originSaveRootPath, savePath, updatedAt string) string {
+	cz := time.FixedZone("CST", 8*3600) // 东8区
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", updatedAt, cz)
+	if err != nil {
+		return savePath
+	}
+	relPath, err := filepath.Rel(originSaveRootPath, savePath)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		relPath = filepath.Base(savePath)
+	}
+	return filepath.Join(originSaveRootPath, t.Format("2006"), t.Format("01"), t.Format("02"), relPath)
+}
+
+// ConflictPanPath 返回占用该保存路径的网盘文件路径
+func (r *FlatSavePathRegistry) ConflictPanPath(savePath string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.savePaths[savePath]
+}
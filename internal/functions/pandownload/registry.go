@@ -0,0 +1,172 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package pandownload
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// RegistryStatusDownloading 正在下载
+	RegistryStatusDownloading = "downloading"
+	// RegistryStatusPaused 下载失败或者中断, 可以继续下载
+	RegistryStatusPaused = "paused"
+
+	// registrySaveInterval 下载进度写入登记文件的最小间隔
+	registrySaveInterval = 5 * time.Second
+)
+
+type (
+	// DownloadRegistryEntry 登记的下载任务
+	DownloadRegistryEntry struct {
+		FileId     string `json:"fileId"`
+		DriveId    string `json:"driveId"`
+		PanPath    string `json:"panPath"`
+		SavePath   string `json:"savePath"`
+		TotalSize  int64  `json:"totalSize"`
+		Downloaded int64  `json:"downloaded"`
+		Status     string `json:"status"`
+		UpdatedAt  string `json:"updatedAt"`
+	}
+
+	// DownloadRegistry 所有未完成下载的登记文件, 记录各个下载任务的进度, 用于查看和继续下载.
+	// 下载完成或者取消后从登记文件中移除
+	DownloadRegistry struct {
+		path     string
+		mu       sync.Mutex
+		entries  map[string]*DownloadRegistryEntry // 本地保存路径 -> 下载任务
+		lastSave time.Time
+	}
+)
+
+// NewDownloadRegistry 打开登记文件, 文件不存在时创建空的登记表
+func NewDownloadRegistry(path string) (*DownloadRegistry, error) {
+	r := &DownloadRegistry{
+		path:    path,
+		entries: map[string]*DownloadRegistryEntry{},
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	var list []*DownloadRegistryEntry
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range list {
+		r.entries[entry.SavePath] = entry
+	}
+	return r, nil
+}
+
+// Entries 登记的下载任务, 按照更新时间倒序排列
+func (r *DownloadRegistry) Entries() []*DownloadRegistryEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]*DownloadRegistryEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		e := *entry
+		list = append(list, &e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UpdatedAt > list[j].UpdatedAt
+	})
+	return list
+}
+
+// Put 登记下载任务, 相同保存路径的任务会被覆盖
+func (r *DownloadRegistry) Put(entry *DownloadRegistryEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := *entry
+	e.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	r.entries[e.SavePath] = &e
+	return r.save()
+}
+
+// UpdateProgress 更新下载任务的已下载大小, 为了减少磁盘写入, 间隔 registrySaveInterval 才写入登记文件
+func (r *DownloadRegistry) UpdateProgress(savePath string, downloaded int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[savePath]
+	if !ok {
+		return nil
+	}
+	entry.Downloaded = downloaded
+	entry.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	if time.Since(r.lastSave) < registrySaveInterval {
+		return nil
+	}
+	return r.save()
+}
+
+// SetStatus 更新下载任务的状态
+func (r *DownloadRegistry) SetStatus(savePath, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[savePath]
+	if !ok {
+		return nil
+	}
+	entry.Status = status
+	entry.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	return r.save()
+}
+
+// Remove 移除下载任务
+func (r *DownloadRegistry) Remove(savePath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[savePath]; !ok {
+		return nil
+	}
+	delete(r.entries, savePath)
+	return r.save()
+}
+
+// save 写入登记文件, 调用前需要加锁
+func (r *DownloadRegistry) save() error {
+	list := make([]*DownloadRegistryEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].SavePath < list[j].SavePath
+	})
+	data, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	// 先写入临时文件再重命名, 避免程序中断时登记文件损坏
+	tmpPath := r.path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	r.lastSave = time.Now()
+	return os.Rename(tmpPath, r.path)
+}
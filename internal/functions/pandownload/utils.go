@@ -15,6 +15,7 @@ package pandownload
 
 import (
 	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan/internal/file/downloader"
 	"github.com/tickstep/aliyunpan/internal/localfile"
 	"os"
 )
@@ -43,6 +44,17 @@ func FileExist(path string) bool {
 	return false
 }
 
+// SplitFileExist 检查文件是否已经下载为分块文件
+//
+// 只有当所有分块文件存在并且大小正确, 断点续传文件不存在时, 才判断为存在
+func SplitFileExist(path string, splitSize, totalSize int64) bool {
+	if !downloader.SplitFilePartsExist(path, splitSize, totalSize) {
+		return false
+	}
+	_, err := os.Stat(path + DownloadSuffix)
+	return err != nil
+}
+
 // SymlinkFileExist 检查文件是否存在
 //
 // 逻辑和 FileExist 一致，增加符号链接文件的支持
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package pantag
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/library-go/converter"
+	"github.com/tickstep/library-go/jsonhelper"
+)
+
+const (
+	// TagDatabaseFileName 本地标签数据库文件名
+	TagDatabaseFileName = "tag_database.json"
+)
+
+type (
+	// TagDatabase 本地标签数据库，以网盘文件的 ContentHash(SHA1) 为key保存文件的扩展属性
+	TagDatabase struct {
+		// Tags ContentHash => 扩展属性名称 => base64编码的属性值
+		Tags      map[string]map[string]string `json:"tags"`
+		Timestamp int64                        `json:"timestamp"`
+
+		dataFile *os.File
+	}
+)
+
+// TagDatabasePath 获取本地标签数据库文件路径
+func TagDatabasePath() string {
+	return filepath.Join(config.GetConfigDir(), TagDatabaseFileName)
+}
+
+// NewTagDatabase 打开本地标签数据库, 从库中读取内容
+func NewTagDatabase() (td *TagDatabase, err error) {
+	file, err := os.OpenFile(TagDatabasePath(), os.O_CREATE|os.O_RDWR, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	td = &TagDatabase{
+		Tags:     map[string]map[string]string{},
+		dataFile: file,
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() <= 0 {
+		return td, nil
+	}
+
+	err = jsonhelper.UnmarshalData(file, td)
+	if err != nil {
+		return nil, err
+	}
+	if td.Tags == nil {
+		td.Tags = map[string]map[string]string{}
+	}
+	return td, nil
+}
+
+// Get 获取文件的扩展属性
+func (td *TagDatabase) Get(contentHash string) map[string][]byte {
+	item, ok := td.Tags[strings.ToUpper(contentHash)]
+	if !ok {
+		return nil
+	}
+	attrs := map[string][]byte{}
+	for name, value := range item {
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		attrs[name] = data
+	}
+	return attrs
+}
+
+// Set 保存文件的扩展属性，属性为空则删除该文件的记录
+func (td *TagDatabase) Set(contentHash string, attrs map[string][]byte) {
+	contentHash = strings.ToUpper(contentHash)
+	if len(attrs) == 0 {
+		delete(td.Tags, contentHash)
+		return
+	}
+	item := map[string]string{}
+	for name, value := range attrs {
+		item[name] = base64.StdEncoding.EncodeToString(value)
+	}
+	td.Tags[contentHash] = item
+}
+
+// Save 保存内容
+func (td *TagDatabase) Save() error {
+	if td.dataFile == nil {
+		return errors.New("dataFile is nil")
+	}
+
+	td.Timestamp = time.Now().Unix()
+
+	var (
+		builder = &strings.Builder{}
+		err     = jsonhelper.MarshalData(builder, td)
+	)
+	if err != nil {
+		return err
+	}
+
+	err = td.dataFile.Truncate(int64(builder.Len()))
+	if err != nil {
+		return err
+	}
+
+	_, err = td.dataFile.WriteAt(converter.ToBytes(builder.String()), 0)
+	return err
+}
+
+// Close 关闭数据库
+func (td *TagDatabase) Close() error {
+	if td.dataFile == nil {
+		return nil
+	}
+	return td.dataFile.Close()
+}
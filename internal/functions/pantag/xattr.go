@@ -0,0 +1,67 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package pantag
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// DefaultXattrNamespaces 默认同步的扩展属性，即 macOS Finder 的标签
+	DefaultXattrNamespaces = []string{"com.apple.metadata:kMDItemUserTags"}
+
+	// ErrXattrNotSupported 当前系统不支持扩展属性
+	ErrXattrNotSupported = errors.New("当前系统不支持文件扩展属性")
+)
+
+// matchNamespace 扩展属性名称是否匹配指定的命名空间，命名空间为属性名称或者属性名称前缀
+func matchNamespace(name string, namespaces []string) bool {
+	for _, ns := range namespaces {
+		if name == ns || strings.HasPrefix(name, ns) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadXattrs 读取本地文件匹配命名空间的扩展属性
+func ReadXattrs(filePath string, namespaces []string) (map[string][]byte, error) {
+	names, err := listXattr(filePath)
+	if err != nil {
+		return nil, err
+	}
+	attrs := map[string][]byte{}
+	for _, name := range names {
+		if !matchNamespace(name, namespaces) {
+			continue
+		}
+		value, err := getXattr(filePath, name)
+		if err != nil {
+			return nil, err
+		}
+		attrs[name] = value
+	}
+	return attrs, nil
+}
+
+// WriteXattrs 将扩展属性写入本地文件
+func WriteXattrs(filePath string, attrs map[string][]byte) error {
+	for name, value := range attrs {
+		if err := setXattr(filePath, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || linux
+// +build darwin linux
+
+package pantag
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+func listXattr(filePath string) ([]string, error) {
+	size, err := unix.Listxattr(filePath, nil)
+	if err != nil || size <= 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = unix.Listxattr(filePath, buf)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+func getXattr(filePath, name string) ([]byte, error) {
+	size, err := unix.Getxattr(filePath, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = unix.Getxattr(filePath, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
+
+func setXattr(filePath, name string, value []byte) error {
+	return unix.Setxattr(filePath, name, value, 0)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package panupload
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan/internal/config"
+	"github.com/tickstep/library-go/converter"
+	"github.com/tickstep/library-go/logger"
+	"github.com/tickstep/library-go/requester"
+)
+
+const (
+	// BandwidthProbeSize 测速上传的数据大小
+	BandwidthProbeSize = 1 * converter.MB
+	// AutoBlockDuration 自动分片大小时，期望每个分片上传的耗时
+	AutoBlockDuration = 30 * time.Second
+)
+
+// MeasureUploadBandwidth 上传 1MB 测试数据到网盘根目录测量上传带宽，单位 B/s。
+// 测试文件不会确认上传完成，网盘中不会出现该文件
+func MeasureUploadBandwidth(panClient *config.PanClient, driveId string) (int64, error) {
+	createResult, apierr := panClient.OpenapiPanClient().CreateUploadFile(&aliyunpan.CreateFileUploadParam{
+		DriveId:       driveId,
+		Name:          ".aliyunpan_bandwidth_probe_" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		Size:          BandwidthProbeSize,
+		CheckNameMode: "auto_rename",
+		ParentFileId:  aliyunpan.DefaultRootParentFileId,
+		BlockSize:     BandwidthProbeSize,
+	})
+	if apierr != nil {
+		return 0, apierr
+	}
+	defer func() {
+		// 清理未完成的测试文件，失败不影响测速结果
+		panClient.OpenapiPanClient().FileDelete(&aliyunpan.FileBatchActionParam{DriveId: driveId, FileId: createResult.FileId})
+	}()
+	if len(createResult.PartInfoList) == 0 {
+		return 0, fmt.Errorf("获取测速上传链接失败")
+	}
+
+	var elapsed time.Duration
+	apierr = panClient.OpenapiPanClient().UploadFileData(createResult.PartInfoList[0].UploadURL, func(httpMethod, fullUrl string, headers map[string]string) (*http.Response, error) {
+		client := requester.NewHTTPClient()
+		client.SetTimeout(0)
+		start := time.Now()
+		resp, err := client.Req(httpMethod, fullUrl, bytes.NewReader(make([]byte, BandwidthProbeSize)), headers)
+		elapsed = time.Since(start)
+		return resp, err
+	})
+	if apierr != nil {
+		return 0, apierr
+	}
+	if elapsed <= 0 {
+		elapsed = time.Millisecond
+	}
+	bandwidth := int64(float64(BandwidthProbeSize) / elapsed.Seconds())
+	logger.Verbosef("upload bandwidth probe: %s in %s\n", converter.ConvertFileSize(BandwidthProbeSize, 2), elapsed)
+	return bandwidth, nil
+}
+
+// AutoUploadBlockSize 根据上传带宽计算分片大小，使每个分片上传耗时约为 AutoBlockDuration。
+// 分片大小按 MB 对齐，限制在 MinUploadBlockSize ~ MaxUploadBlockSize 之间，且不超过文件本身大小所需
+func AutoUploadBlockSize(fileSize, bandwidth int64) int64 {
+	blockSize := int64(float64(bandwidth) * AutoBlockDuration.Seconds())
+	blockSize = blockSize / converter.MB * converter.MB
+	if fileSize > 0 && blockSize > fileSize {
+		blockSize = (fileSize + converter.MB - 1) / converter.MB * converter.MB
+	}
+	if blockSize < MinUploadBlockSize {
+		return MinUploadBlockSize
+	}
+	if blockSize > MaxUploadBlockSize {
+		return MaxUploadBlockSize
+	}
+	return blockSize
+}
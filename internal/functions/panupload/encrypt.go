@@ -0,0 +1,52 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package panupload
+
+import (
+	"github.com/tickstep/aliyunpan/library/crypto"
+	"os"
+)
+
+// EncryptFileToTemp 使用 AES-256-GCM 加密本地文件到临时文件，返回临时文件路径。
+// 网盘接口不支持设置文件描述，加密参数(base64 JSON)写在临时文件的头部
+func EncryptFileToTemp(localFilePath, passphrase string) (tmpFilePath string, err error) {
+	params, err := crypto.NewGCMStreamParams()
+	if err != nil {
+		return "", err
+	}
+
+	plainFile, err := os.Open(localFilePath)
+	if err != nil {
+		return "", err
+	}
+	defer plainFile.Close()
+
+	tmpFile, err := os.CreateTemp("", "aliyunpan-encrypt-*")
+	if err != nil {
+		return "", err
+	}
+	tmpFilePath = tmpFile.Name()
+	err = crypto.WriteGCMStreamHeader(tmpFile, params)
+	if err == nil {
+		err = crypto.GCMStreamEncrypt(tmpFile, plainFile, passphrase, params)
+	}
+	if er := tmpFile.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		os.Remove(tmpFilePath)
+		return "", err
+	}
+	return tmpFilePath, nil
+}
@@ -78,6 +78,16 @@ type (
 
 		// 上传文件记录器
 		FileRecorder *log.FileRecorder
+
+		// 加密密码，不为空则先加密文件内容再上传
+		EncryptPassphrase string
+		encryptFilePath   string // 加密后的临时文件，头部为加密参数
+
+		// 预读分片数量，0表示不预读
+		ReadAhead int
+
+		// 测得的上传带宽，单位 B/s。大于0时根据带宽和文件大小自动计算分片大小
+		UploadBandwidth int64
 	}
 )
 
@@ -167,6 +177,7 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 			Parallel:  utu.Parallel,
 			BlockSize: utu.BlockSize,
 			MaxRate:   config.Config.MaxUploadRate,
+			ReadAhead: utu.ReadAhead,
 		}, utu.LocalFileChecksum.UploadOpEntity, utu.GlobalSpeedsStat)
 
 	// 设置断点续传
@@ -293,6 +304,25 @@ func (utu *UploadTaskUnit) pluginCallback(result string) {
 
 func (utu *UploadTaskUnit) OnComplete(lastRunResult *taskframework.TaskUnitRunResult) {
 	// 任务结束，可能成功也可能失败
+	if utu.encryptFilePath != "" {
+		os.Remove(utu.encryptFilePath)
+		utu.encryptFilePath = ""
+	}
+}
+
+// prepareEncryptFile 加密文件到临时文件，之后上传的是加密后的文件内容
+func (utu *UploadTaskUnit) prepareEncryptFile() error {
+	if utu.EncryptPassphrase == "" || utu.encryptFilePath != "" {
+		return nil
+	}
+	fmt.Printf("[%s] %s 正在加密文件: %s\n", utu.taskInfo.Id(), time.Now().Format("2006-01-02 15:04:06"), utu.LocalFileChecksum.Path.LogicPath)
+	tmpFilePath, err := EncryptFileToTemp(utu.LocalFileChecksum.Path.RealPath, utu.EncryptPassphrase)
+	if err != nil {
+		return err
+	}
+	utu.encryptFilePath = tmpFilePath
+	utu.LocalFileChecksum.Path.RealPath = tmpFilePath
+	return nil
 }
 func (utu *UploadTaskUnit) OnCancel(lastRunResult *taskframework.TaskUnitRunResult) {
 
@@ -302,6 +332,10 @@ func (utu *UploadTaskUnit) RetryWait() time.Duration {
 }
 
 func (utu *UploadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
+	if err := utu.prepareEncryptFile(); err != nil {
+		fmt.Printf("[%s] 文件加密失败, 错误信息: %s, 跳过...\n", utu.taskInfo.Id(), err)
+		return
+	}
 	err := utu.LocalFileChecksum.OpenPath()
 	if err != nil {
 		fmt.Printf("[%s] 文件不可读, 错误信息: %s, 跳过...\n", utu.taskInfo.Id(), err)
@@ -483,6 +517,12 @@ StepUploadPrepareUpload:
 		}
 	}
 
+	// 根据上传带宽自动计算BlockSize大小
+	if utu.UploadBandwidth > 0 {
+		utu.BlockSize = AutoUploadBlockSize(utu.LocalFileChecksum.Length, utu.UploadBandwidth)
+		logger.Verboseln("auto upload block size: " + converter.ConvertFileSize(utu.BlockSize, 2))
+	}
+
 	// 自动调整BlockSize大小
 	newBlockSize = utils.ResizeUploadBlockSize(utu.LocalFileChecksum.Length, utu.BlockSize)
 	if newBlockSize != utu.BlockSize {
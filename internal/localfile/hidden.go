@@ -0,0 +1,25 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package localfile
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// isDotFile 文件名以 "." 开头, 即POSIX系统的隐藏文件
+func isDotFile(filePath string) bool {
+	name := filepath.Base(filePath)
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
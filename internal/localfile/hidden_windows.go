@@ -0,0 +1,38 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package localfile
+
+import (
+	"syscall"
+)
+
+// IsHiddenFile 是否为隐藏文件或者隐藏目录, 文件名以 "." 开头或者设置了隐藏属性的文件
+func IsHiddenFile(filePath string) bool {
+	if isDotFile(filePath) {
+		return true
+	}
+	p, err := syscall.UTF16PtrFromString(filePath)
+	if err != nil {
+		return false
+	}
+	attrs, err := syscall.GetFileAttributes(p)
+	if err != nil {
+		return false
+	}
+	return attrs&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
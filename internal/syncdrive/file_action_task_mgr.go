@@ -173,7 +173,7 @@ func (f *FileActionTaskManager) doFileDiffRoutine(localFiles LocalFileList, panF
 	// download file from pan drive
 	if panFilesNeedToDownload != nil {
 		for _, file := range panFilesNeedToDownload {
-			if f.task.Mode == Download {
+			if f.task.Mode == Download || f.task.Mode == SyncTwoWay {
 				syncItem := &SyncFileItem{
 					Action:            SyncFileActionDownload,
 					Status:            SyncFileStatusCreate,
@@ -211,7 +211,7 @@ func (f *FileActionTaskManager) doFileDiffRoutine(localFiles LocalFileList, panF
 	// upload file to pan drive
 	if localFilesNeedToUpload != nil {
 		for _, file := range localFilesNeedToUpload {
-			if f.task.Mode == Upload {
+			if f.task.Mode == Upload || f.task.Mode == SyncTwoWay {
 				// check local file modified or not
 				if file.IsFile() {
 					if f.syncOption.LocalFileModifiedCheckIntervalSec > 0 {
@@ -337,9 +337,57 @@ func (f *FileActionTaskManager) doFileDiffRoutine(localFiles LocalFileList, panF
 			}
 			f.addToSyncDb(downloadPanFile)
 		} else if f.task.Mode == SyncTwoWay {
-			// TODO: no support yet
-			logger.Verboseln("not support sync mode")
+			// 双向同步，两边都有的文件按照优先级决定上传还是下载，不会删除任何文件
+			action := twoWaySyncAction(localFile, panFile, f.syncOption.SyncPriority)
+			if action == "" {
+				logger.Verboseln("file is the same, no need to sync file: ", localFile.Path)
+				continue
+			}
+			syncItem := &SyncFileItem{
+				Action:            action,
+				Status:            SyncFileStatusCreate,
+				StatusUpdateTime:  "",
+				PanFolderPath:     f.task.PanFolderPath,
+				LocalFolderPath:   f.task.LocalFolderPath,
+				DriveId:           f.task.DriveId,
+				DownloadBlockSize: f.syncOption.FileDownloadBlockSize,
+				UploadBlockSize:   f.syncOption.FileUploadBlockSize,
+			}
+			if action == SyncFileActionUpload {
+				syncItem.LocalFile = localFile
+			} else {
+				syncItem.PanFile = panFile
+			}
+			f.addToSyncDb(&FileActionTask{
+				syncItem: syncItem,
+			})
+		}
+	}
+}
+
+// twoWaySyncAction 双向同步时，本地和云盘都存在的文件需要执行的动作。
+// 文件大小和修改时间都相同（或者SHA1相同）认为文件没有修改，返回空；否则按照优先级选择上传或者下载
+func twoWaySyncAction(localFile *LocalFileItem, panFile *PanFileItem, priority SyncPriorityOption) SyncFileAction {
+	if localFile.FileSize == panFile.FileSize {
+		if localFile.Sha1Hash != "" && strings.ToLower(localFile.Sha1Hash) == strings.ToLower(panFile.Sha1Hash) {
+			return ""
+		}
+		if localFile.UpdateTimeUnix() == panFile.UpdateTimeUnix() {
+			return ""
+		}
+	}
+
+	switch priority {
+	case SyncPriorityLocalFirst:
+		return SyncFileActionUpload
+	case SyncPriorityPanFirst:
+		return SyncFileActionDownload
+	default:
+		// 时间优先，修改时间较新的一方覆盖另一方
+		if localFile.UpdateTimeUnix() >= panFile.UpdateTimeUnix() {
+			return SyncFileActionUpload
 		}
+		return SyncFileActionDownload
 	}
 }
 
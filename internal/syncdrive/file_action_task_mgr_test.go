@@ -0,0 +1,39 @@
+package syncdrive
+
+import (
+	"testing"
+)
+
+func TestTwoWaySyncAction(t *testing.T) {
+	localFile := &LocalFileItem{
+		FileSize:  100,
+		UpdatedAt: "2022-08-01 10:00:00",
+	}
+	panFile := &PanFileItem{
+		FileSize:  100,
+		UpdatedAt: "2022-08-01 10:00:00",
+	}
+	if action := twoWaySyncAction(localFile, panFile, SyncPriorityTimestampFirst); action != "" {
+		t.Errorf("same size and time: want no action, got %s", action)
+	}
+
+	// 大小相同, 修改时间不同, 但是SHA1相同
+	localFile.Sha1Hash = "A9993E364706816ABA3E25717850C26C9CD0D89D"
+	panFile.Sha1Hash = "a9993e364706816aba3e25717850c26c9cd0d89d"
+	panFile.UpdatedAt = "2022-08-02 10:00:00"
+	if action := twoWaySyncAction(localFile, panFile, SyncPriorityTimestampFirst); action != "" {
+		t.Errorf("same sha1: want no action, got %s", action)
+	}
+
+	panFile.FileSize = 200
+	panFile.Sha1Hash = "0000000000000000000000000000000000000000"
+	if action := twoWaySyncAction(localFile, panFile, SyncPriorityTimestampFirst); action != SyncFileActionDownload {
+		t.Errorf("time first: want %s, got %s", SyncFileActionDownload, action)
+	}
+	if action := twoWaySyncAction(localFile, panFile, SyncPriorityLocalFirst); action != SyncFileActionUpload {
+		t.Errorf("local first: want %s, got %s", SyncFileActionUpload, action)
+	}
+	if action := twoWaySyncAction(localFile, panFile, SyncPriorityPanFirst); action != SyncFileActionDownload {
+		t.Errorf("pan first: want %s, got %s", SyncFileActionDownload, action)
+	}
+}
@@ -46,8 +46,8 @@ type (
 		Policy SyncPolicy `json:"policy"`
 		// CycleMode 循环模式，OneTime-运行一次，InfiniteLoop-无限循环模式
 		CycleModeType CycleMode `json:"-"`
-		// Priority 优先级选项
-		Priority SyncPriorityOption `json:"-"`
+		// Priority 优先级选项，只对双向同步模式有效
+		Priority SyncPriorityOption `json:"priority,omitempty"`
 		// LastSyncTime 上一次同步时间
 		LastSyncTime string `json:"lastSyncTime"`
 
@@ -244,7 +244,8 @@ func (t *SyncTask) Start() error {
 	} else if t.Mode == Download {
 		go t.scanPanFile(t.ctx)
 	} else if t.Mode == SyncTwoWay {
-		return fmt.Errorf("异常：暂不支持该模式。")
+		// 以本地目录为基准扫描，同时对比云盘对应目录，云盘独有的文件夹会创建到本地后继续扫描
+		go t.scanLocalFile(t.ctx)
 	} else {
 		return fmt.Errorf("异常：暂不支持该模式。")
 	}
@@ -505,7 +506,8 @@ func (t *SyncTask) scanLocalFile(ctx context.Context) {
 			if err1 != nil {
 				continue
 			}
-			if len(files) == 0 {
+			if len(files) == 0 && t.Mode != SyncTwoWay {
+				// 双向同步模式下本地空文件夹对应的云盘文件夹可能有文件，需要继续对比
 				continue
 			}
 			localFileScanList := LocalFileList{}
@@ -591,6 +593,20 @@ func (t *SyncTask) scanLocalFile(ctx context.Context) {
 			for _, pf := range panFileList {
 				pf.Path = path.Join(GetPanFileFullPathFromLocalPath(item.path, t.LocalFolderPath, t.PanFolderPath), pf.FileName)
 				panFileScanList = append(panFileScanList, NewPanFileItem(pf))
+
+				// 双向同步，云盘独有的文件夹先创建到本地再加入扫描队列，扫描时对比出文件夹里面需要下载的文件
+				if t.Mode == SyncTwoWay && pf.IsFolder() {
+					localPath := item.path + "/" + pf.FileName
+					if b, e := utils.PathExists(localPath); e == nil && !b {
+						if e = os.MkdirAll(localPath, 0755); e != nil {
+							logger.Verboseln("create local folder error: ", localPath, e)
+							continue
+						}
+						folderQueue.Push(&folderItem{
+							path: localPath,
+						})
+					}
+				}
 			}
 
 			// 对比文件
@@ -18,6 +18,7 @@ import (
 	"github.com/oleiade/lane"
 	"github.com/tickstep/aliyunpan/internal/waitgroup"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
@@ -30,6 +31,13 @@ type (
 		// 是否统计失败队列
 		IsFailedDeque bool
 		failedDeque   *lane.Deque
+
+		// 任务失败后不再执行队列中剩余的任务
+		AbortOnFailed bool
+		aborted       int32
+
+		// 调用 Stop 后不再执行队列中剩余的任务
+		stopped int32
 	}
 )
 
@@ -52,6 +60,11 @@ func (te *TaskExecutor) lazyInit() {
 	}
 }
 
+// SetIdGenerator 设置任务id生成器, 多个执行器共用同一个生成器时任务id不会重复
+func (te *TaskExecutor) SetIdGenerator(incr *incremental.Int) {
+	te.incr = incr
+}
+
 // 设置任务的最大并发量
 func (te *TaskExecutor) SetParallel(parallel int) {
 	te.parallel = parallel
@@ -93,6 +106,9 @@ func (te *TaskExecutor) Execute() {
 	for {
 		wg := waitgroup.NewWaitGroup(te.parallel)
 		for {
+			if te.Aborted() || te.Stopped() {
+				break
+			}
 			e := te.deque.Shift()
 			if e == nil { // 任务为空
 				break
@@ -104,6 +120,12 @@ func (te *TaskExecutor) Execute() {
 				// type cast failed
 			}
 			wg.AddDelta()
+			// 等待空闲的过程中可能有任务失败或者被停止
+			if te.Aborted() || te.Stopped() {
+				wg.Done()
+				te.deque.Prepend(task)
+				break
+			}
 
 			go func(task *TaskInfoItem) {
 				defer wg.Done()
@@ -134,10 +156,7 @@ func (te *TaskExecutor) Execute() {
 					// 执行失败
 					if task.Info.IsExceedRetry() {
 						task.Unit.OnFailed(result)
-						if te.IsFailedDeque {
-							// 加入失败队列
-							te.failedDeque.Append(task)
-						}
+						te.onFailed(task)
 						task.Unit.OnComplete(result)
 						return
 					}
@@ -153,31 +172,49 @@ func (te *TaskExecutor) Execute() {
 
 				// 执行失败
 				task.Unit.OnFailed(result)
-				if te.IsFailedDeque {
-					// 加入失败队列
-					te.failedDeque.Append(task)
-				}
+				te.onFailed(task)
 				task.Unit.OnComplete(result)
 			}(task)
 		}
 
 		wg.Wait()
 
-		// 没有任务了
-		if te.deque.Size() == 0 {
+		// 没有任务了, 或者已经中止
+		if te.deque.Size() == 0 || te.Aborted() || te.Stopped() {
 			break
 		}
 	}
 }
 
+// onFailed 任务最终执行失败
+func (te *TaskExecutor) onFailed(task *TaskInfoItem) {
+	if te.IsFailedDeque {
+		// 加入失败队列
+		te.failedDeque.Append(task)
+	}
+	if te.AbortOnFailed {
+		atomic.StoreInt32(&te.aborted, 1)
+	}
+}
+
+// Aborted 是否因为任务失败而中止执行, 中止后未执行的任务仍然保留在队列中
+func (te *TaskExecutor) Aborted() bool {
+	return atomic.LoadInt32(&te.aborted) == 1
+}
+
+// Stopped 是否已经调用 Stop 停止执行
+func (te *TaskExecutor) Stopped() bool {
+	return atomic.LoadInt32(&te.stopped) == 1
+}
+
 //FailedDeque 获取失败队列
 func (te *TaskExecutor) FailedDeque() *lane.Deque {
 	return te.failedDeque
 }
 
-//Stop 停止执行
+//Stop 停止执行, 正在执行的任务不受影响, 队列中剩余的任务不再执行, 仍然保留在队列中
 func (te *TaskExecutor) Stop() {
-
+	atomic.StoreInt32(&te.stopped, 1)
 }
 
 //Pause 暂停执行
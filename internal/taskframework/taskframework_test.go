@@ -15,6 +15,7 @@ package taskframework_test
 
 import (
 	"fmt"
+	"github.com/GeertJohan/go.incremental"
 	"github.com/tickstep/aliyunpan/internal/taskframework"
 	"testing"
 	"time"
@@ -74,3 +75,54 @@ func TestTaskExecutor(t *testing.T) {
 	}
 	te.Execute()
 }
+
+func TestTaskExecutorAbortOnFailed(t *testing.T) {
+	te := &taskframework.TaskExecutor{
+		IsFailedDeque: true,
+		AbortOnFailed: true,
+	}
+	te.SetParallel(1)
+	for i := 0; i < 3; i++ {
+		te.Append(&TestUnit{}, 0)
+	}
+	te.Execute()
+	if !te.Aborted() {
+		t.Fatal("executor should be aborted")
+	}
+	if te.FailedDeque().Size() != 1 {
+		t.Fatalf("unexpected failed count: %d", te.FailedDeque().Size())
+	}
+	if te.Count() != 2 {
+		t.Fatalf("unexpected left count: %d", te.Count())
+	}
+}
+
+func TestTaskExecutorStop(t *testing.T) {
+	te := &taskframework.TaskExecutor{
+		IsFailedDeque: true,
+	}
+	te.SetParallel(1)
+	for i := 0; i < 3; i++ {
+		te.Append(&TestUnit{}, 0)
+	}
+	te.Stop()
+	te.Execute()
+	if te.FailedDeque().Size() != 0 {
+		t.Fatalf("unexpected failed count: %d", te.FailedDeque().Size())
+	}
+	if te.Count() != 3 {
+		t.Fatalf("unexpected left count: %d", te.Count())
+	}
+}
+
+func TestTaskExecutorSetIdGenerator(t *testing.T) {
+	incr := &incremental.Int{}
+	te1, te2 := &taskframework.TaskExecutor{}, &taskframework.TaskExecutor{}
+	te1.SetIdGenerator(incr)
+	te2.SetIdGenerator(incr)
+	id1 := te1.Append(&TestUnit{}, 0).Id()
+	id2 := te2.Append(&TestUnit{}, 0).Id()
+	if id1 == id2 {
+		t.Fatalf("task id should not repeat: %s, %s", id1, id2)
+	}
+}
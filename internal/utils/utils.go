@@ -29,6 +29,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -273,6 +274,31 @@ func IsExcludeFile(filePath string, excludeNames *[]string) bool {
 	return false
 }
 
+// IsExcludeGlobFile 文件名是否匹配任意一个通配符，例如 *.tmp、Thumbs.db
+func IsExcludeGlobFile(filePath string, patterns []string) bool {
+	return matchGlobFileName(filePath, patterns)
+}
+
+// IsIncludeGlobFile 文件名是否匹配任意一个包含通配符，例如 *.mp4。没有指定通配符时返回false
+func IsIncludeGlobFile(filePath string, patterns []string) bool {
+	return matchGlobFileName(filePath, patterns)
+}
+
+// matchGlobFileName 文件名是否匹配任意一个通配符
+func matchGlobFileName(filePath string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+
+	fileName := path.Base(strings.ReplaceAll(filePath, "\\", "/"))
+	for _, pattern := range patterns {
+		if m, _ := filepath.Match(pattern, fileName); m {
+			return true
+		}
+	}
+	return false
+}
+
 // ResizeUploadBlockSize 自动调整分片大小，方便支持极大单文件上传。返回新的分片大小
 func ResizeUploadBlockSize(fileSize, defaultBlockSize int64) int64 {
 	if (aliyunpan.MaxPartNum * defaultBlockSize) > fileSize {
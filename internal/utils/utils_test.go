@@ -51,3 +51,17 @@ func TestResizeUploadBlockSize_ReturnNewBlockSize(t *testing.T) {
 	fileSize := int64(107374182400)                     // 100GB
 	fmt.Println(ResizeUploadBlockSize(fileSize, 10*MB)) // 10737664 = 10486KB
 }
+
+func TestIsExcludeGlobFile(t *testing.T) {
+	patterns := []string{"*.tmp", "Thumbs.db"}
+	fmt.Println(IsExcludeGlobFile("/我的文档/a.tmp", patterns))     // true
+	fmt.Println(IsExcludeGlobFile("/我的文档/Thumbs.db", patterns)) // true
+	fmt.Println(IsExcludeGlobFile("/我的文档/a.txt", patterns))     // false
+}
+
+func TestIsIncludeGlobFile(t *testing.T) {
+	patterns := []string{"*.mp4"}
+	fmt.Println(IsIncludeGlobFile("/我的视频/a.mp4", patterns)) // true
+	fmt.Println(IsIncludeGlobFile("/我的视频/a.txt", patterns)) // false
+	fmt.Println(IsIncludeGlobFile("/我的视频/a.mp4", nil))      // false
+}
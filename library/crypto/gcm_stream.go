@@ -0,0 +1,307 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"io"
+)
+
+const (
+	// GCMStreamCipher 流式加密算法名称
+	GCMStreamCipher = "aes-256-gcm"
+	// GCMStreamKDF 密钥派生算法名称
+	GCMStreamKDF = "pbkdf2-sha256"
+	// GCMStreamVersion 加密参数版本
+	GCMStreamVersion = 1
+	// DefaultGCMStreamIterations 默认的密钥派生迭代次数
+	DefaultGCMStreamIterations = 100000
+	// DefaultGCMStreamChunkSize 默认的加密分块大小，每一块单独进行GCM认证
+	DefaultGCMStreamChunkSize = 64 * 1024
+
+	// GCMStreamHeaderMagic 加密文件头部的标识，之后是4字节的加密参数长度和 base64 JSON 格式的加密参数
+	GCMStreamHeaderMagic = "ALYPENC1"
+
+	gcmStreamKeySize      = 32
+	gcmStreamSaltSize     = 16
+	gcmStreamNonceSize    = 12
+	gcmStreamMaxHeaderLen = 4096
+)
+
+var (
+	// ErrGCMStreamParams 加密参数错误
+	ErrGCMStreamParams = errors.New("invalid encrypt params")
+	// ErrGCMStreamNonceMismatch 密文头部的nonce和加密参数不一致
+	ErrGCMStreamNonceMismatch = errors.New("encrypted data nonce mismatch")
+	// ErrGCMStreamTruncated 密文不完整
+	ErrGCMStreamTruncated = errors.New("encrypted data truncated")
+	// ErrGCMStreamHeaderNotFound 文件头部没有加密参数
+	ErrGCMStreamHeaderNotFound = errors.New("encrypt header not found")
+)
+
+type (
+	// GCMStreamParams 流式加密参数，用于解密时还原密钥和nonce
+	GCMStreamParams struct {
+		Version    int    `json:"v"`
+		Cipher     string `json:"cipher"`
+		KDF        string `json:"kdf"`
+		Iterations int    `json:"iter"`
+		Salt       string `json:"salt"`
+		Nonce      string `json:"nonce"`
+		ChunkSize  int    `json:"chunk"`
+	}
+)
+
+// NewGCMStreamParams 生成新的随机加密参数
+func NewGCMStreamParams() (*GCMStreamParams, error) {
+	salt := make([]byte, gcmStreamSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcmStreamNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return &GCMStreamParams{
+		Version:    GCMStreamVersion,
+		Cipher:     GCMStreamCipher,
+		KDF:        GCMStreamKDF,
+		Iterations: DefaultGCMStreamIterations,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		ChunkSize:  DefaultGCMStreamChunkSize,
+	}, nil
+}
+
+// EncodeGCMStreamParams 将加密参数编码为 base64 JSON 字符串
+func EncodeGCMStreamParams(params *GCMStreamParams) (string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecodeGCMStreamParams 解析 base64 JSON 格式的加密参数
+func DecodeGCMStreamParams(s string) (*GCMStreamParams, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrGCMStreamParams
+	}
+	params := &GCMStreamParams{}
+	if err = json.Unmarshal(data, params); err != nil {
+		return nil, ErrGCMStreamParams
+	}
+	if err = params.check(); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// WriteGCMStreamHeader 写入包含加密参数的文件头部，解密时使用 ReadGCMStreamHeader 读取
+func WriteGCMStreamHeader(w io.Writer, params *GCMStreamParams) error {
+	encoded, err := EncodeGCMStreamParams(params)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, len(GCMStreamHeaderMagic)+4, len(GCMStreamHeaderMagic)+4+len(encoded))
+	copy(header, GCMStreamHeaderMagic)
+	binary.BigEndian.PutUint32(header[len(GCMStreamHeaderMagic):], uint32(len(encoded)))
+	header = append(header, encoded...)
+	_, err = w.Write(header)
+	return err
+}
+
+// ReadGCMStreamHeader 读取 WriteGCMStreamHeader 写入的文件头部，返回加密参数
+func ReadGCMStreamHeader(r io.Reader) (*GCMStreamParams, error) {
+	prefix := make([]byte, len(GCMStreamHeaderMagic)+4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, ErrGCMStreamHeaderNotFound
+	}
+	if string(prefix[:len(GCMStreamHeaderMagic)]) != GCMStreamHeaderMagic {
+		return nil, ErrGCMStreamHeaderNotFound
+	}
+	size := binary.BigEndian.Uint32(prefix[len(GCMStreamHeaderMagic):])
+	if size == 0 || size > gcmStreamMaxHeaderLen {
+		return nil, ErrGCMStreamParams
+	}
+	encoded := make([]byte, size)
+	if _, err := io.ReadFull(r, encoded); err != nil {
+		return nil, ErrGCMStreamTruncated
+	}
+	return DecodeGCMStreamParams(string(encoded))
+}
+
+func (p *GCMStreamParams) check() error {
+	if p.Version != GCMStreamVersion || p.Cipher != GCMStreamCipher || p.KDF != GCMStreamKDF {
+		return fmt.Errorf("%w: unsupported %s/%s v%d", ErrGCMStreamParams, p.Cipher, p.KDF, p.Version)
+	}
+	if p.Iterations <= 0 || p.ChunkSize <= 0 {
+		return ErrGCMStreamParams
+	}
+	return nil
+}
+
+func (p *GCMStreamParams) nonce() ([]byte, error) {
+	nonce, err := base64.StdEncoding.DecodeString(p.Nonce)
+	if err != nil || len(nonce) != gcmStreamNonceSize {
+		return nil, ErrGCMStreamParams
+	}
+	return nonce, nil
+}
+
+func (p *GCMStreamParams) aead(passphrase string) (cipher.AEAD, error) {
+	salt, err := base64.StdEncoding.DecodeString(p.Salt)
+	if err != nil {
+		return nil, ErrGCMStreamParams
+	}
+	key := pbkdf2.Key([]byte(passphrase), salt, p.Iterations, gcmStreamKeySize, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GCMStreamEncryptedSize 计算明文加密后的密文大小
+func GCMStreamEncryptedSize(plainSize int64, params *GCMStreamParams) int64 {
+	chunkSize := int64(params.ChunkSize)
+	chunks := (plainSize + chunkSize - 1) / chunkSize
+	if chunks == 0 {
+		// 空文件也会写入一个空的分块，用于认证
+		chunks = 1
+	}
+	return gcmStreamNonceSize + plainSize + chunks*16
+}
+
+// chunkNonce 每个分块的nonce为 基础nonce 异或 分块序号
+func chunkNonce(base []byte, index uint64) []byte {
+	nonce := make([]byte, len(base))
+	copy(nonce, base)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], index)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-8+i] ^= counter[i]
+	}
+	return nonce
+}
+
+// chunkAdditionalData 最后一个分块带有结束标记，防止密文被截断
+func chunkAdditionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// GCMStreamEncrypt 使用 AES-256-GCM 流式加密数据，输出的密文头部为随机nonce
+func GCMStreamEncrypt(dst io.Writer, src io.Reader, passphrase string, params *GCMStreamParams) error {
+	if err := params.check(); err != nil {
+		return err
+	}
+	aead, err := params.aead(passphrase)
+	if err != nil {
+		return err
+	}
+	nonce, err := params.nonce()
+	if err != nil {
+		return err
+	}
+	if _, err = dst.Write(nonce); err != nil {
+		return err
+	}
+
+	// 预读一个分块，用于判断当前分块是否是最后一块
+	cur := make([]byte, params.ChunkSize)
+	next := make([]byte, params.ChunkSize)
+	n, err := io.ReadFull(src, cur)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	for index := uint64(0); ; index++ {
+		m, er := io.ReadFull(src, next)
+		if er != nil && er != io.EOF && er != io.ErrUnexpectedEOF {
+			return er
+		}
+		last := m == 0
+		sealed := aead.Seal(nil, chunkNonce(nonce, index), cur[:n], chunkAdditionalData(last))
+		if _, err = dst.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		cur, next = next, cur
+		n = m
+	}
+}
+
+// GCMStreamDecrypt 解密 GCMStreamEncrypt 加密的数据
+func GCMStreamDecrypt(dst io.Writer, src io.Reader, passphrase string, params *GCMStreamParams) error {
+	if err := params.check(); err != nil {
+		return err
+	}
+	aead, err := params.aead(passphrase)
+	if err != nil {
+		return err
+	}
+	nonce, err := params.nonce()
+	if err != nil {
+		return err
+	}
+	header := make([]byte, gcmStreamNonceSize)
+	if _, err = io.ReadFull(src, header); err != nil {
+		return ErrGCMStreamTruncated
+	}
+	if !bytes.Equal(header, nonce) {
+		return ErrGCMStreamNonceMismatch
+	}
+
+	sealedSize := params.ChunkSize + aead.Overhead()
+	cur := make([]byte, sealedSize)
+	next := make([]byte, sealedSize)
+	n, err := io.ReadFull(src, cur)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return ErrGCMStreamTruncated
+	}
+	for index := uint64(0); ; index++ {
+		m, er := io.ReadFull(src, next)
+		if er != nil && er != io.EOF && er != io.ErrUnexpectedEOF {
+			return er
+		}
+		last := m == 0
+		plain, er := aead.Open(nil, chunkNonce(nonce, index), cur[:n], chunkAdditionalData(last))
+		if er != nil {
+			return er
+		}
+		if _, err = dst.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		cur, next = next, cur
+		n = m
+	}
+}
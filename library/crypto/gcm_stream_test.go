@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGCMStreamEncryptDecrypt(t *testing.T) {
+	for _, size := range []int{0, 1, DefaultGCMStreamChunkSize, DefaultGCMStreamChunkSize + 1} {
+		params, err := NewGCMStreamParams()
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain := bytes.Repeat([]byte("a"), size)
+
+		cipherBuf := &bytes.Buffer{}
+		if err = GCMStreamEncrypt(cipherBuf, bytes.NewReader(plain), "123456", params); err != nil {
+			t.Fatal(err)
+		}
+		if int64(cipherBuf.Len()) != GCMStreamEncryptedSize(int64(size), params) {
+			t.Fatalf("encrypted size mismatch: %d", size)
+		}
+
+		description, _ := EncodeGCMStreamParams(params)
+		decoded, err := DecodeGCMStreamParams(description)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plainBuf := &bytes.Buffer{}
+		if err = GCMStreamDecrypt(plainBuf, bytes.NewReader(cipherBuf.Bytes()), "123456", decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plainBuf.Bytes(), plain) {
+			t.Fatalf("decrypted data mismatch: %d", size)
+		}
+		if GCMStreamDecrypt(&bytes.Buffer{}, bytes.NewReader(cipherBuf.Bytes()), "654321", decoded) == nil {
+			t.Fatalf("decrypt with wrong passphrase should fail: %d", size)
+		}
+	}
+}
+
+func TestGCMStreamHeader(t *testing.T) {
+	params, err := NewGCMStreamParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = WriteGCMStreamHeader(buf, params); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("ciphertext")
+
+	decoded, err := ReadGCMStreamHeader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != *params {
+		t.Fatalf("decoded params mismatch: %+v", decoded)
+	}
+	if buf.String() != "ciphertext" {
+		t.Fatalf("header should be consumed, left: %q", buf.String())
+	}
+	if _, err = ReadGCMStreamHeader(bytes.NewReader([]byte("plain text file"))); err != ErrGCMStreamHeaderNotFound {
+		t.Fatalf("plain data should have no header: %v", err)
+	}
+}
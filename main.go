@@ -323,6 +323,9 @@ func main() {
 
 		// check update
 		command.ReloadConfigFunc(c)
+
+		// 继续监控上次创建的需要过期通知的分享
+		command.StartShareExpiryWatcher(0)
 		if config.Config.UpdateCheckInfo.LatestVer != "" {
 			if utils.ParseVersionNum(config.Config.UpdateCheckInfo.LatestVer) > utils.ParseVersionNum(global.AppVersion) {
 				fmt.Printf("\n当前的软件版本为：%s， 现在有新版本 %s 可供更新，强烈推荐进行更新！（可以输入 update 命令进行更新）\n\n",
@@ -426,6 +429,9 @@ func main() {
 		// 获取当前帐号 who
 		command.CmdWho(),
 
+		// 多账号管理 account
+		command.CmdAccount(),
+
 		// 获取当前帐号空间配额 quota
 		command.CmdQuota(),
 
@@ -438,9 +444,18 @@ func main() {
 		// 列出目录 ls
 		command.CmdLs(),
 
+		// 搜索文件 search
+		command.CmdSearch(),
+
 		// 显示树形目录 tree
 		command.CmdTree(),
 
+		// 对比本地目录和网盘目录 compare-local-pan
+		command.CmdCompareLocalPan(),
+		command.CmdTagSync(),
+		command.CmdBundle(),
+		command.CmdReport(),
+
 		// 创建目录 mkdir
 		command.CmdMkdir(),
 
@@ -456,6 +471,9 @@ func main() {
 		// 重命名文件 rename
 		command.CmdRename(),
 
+		// 文件管理 file
+		command.CmdFile(),
+
 		// 同步备份 sync
 		command.CmdSync(),
 
@@ -471,6 +489,9 @@ func main() {
 		// 工具箱 tool
 		command.CmdTool(),
 
+		// 目录列表缓存 cache
+		command.CmdCache(),
+
 		// 显示命令历史
 		{
 			Name:      "history",
@@ -660,6 +681,9 @@ func main() {
 			// 回收站
 			command.CmdRecycle(),
 
+			// 账号管理 user
+			command.CmdUser(),
+
 			// 相簿
 			//command.CmdAlbum(),
 		}